@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/golemon/parse"
+)
+
+func ambiguityUsage(set *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintln(os.Stderr, "usage: lemon ambiguity [flags] infile")
+		fmt.Fprintln(os.Stderr, "The sentences are searched by length, and their number grows exponentially:")
+		fmt.Fprintln(os.Stderr, "on example/expr.y, each token more takes about 4 times longer, some 7 seconds")
+		fmt.Fprintln(os.Stderr, "for 15 tokens. The search stops at the time limit.")
+		set.PrintDefaults()
+		os.Exit(2)
+	}
+}
+
+// Run `golemon ambiguity`: search a sentence of the grammar with two parse
+// trees, regardless of the conflicts of its parser. Exits with 1 if one is
+// found.
+func runAmbiguity(args []string) {
+	set := flag.NewFlagSet("ambiguity", flag.ExitOnError)
+	maxLength := set.Int("n", 10, "maximum number of tokens of the sentences; the time grows exponentially with it")
+	timeout := set.Duration("timeout", parse.DefaultAmbiguityTimeout, "time limit of the search, 0 for none")
+	classify := set.String("classify", "case", "type of symbols neither declared nor defined by a rule: case, first or usage")
+	set.Usage = ambiguityUsage(set)
+	set.Parse(args)
+
+	if set.NArg() != 1 {
+		set.Usage()
+	}
+
+	classifier, ok := parse.SymbolClassifiers[*classify]
+
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown symbol classifier: %s\n", *classify)
+		set.Usage()
+	}
+
+	lemon := parse.NewLemon(set.Arg(0), "")
+	lemon.SetSymbolClassifier(classifier)
+	lemon.ReadGrammar()
+
+	amb, ok := lemon.FindAmbiguity(*maxLength, *timeout)
+
+	if ok {
+		fmt.Println(amb)
+		os.Exit(1)
+	}
+
+	if amb.Searched < *maxLength {
+		fmt.Printf("Time limit reached: no ambiguous sentence of at most %d tokens\n", amb.Searched)
+	} else {
+		fmt.Printf("No ambiguous sentence of at most %d tokens\n", amb.Searched)
+	}
+}
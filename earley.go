@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/golemon/parse"
+)
+
+func earleyUsage(set *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintln(os.Stderr, "usage: lemon earley [flags] infile [token ...]")
+		set.PrintDefaults()
+		os.Exit(2)
+	}
+}
+
+// Run `golemon earley`: parse the tokens, given by name, with the rules of
+// the grammar, whatever the conflicts of its parser, and print the parse
+// trees. Exits with 1 if the tokens are rejected.
+func runEarley(args []string) {
+	set := flag.NewFlagSet("earley", flag.ExitOnError)
+	maxTrees := set.Int("trees", 10, "maximum number of parse trees printed")
+	classify := set.String("classify", "case", "type of symbols neither declared nor defined by a rule: case, first or usage")
+	set.Usage = earleyUsage(set)
+	set.Parse(args)
+
+	if set.NArg() < 1 {
+		set.Usage()
+	}
+
+	classifier, ok := parse.SymbolClassifiers[*classify]
+
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown symbol classifier: %s\n", *classify)
+		set.Usage()
+	}
+
+	lemon := parse.NewLemon(set.Arg(0), "")
+	lemon.SetSymbolClassifier(classifier)
+	lemon.ReadGrammar()
+	var tokens []*parse.Symbol
+
+	for _, name := range set.Args()[1:] {
+		symbol, ok := lemon.Symbol(name)
+
+		if !ok || !symbol.IsTerminal() || name == parse.EndSymbolName {
+			fmt.Fprintf(os.Stderr, "not a terminal of the grammar: %s\n", name)
+			os.Exit(2)
+		}
+
+		tokens = append(tokens, symbol)
+	}
+
+	result := lemon.EarleyParse(tokens, *maxTrees)
+	fmt.Println(result)
+
+	if !result.Accepted {
+		os.Exit(1)
+	}
+}
@@ -26,6 +26,8 @@ import (
 
 %}
 
+%name expr
+
 %union {
 	num *big.Rat
 }
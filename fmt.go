@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/golemon/format"
+)
+
+// Flags of `golemon fmt`.
+type fmtFlags struct {
+	list  bool // Print the names of files whose formatting differs
+	write bool // Write the result back to the files
+	diff  bool // Print the diffs instead of the result
+}
+
+func fmtUsage(set *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintln(os.Stderr, "usage: lemon fmt [flags] [path ...]")
+		set.PrintDefaults()
+		os.Exit(2)
+	}
+}
+
+// Run `golemon fmt`. Without paths the standard input is formatted to the
+// standard output. Directories are walked for `.y` files.
+func runFmt(args []string) {
+	var flags fmtFlags
+	set := flag.NewFlagSet("fmt", flag.ExitOnError)
+	set.BoolVar(&flags.list, "l", false, "list files whose formatting differs")
+	set.BoolVar(&flags.write, "w", false, "write result to the source file instead of stdout")
+	set.BoolVar(&flags.diff, "d", false, "display diffs instead of rewriting files")
+	set.Usage = fmtUsage(set)
+	set.Parse(args)
+
+	failed := false
+
+	if set.NArg() == 0 {
+		if flags.write {
+			fmt.Fprintln(os.Stderr, "error: cannot use -w with standard input")
+			os.Exit(2)
+		}
+
+		if err := fmtFile("<standard input>", os.Stdin, flags); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+
+	for _, path := range set.Args() {
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if entry.IsDir() || (file != path && filepath.Ext(file) != ".y") {
+				return nil
+			}
+
+			if err := fmtFile(file, nil, flags); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(2)
+	}
+}
+
+// Format one file. If `in` is nil the file is read from disk.
+func fmtFile(filename string, in io.Reader, flags fmtFlags) error {
+	var src []byte
+	var err error
+
+	if in == nil {
+		src, err = os.ReadFile(filename)
+	} else {
+		src, err = io.ReadAll(in)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	res, err := format.Source(src)
+
+	if err != nil {
+		return fmt.Errorf("%s:%v", filename, err)
+	}
+
+	changed := !bytes.Equal(src, res)
+
+	if changed && flags.list {
+		fmt.Println(filename)
+	}
+
+	if changed && flags.write {
+		info, err := os.Stat(filename)
+
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(filename, res, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+
+	if changed && flags.diff {
+		os.Stdout.Write(format.Diff(filename+".orig", filename, src, res))
+	}
+
+	if !flags.list && !flags.write && !flags.diff {
+		os.Stdout.Write(res)
+	}
+
+	return nil
+}
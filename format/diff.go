@@ -0,0 +1,217 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const diffContext = 3 // Unchanged lines shown around each change
+
+// An edit of the line script turning `a` into `b`.
+type edit struct {
+	op   byte // ' ', '-' or '+'
+	line string
+}
+
+func splitLines(src []byte) []string {
+	var lines []string
+
+	for len(src) > 0 {
+		n := bytes.IndexByte(src, '\n')
+
+		if n < 0 {
+			lines = append(lines, string(src)+"\n\\ No newline at end of file\n")
+			break
+		}
+
+		lines = append(lines, string(src[:n+1]))
+		src = src[n+1:]
+	}
+
+	return lines
+}
+
+// Compute the shortest edit script with the Myers algorithm.
+func lineEdits(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[max+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, max)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Walk the snapshots of `v` back from the end to collect the edits.
+func backtrack(a, b []string, trace [][]int, max int) []edit {
+	var edits []edit
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+
+		if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := v[max+prevK]
+		prevY := prevX - prevK
+
+		if d == 0 {
+			prevX, prevY = 0, 0
+		}
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{' ', a[x]})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, edit{'+', b[y]})
+			} else {
+				x--
+				edits = append(edits, edit{'-', a[x]})
+			}
+		}
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+
+	return edits
+}
+
+// Diff returns the changes from `a` to `b` in unified format, or nil if
+// there are none.
+func Diff(oldName, newName string, a, b []byte) []byte {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+
+	edits := lineEdits(splitLines(a), splitLines(b))
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldName, newName)
+
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while changes are close enough to share context.
+		start := i - diffContext
+
+		if start < 0 {
+			start = 0
+		}
+
+		end := i
+
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+
+			same := end
+
+			for same < len(edits) && edits[same].op == ' ' {
+				same++
+			}
+
+			if same == len(edits) || same-end > 2*diffContext {
+				end += diffContext
+
+				if end > len(edits) {
+					end = len(edits)
+				}
+
+				break
+			}
+
+			end = same
+		}
+
+		// Line numbers of the hunk start in both files.
+		oldLine, newLine := 1, 1
+
+		for _, e := range edits[:start] {
+			if e.op != '+' {
+				oldLine++
+			}
+
+			if e.op != '-' {
+				newLine++
+			}
+		}
+
+		oldCount, newCount := 0, 0
+
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				oldCount++
+			}
+
+			if e.op != '-' {
+				newCount++
+			}
+		}
+
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+
+		for _, e := range edits[start:end] {
+			buf.WriteByte(e.op)
+			buf.WriteString(e.line)
+		}
+
+		i = end
+	}
+
+	return buf.Bytes()
+}
+
+func hunkRange(line, count int) string {
+	if count == 0 {
+		line--
+	}
+
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+
+	return fmt.Sprintf("%d,%d", line, count)
+}
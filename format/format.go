@@ -0,0 +1,537 @@
+// Package format implements the standard formatting of grammar files.
+//
+// Rules are written one alternative per line with `:`, `|` and `;`
+// aligned. Consecutive `%token` and `%type` declarations with the same tag
+// are merged. Comments are kept in place. Code inside `%{ %}`, `%union`
+// and after the second `%%` is left untouched, while the lines of multi-line
+// actions are re-indented.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/golemon/parse"
+)
+
+const (
+	ruleIndent = "\t"   // Indentation of `:`, `|` and `;`
+	contIndent = "\t  " // Indentation of a line continuing an alternative
+)
+
+// Format the grammar file `src`. Formatting its output again gives the
+// same result. Malformed input such as an unterminated comment is an error.
+func Source(src []byte) ([]byte, error) {
+	var err error
+
+	cst := parse.ParseCST(src, func(pos parse.Position, msg string) {
+		if err == nil {
+			err = fmt.Errorf("%v: %s", pos, msg)
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &printer{cst: cst}
+	p.file(cst.Root)
+
+	return p.buf.Bytes(), nil
+}
+
+type printer struct {
+	cst         *parse.CST
+	buf         bytes.Buffer
+	lineComment bool   // The current line ends with a `//` comment
+	indent      string // Indentation used after a `//` comment
+}
+
+// A node at the top level of the file.
+type item struct {
+	node     *parse.Node
+	newlines int // Number of newlines since the previous item
+}
+
+// A token with the comments before it.
+type part struct {
+	node     *parse.Node
+	comments []comment
+}
+
+type comment struct {
+	node    *parse.Node
+	ownLine bool // Preceded by a newline
+}
+
+func isComment(node *parse.Node) bool {
+	return node.Kind == parse.NdToken && node.Token.Kind == parse.TkComment
+}
+
+func (p *printer) text(node *parse.Node) string {
+	return p.cst.Text(node)
+}
+
+func (p *printer) write(strs ...string) {
+	for _, str := range strs {
+		p.buf.WriteString(str)
+	}
+}
+
+// Start a new line with the given indentation. Trailing spaces are removed.
+func (p *printer) newline(blank bool, indent string) {
+	for bytes.HasSuffix(p.buf.Bytes(), []byte(" ")) || bytes.HasSuffix(p.buf.Bytes(), []byte("\t")) {
+		p.buf.Truncate(p.buf.Len() - 1)
+	}
+
+	if p.buf.Len() > 0 {
+		p.write("\n")
+
+		if blank {
+			p.write("\n")
+		}
+	}
+
+	p.write(indent)
+	p.lineComment = false
+}
+
+// Separate two tokens on the same line, unless a `//` comment ends it.
+func (p *printer) space() {
+	if p.lineComment {
+		p.newline(false, p.indent)
+	} else {
+		p.write(" ")
+	}
+}
+
+func (p *printer) comment(node *parse.Node) {
+	text := strings.TrimRight(p.text(node), " \t\r")
+	p.write(text)
+	p.lineComment = strings.HasPrefix(text, "//")
+}
+
+func (p *printer) file(root *parse.Node) {
+	var items []item
+	newlines := 0
+
+	for _, child := range root.Children {
+		switch {
+		case child.Kind == parse.NdToken && child.Token.Kind == parse.TkNewline:
+			newlines++
+		case child.IsTrivia() && !isComment(child):
+		default:
+			items = append(items, item{child, newlines})
+			newlines = 0
+		}
+	}
+
+	prevKind := parse.NdFile
+
+	for i := 0; i < len(items); i++ {
+		node := items[i].node
+
+		// A comment or stray token on the same line as the previous item.
+		if i > 0 && node.Kind == parse.NdToken && items[i].newlines == 0 {
+			p.space()
+			p.comment(node)
+			continue
+		}
+
+		if node.Kind == parse.NdEpilogue && node.End > node.Start {
+			p.write(p.text(node))
+			return
+		}
+
+		blank := items[i].newlines > 1 || prevKind == parse.NdRule || prevKind == parse.NdPrologue ||
+			prevKind == parse.NdSeparator || node.Kind == parse.NdSeparator
+		p.newline(i > 0 && blank, "")
+		p.indent = ""
+		prevKind = node.Kind
+
+		switch node.Kind {
+		case parse.NdDecl:
+			end := i + 1
+
+			for end < len(items) && items[end].node.Kind == parse.NdDecl && items[end].newlines < 2 {
+				end++
+			}
+
+			trailing := end < len(items) && isComment(items[end].node) && items[end].newlines == 0
+			p.decls(items[i:end], trailing)
+			i = end - 1
+
+		case parse.NdRule:
+			p.rule(node)
+
+		case parse.NdSeparator:
+			p.write("%%")
+
+		case parse.NdPrologue:
+			p.tokens(node.Children, "")
+
+		default:
+			p.comment(node)
+		}
+	}
+
+	p.newline(false, "")
+}
+
+// Write tokens separated by a single space. Only `%` and `#` are glued to
+// the following keyword or label.
+func (p *printer) tokens(nodes []*parse.Node, indent string) {
+	first := true
+	glue := false
+	p.indent = indent
+
+	for _, node := range nodes {
+		if node.IsTrivia() && !isComment(node) {
+			continue
+		}
+
+		if !first && !glue {
+			p.space()
+		}
+
+		if isComment(node) {
+			p.comment(node)
+		} else {
+			p.write(strings.TrimRight(p.text(node), " \t\r"))
+		}
+
+		first = false
+		glue = p.text(node) == "%" || p.text(node) == "#"
+	}
+}
+
+// A declaration such as `%token <tag> A B`.
+type decl struct {
+	keyword string   // Lower case keyword without `%`
+	tag     string   // The tag including `<>`, if any
+	args    []string // Symbols or code
+	node    *parse.Node
+	raw     bool // Can't be normalized, so written token by token
+}
+
+func (p *printer) parseDecl(node *parse.Node) *decl {
+	d := &decl{node: node}
+	sig := node.Significant()
+
+	if len(sig) < 2 || sig[1].Token.Kind != parse.TkIdent || hasComment(node) {
+		d.raw = true
+		return d
+	}
+
+	d.keyword = strings.ToLower(p.text(sig[1]))
+
+	for _, arg := range sig[2:] {
+		if arg.Token.Kind == parse.TkTag && d.tag == "" && len(d.args) == 0 {
+			d.tag = p.text(arg)
+		} else {
+			d.args = append(d.args, p.text(arg))
+		}
+	}
+
+	return d
+}
+
+func hasComment(node *parse.Node) bool {
+	for _, child := range node.Children {
+		if isComment(child) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *decl) mergeable() bool {
+	return !d.raw && (d.keyword == "token" || d.keyword == "type")
+}
+
+// Write a block of declarations. Within a block, `%token` and `%type`
+// declarations sharing a tag are merged into the first of them, unless a
+// precedence or other declaration lies between. Keywords are padded so
+// tags and symbols line up.
+func (p *printer) decls(items []item, trailing bool) {
+	var lines []*decl
+	group := map[string]*decl{}
+
+	for i, it := range items {
+		d := p.parseDecl(it.node)
+
+		if !d.mergeable() || (trailing && i == len(items)-1) {
+			group = map[string]*decl{}
+			lines = append(lines, d)
+			continue
+		}
+
+		key := d.keyword + " " + d.tag
+
+		if first, ok := group[key]; ok {
+			for _, arg := range d.args {
+				if !contains(first.args, arg) {
+					first.args = append(first.args, arg)
+				}
+			}
+		} else {
+			group[key] = d
+			lines = append(lines, d)
+		}
+	}
+
+	width := 0
+
+	for _, d := range lines {
+		if !d.raw && len(d.keyword) > width {
+			width = len(d.keyword)
+		}
+	}
+
+	for i, d := range lines {
+		if i > 0 {
+			p.newline(false, "")
+		}
+
+		if d.raw {
+			p.tokens(d.node.Children, ruleIndent)
+			continue
+		}
+
+		fields := []string{"%" + d.keyword + strings.Repeat(" ", width-len(d.keyword))}
+
+		if d.tag != "" {
+			fields = append(fields, d.tag)
+		}
+
+		p.write(strings.Join(append(fields, d.args...), " "))
+	}
+}
+
+func contains(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Split the children of a node into tokens, each with the comments before it.
+func splitParts(nodes []*parse.Node) []part {
+	var parts []part
+	var comments []comment
+	ownLine := false
+
+	for _, node := range nodes {
+		switch {
+		case node.Kind == parse.NdToken && node.Token.Kind == parse.TkNewline:
+			ownLine = true
+		case isComment(node):
+			comments = append(comments, comment{node, ownLine})
+			ownLine = false
+		case node.IsTrivia():
+		default:
+			parts = append(parts, part{node, comments})
+			comments = nil
+			ownLine = false
+		}
+	}
+
+	return parts
+}
+
+// Write a rule as
+//
+//	lhs
+//		: alternative
+//		| alternative
+//		;
+func (p *printer) rule(node *parse.Node) {
+	parts := splitParts(node.Children)
+	p.write(p.text(parts[0].node))
+	p.indent = contIndent
+
+	// Single-line actions of the alternatives are aligned.
+	column := 0
+
+	for _, pt := range parts {
+		if head, code := p.alternative(pt.node); code != nil && isSimple(head, p.text(code)) {
+			if n := utf8.RuneCountInString(head); n > column {
+				column = n
+			}
+		}
+	}
+
+	hasSemicolon := false
+
+	for i := 1; i < len(parts); i++ {
+		pt := parts[i]
+
+		if pt.node.Kind == parse.NdAlternative {
+			p.writeAlternative(pt, column)
+			continue
+		}
+
+		for _, c := range pt.comments {
+			p.writeComment(c)
+		}
+
+		// Comments on their own lines before an alternative are moved
+		// above its `:` or `|`.
+		if i+1 < len(parts) && parts[i+1].node.Kind == parse.NdAlternative {
+			for _, c := range parts[i+1].comments {
+				if c.ownLine {
+					p.writeComment(c)
+				}
+			}
+		}
+
+		text := p.text(pt.node)
+		hasSemicolon = hasSemicolon || text == ";"
+		p.newline(false, ruleIndent)
+		p.write(text)
+	}
+
+	if !hasSemicolon {
+		p.newline(false, ruleIndent)
+		p.write(";")
+	}
+}
+
+func (p *printer) writeComment(c comment) {
+	if c.ownLine {
+		p.newline(false, ruleIndent)
+	} else {
+		p.space()
+	}
+
+	p.comment(c.node)
+}
+
+func (p *printer) writeAlternative(pt part, column int) {
+	for _, c := range pt.comments {
+		if !c.ownLine {
+			p.writeComment(c)
+		}
+	}
+
+	head, code := p.alternative(pt.node)
+
+	if head == "" && code == nil {
+		return
+	}
+
+	p.space()
+	p.write(head)
+
+	if code == nil {
+		return
+	}
+
+	text := p.text(code)
+
+	if isSimple(head, text) {
+		if head != "" || column > 0 {
+			p.space()
+		}
+
+		p.write(strings.Repeat(" ", column-utf8.RuneCountInString(head)), text)
+	} else {
+		if head != "" {
+			p.space()
+		}
+
+		p.write(reindent(text, ruleIndent))
+	}
+}
+
+// A head and action which can be aligned with others.
+func isSimple(head, code string) bool {
+	return !strings.Contains(head, "\n") && !strings.Contains(code, "\n")
+}
+
+// Format the alternative, except a final action which is returned separately.
+func (p *printer) alternative(node *parse.Node) (string, *parse.Node) {
+	if node.Kind != parse.NdAlternative {
+		return "", nil
+	}
+
+	children := node.Children
+	var code *parse.Node
+
+	if n := len(children); n > 0 && children[n-1].Token.Kind == parse.TkCode {
+		code = children[n-1]
+		children = children[:n-1]
+	}
+
+	sub := &printer{cst: p.cst}
+	sub.tokens(children, contIndent)
+
+	return strings.TrimRight(sub.buf.String(), " \t"), code
+}
+
+// Re-indent the lines of a multi-line action: the body by `indent` plus one
+// tab, and a closing brace on its own line by `indent`. Nested indentation
+// of four spaces becomes a tab. Actions containing raw strings are left alone.
+func reindent(code string, indent string) string {
+	if strings.Contains(code, "`") {
+		return code
+	}
+
+	lines := strings.Split(code, "\n")
+
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " \t\r")
+	}
+
+	body := lines[1:]
+	closing := strings.TrimSpace(body[len(body)-1]) == "}"
+
+	if closing {
+		body = body[:len(body)-1]
+	}
+
+	prefix := ""
+	first := true
+
+	for _, line := range body {
+		if line == "" {
+			continue
+		}
+
+		space := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+		if first {
+			prefix = space
+			first = false
+		}
+
+		for !strings.HasPrefix(space, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	result := []string{lines[0]}
+
+	for _, line := range body {
+		if line == "" {
+			result = append(result, "")
+		} else {
+			rest := line[len(prefix):]
+			space := rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))]
+			rest = strings.ReplaceAll(space, "    ", "\t") + rest[len(space):]
+			result = append(result, indent+"\t"+rest)
+		}
+	}
+
+	if closing {
+		result = append(result, indent+"}")
+	}
+
+	return strings.Join(result, "\n")
+}
@@ -0,0 +1,146 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSourceExamples(t *testing.T) {
+	files, _ := filepath.Glob("../example/*.y")
+
+	if len(files) == 0 {
+		t.Fatal("No example found")
+	}
+
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := Source(src)
+
+		if strings.HasSuffix(file, "eof_inside_comment.y") {
+			if err == nil {
+				t.Errorf("%s: expect an error", file)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+
+		again, err := Source(out)
+
+		if err != nil || string(again) != string(out) {
+			t.Errorf("%s: not idempotent:\n%s", file, Diff("once", "twice", out, again))
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	src := `%{
+package main
+%}
+%token A // first
+%type <x> b
+%token   C
+%type <x> c d
+%left '+'
+%token D
+
+%%
+// The rule.
+a :
+    A b { x(); } // trailing
+  | C
+    {
+        if y {
+            z()
+        }
+    }
+  | /* empty */
+  | D %prec A {q}
+a2: b # Only ;
+%%
+func main() {}
+`
+	expect := `%{
+package main
+%}
+
+%token A // first
+%type  <x> b c d
+%token C
+%left  '+'
+%token D
+
+%%
+
+// The rule.
+a
+	: A b       { x(); } // trailing
+	| C {
+		if y {
+			z()
+		}
+	}
+	| /* empty */
+	| D %prec A {q}
+	;
+
+a2
+	: b #Only
+	;
+
+%%
+func main() {}
+`
+	out, err := Source([]byte(src))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != expect {
+		t.Errorf("Unexpected output:\n%s", Diff("expect", "actual", []byte(expect), out))
+	}
+
+	if again, _ := Source(out); string(again) != expect {
+		t.Errorf("Not idempotent:\n%s", Diff("once", "twice", out, again))
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	expect := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`
+
+	if diff := string(Diff("a", "b", []byte(a), []byte(b))); diff != expect {
+		t.Errorf("Expect:\n%s\nactual:\n%s", expect, diff)
+	}
+
+	if diff := Diff("a", "b", []byte(a), []byte(a)); diff != nil {
+		t.Errorf("Expect no diff, actual:\n%s", diff)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,8 +9,23 @@ import (
 	"github.com/golemon/parse"
 )
 
+var classify = flag.String("classify", "case", "type of symbols neither declared nor defined by a rule: case, first or usage")
+var report = flag.String("report", "", "also write a report documenting the grammar: md or html")
+var quiet = flag.Bool("q", false, "don't write the report of the states (.out file)")
+var verbose = flag.Bool("v", false, "print the grammar, its FIRST sets and a summary of the states")
+var lr = flag.String("lr", "lalr", "how the states of the parser are built: lalr, canonical, minimal or slr")
+var mode = flag.String("mode", "lr", "type of the generated parser: lr, or ll1 for a recursive-descent parser")
+var cex = flag.Bool("cex", false, "print counterexamples of the conflicts")
+var lookahead = flag.Int("k", 1, "maximum number of tokens of lookahead to decide the conflicts left by precedence")
+var cexTimeout = flag.Duration("cex-timeout", parse.DefaultCounterexampleTimeout, "time limit of the search of an ambiguous counterexample, per conflict")
+
 func usage() {
-	fmt.Println("usage: lemon infile [outfile]")
+	fmt.Println("usage: lemon [flags] infile [outfile]")
+	fmt.Println("       lemon fmt [-l] [-w] [-d] [path ...]")
+	fmt.Println("       lemon ambiguity [-n length] [-timeout duration] infile")
+	fmt.Println("       lemon transform -t name[,name...] infile")
+	fmt.Println("       lemon earley [-trees n] infile [token ...]")
+	flag.PrintDefaults()
 	os.Exit(1)
 }
 
@@ -20,20 +36,134 @@ func fileNameWithoutExtension(filename string) string {
 	return base[0 : len(base)-len(extension)]
 }
 
-// TODO: parse command flag
+// Get the path of the file without its extension, in the same directory.
+func pathWithoutExtension(filename string) string {
+	return filename[0 : len(filename)-len(filepath.Ext(filename))]
+}
+
 func main() {
-	if len(os.Args) < 2 {
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		runFmt(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "ambiguity" {
+		runAmbiguity(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "transform" {
+		runTransform(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "earley" {
+		runEarley(os.Args[2:])
+		return
+	}
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+	}
+
+	classifier, ok := parse.SymbolClassifiers[*classify]
+
+	if !ok {
+		fmt.Printf("unknown symbol classifier: %s\n", *classify)
+		usage()
+	}
+
+	reportFormat, ok := parse.ReportFormats[*report]
+
+	if *report != "" && !ok {
+		fmt.Printf("unknown report format: %s\n", *report)
+		usage()
+	}
+
+	lrMode, ok := parse.LRModes[*lr]
+
+	if !ok {
+		fmt.Printf("unknown LR mode: %s\n", *lr)
+		usage()
+	}
+
+	if *mode != "lr" && *mode != "ll1" {
+		fmt.Printf("unknown parser mode: %s\n", *mode)
 		usage()
 	}
 
-	infile := os.Args[1]
+	infile := flag.Arg(0)
 	outfile := fileNameWithoutExtension(infile) + ".go"
 
-	if len(os.Args) == 3 {
-		outfile = os.Args[2]
+	if flag.NArg() == 2 {
+		outfile = flag.Arg(1)
 	}
 
 	lemon := parse.NewLemon(infile, outfile)
-	lemon.Parse()
+	lemon.SetSymbolClassifier(classifier)
+	lemon.SetLRMode(lrMode)
+	lemon.SetMaxLookahead(*lookahead)
+	lemon.SetVerbose(*verbose)
+
+	if *cex {
+		lemon.SetCounterexampleTimeout(*cexTimeout)
+	}
+
+	if *mode == "ll1" {
+		lemon.ParseLL1()
+	} else {
+		lemon.Parse()
+
+		if *verbose {
+			fmt.Println(lemon.StateSummary())
+		}
+	}
+
+	lemon.Generate()
+
+	if *report != "" {
+		writeReport(lemon, pathWithoutExtension(outfile)+reportFormat.Extension(), reportFormat)
+	}
+
+	// A recursive-descent parser has no states.
+	if !*quiet && *mode == "lr" {
+		writeOutput(lemon, pathWithoutExtension(outfile)+".out")
+	}
+}
 
+func writeReport(lemon *parse.Lemon, filename string, format parse.ReportFormat) {
+	file, err := os.Create(filename)
+
+	if err == nil {
+		err = lemon.WriteReport(file, format)
+
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func writeOutput(lemon *parse.Lemon, filename string) {
+	file, err := os.Create(filename)
+
+	if err == nil {
+		err = lemon.WriteOutput(file)
+
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -7,12 +7,63 @@ const (
 	Accept
 	Reduce
 	Error
-	Conflict       // Was a reduce, but part of a conflict
-	ShiftResolved  // Was a shift. Precedence resolved conflict
-	ReduceResolved // Was reduce. Precedence resolved conflict
-	NotUsed        // Deleted by compression
+	Conflict         // Was a reduce, but part of a conflict
+	ShiftResolved    // Was a shift. Precedence resolved conflict
+	ReduceResolved   // Was reduce. Precedence resolved conflict
+	NotUsed          // Deleted by compression
+	LookaheadDecided // Was a reduce, but part of a conflict decided by more lookahead
 )
 
 // Every shift or reduce operation is stored as one of the following.
+// A shift on a non-terminal is the goto of the state.
 type Action struct {
+	sp         *Symbol    // The look-ahead symbol
+	actionType ActionType // What to do when the look-ahead is seen
+	stp        *State     // The new state, if a shift
+	rp         *Rule      // The rule, if a reduce
+}
+
+// Get the look-ahead symbol of the action.
+func (ap *Action) Symbol() *Symbol {
+	return ap.sp
+}
+
+// Get what the parser does on the look-ahead symbol.
+func (ap *Action) Type() ActionType {
+	return ap.actionType
+}
+
+// Get the state a shift moves to, or nil.
+func (ap *Action) State() *State {
+	return ap.stp
+}
+
+// Get the rule a reduce reduces, or nil.
+func (ap *Action) Rule() *Rule {
+	return ap.rp
+}
+
+func (actionType ActionType) String() string {
+	switch actionType {
+	case Shift:
+		return "shift"
+	case Accept:
+		return "accept"
+	case Reduce:
+		return "reduce"
+	case Error:
+		return "error"
+	case Conflict:
+		return "conflict"
+	case ShiftResolved:
+		return "shift resolved"
+	case ReduceResolved:
+		return "reduce resolved"
+	case NotUsed:
+		return "not used"
+	case LookaheadDecided:
+		return "decided by lookahead"
+	}
+
+	return "Not implemented"
 }
@@ -0,0 +1,277 @@
+package parse
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+type ConflictKind int
+
+const (
+	ShiftReduce ConflictKind = iota
+	ReduceReduce
+)
+
+func (kind ConflictKind) String() string {
+	switch kind {
+	case ShiftReduce:
+		return "shift/reduce"
+	case ReduceReduce:
+		return "reduce/reduce"
+	}
+
+	return "Not implemented"
+}
+
+// A conflict which is not resolved by precedence. The parser takes the
+// first action, the shift or the reduce by the earliest rule, and the
+// other is marked as a `Conflict`.
+type ParseConflict struct {
+	Kind      ConflictKind
+	State     *State
+	Lookahead *Symbol
+	Taken     *Action // The action the parser takes
+	Dropped   *Action // The reduce which is never done
+}
+
+func (conflict ParseConflict) String() string {
+	taken := "shift"
+
+	if conflict.Taken.actionType != Shift {
+		taken = fmt.Sprintf("reduce `%v`", conflict.Taken.rp)
+	}
+
+	return fmt.Sprintf("State %d has a %v conflict on %s: %s, not reduce `%v`",
+		conflict.State.index, conflict.Kind, conflict.Lookahead.name, taken, conflict.Dropped.rp)
+}
+
+// Sort the actions by look-ahead, then shifts and accept before reduces,
+// and reduces in the order of the rules.
+func sortActions(actions []Action) {
+	sort.SliceStable(actions, func(i, j int) bool {
+		x, y := &actions[i], &actions[j]
+
+		if x.sp.index != y.sp.index {
+			return x.sp.index < y.sp.index
+		}
+
+		if x.actionType != y.actionType {
+			return x.actionType < y.actionType
+		}
+
+		return x.rp != nil && y.rp != nil && x.rp.index < y.rp.index
+	})
+}
+
+// Resolve the conflict between two actions on the same look-ahead, the
+// first one being a shift, an accept or a reduce, and the second a
+// reduce. Return true if it isn't resolved by precedence.
+//
+// A shift/reduce conflict is resolved by the precedence of the look-ahead
+// and of the rule: the higher wins. At the same precedence, a left
+// associative look-ahead makes the parser reduce, a right associative one
+// makes it shift, and a non-associative one makes it an error. Without
+// precedence, the parser shifts. A reduce/reduce conflict is resolved in
+// favor of the earliest rule.
+func resolveConflict(apx *Action, apy *Action) bool {
+	if apy.actionType != Reduce {
+		return false
+	}
+
+	switch apx.actionType {
+	case Shift:
+		spx := apx.sp
+		spy := apy.rp.precSym
+
+		switch {
+		case spy == nil || spx.precedence < 0 || spy.precedence < 0:
+			// Not enough precedence information.
+			apy.actionType = Conflict
+
+			return true
+		case spx.precedence > spy.precedence:
+			apy.actionType = ReduceResolved
+		case spx.precedence < spy.precedence:
+			apx.actionType = ShiftResolved
+		case spx.assoc == Right:
+			apy.actionType = ReduceResolved
+		case spx.assoc == Left:
+			apx.actionType = ShiftResolved
+		default:
+			apx.actionType = Error
+			apy.actionType = ReduceResolved
+		}
+
+	case Accept, Reduce:
+		apy.actionType = Conflict
+
+		return true
+	}
+
+	return false
+}
+
+// Build the actions of each state: the shifts and gotos of the automaton,
+// then a reduce for each lookahead of each completed configuration, and
+// an accept on `$` after the start symbol. Then resolve the conflicts,
+// mark the rules which are reduced, and count the conflicts left.
+func (lemon *Lemon) findActions() {
+	symbols := lemon.symTable.SortedSymbols()
+	lemon.conflicts = nil
+
+	for rule := lemon.firstRule; rule != nil; rule = rule.next {
+		rule.canReduce = false
+	}
+
+	for _, stp := range lemon.sortedState {
+		actions := stp.ap[:0]
+
+		// Actions may be built again, e.g. in another mode.
+		for _, ap := range stp.ap {
+			switch ap.actionType {
+			case Shift, ShiftResolved:
+				actions = append(actions, Action{sp: ap.sp, actionType: Shift, stp: ap.stp})
+			case Error:
+				if ap.stp != nil {
+					actions = append(actions, Action{sp: ap.sp, actionType: Shift, stp: ap.stp})
+				}
+			}
+		}
+
+		for _, cfp := range stp.cfp {
+			if cfp.dot < cfp.rp.nrhs {
+				continue
+			}
+
+			for _, index := range cfp.fws.AsSlice() {
+				if cfp.rp == lemon.acceptRule {
+					actions = append(actions, Action{sp: symbols[index], actionType: Accept})
+				} else {
+					actions = append(actions, Action{sp: symbols[index], actionType: Reduce, rp: cfp.rp})
+				}
+			}
+		}
+
+		sortActions(actions)
+		stp.ap = actions
+
+		for i := range stp.ap {
+			for j := i + 1; j < len(stp.ap) && stp.ap[j].sp == stp.ap[i].sp; j++ {
+				if resolveConflict(&stp.ap[i], &stp.ap[j]) {
+					kind := ShiftReduce
+
+					if stp.ap[i].actionType != Shift {
+						kind = ReduceReduce
+					}
+
+					lemon.conflicts = append(lemon.conflicts, ParseConflict{kind, stp, stp.ap[j].sp, &stp.ap[i], &stp.ap[j]})
+				}
+			}
+		}
+
+		for _, ap := range stp.ap {
+			if ap.actionType == Reduce {
+				ap.rp.canReduce = true
+			}
+		}
+	}
+
+	lemon.nconflict = len(lemon.conflicts)
+}
+
+// Get the conflicts which are not resolved by precedence.
+func (lemon *Lemon) Conflicts() []ParseConflict {
+	return lemon.conflicts
+}
+
+// Get the number of conflicts which are not resolved by precedence.
+func (lemon *Lemon) ConflictCount() int {
+	return lemon.nconflict
+}
+
+// Get the numbers of shift/reduce and reduce/reduce conflicts.
+func (lemon *Lemon) conflictCounts() (int, int) {
+	sr, rr := 0, 0
+
+	for _, conflict := range lemon.conflicts {
+		if conflict.Kind == ShiftReduce {
+			sr++
+		} else {
+			rr++
+		}
+	}
+
+	return sr, rr
+}
+
+// Check the numbers of conflicts against the ones declared by `%expect`
+// and `%expect-rr`. If only one is declared, the other is expected to be
+// 0. Return an empty message if they match or none is declared.
+func (lemon *Lemon) unexpectedConflicts() string {
+	if lemon.expectSR < 0 && lemon.expectRR < 0 {
+		return ""
+	}
+
+	sr, rr := lemon.conflictCounts()
+	expectSR, expectRR := lemon.expectSR, lemon.expectRR
+
+	if expectSR < 0 {
+		expectSR = 0
+	}
+
+	if expectRR < 0 {
+		expectRR = 0
+	}
+
+	if sr == expectSR && rr == expectRR {
+		return ""
+	}
+
+	return fmt.Sprintf("Expect %d shift/reduce and %d reduce/reduce conflicts, actual %d and %d", expectSR, expectRR, sr, rr)
+}
+
+// Print the number of conflicts left, like lemon and yacc, the states
+// which still conflict with more tokens of lookahead, and their
+// counterexamples if they are searched. They don't stop the generator,
+// unless they are declared: then nothing is printed if the numbers
+// match, otherwise the conflicts are listed and the generator stops.
+func (lemon *Lemon) reportConflicts() {
+	if msg := lemon.unexpectedConflicts(); msg != "" {
+		fmt.Fprintf(os.Stderr, "%v: %s: %s:%v\n", SevError, msg, lemon.infile, lemon.expectPos)
+		lemon.writeConflicts(os.Stderr)
+		os.Exit(1)
+	}
+
+	if lemon.nconflict == 0 || lemon.expectSR >= 0 || lemon.expectRR >= 0 {
+		return
+	}
+
+	sr, rr := lemon.conflictCounts()
+	fmt.Fprintf(os.Stderr, "%s: %d parsing conflicts (%d shift/reduce, %d reduce/reduce).\n", lemon.infile, lemon.nconflict, sr, rr)
+
+	for _, decision := range lemon.undecided() {
+		fmt.Fprintf(os.Stderr, "    %v\n", decision)
+	}
+
+	if len(lemon.counterexamples) > 0 {
+		lemon.writeConflicts(os.Stderr)
+	}
+}
+
+// Write the conflicts, or their counterexamples if they are searched.
+func (lemon *Lemon) writeConflicts(w io.Writer) {
+	if len(lemon.counterexamples) > 0 {
+		for _, cex := range lemon.counterexamples {
+			fmt.Fprintf(w, "    %s\n", strings.ReplaceAll(cex.String(), "\n", "\n    "))
+		}
+
+		return
+	}
+
+	for _, conflict := range lemon.conflicts {
+		fmt.Fprintf(w, "    %v\n", conflict)
+	}
+}
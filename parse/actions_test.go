@@ -0,0 +1,233 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+)
+
+func buildActions(t *testing.T, name string, src string, mode LRMode) *Lemon {
+	lemon := buildMode(t, name, src, mode)
+	lemon.updateRulePrecedences()
+	lemon.findActions()
+
+	return lemon
+}
+
+// Get the action the parser takes on the lookahead, or nil.
+func actionOn(stp *State, lookahead *Symbol) *Action {
+	for i := range stp.ap {
+		ap := &stp.ap[i]
+
+		if ap.sp != lookahead {
+			continue
+		}
+
+		switch ap.actionType {
+		case Shift, Reduce, Accept, Error:
+			return ap
+		}
+	}
+
+	return nil
+}
+
+// Get the types of the actions on the lookahead, resolved ones included.
+func actionTypes(stp *State, lookahead *Symbol) []string {
+	var types []string
+
+	for _, ap := range stp.ap {
+		if ap.sp == lookahead {
+			types = append(types, ap.actionType.String())
+		}
+	}
+
+	return types
+}
+
+// Run the parser on the tokens, with the action tables.
+func run(lemon *Lemon, tokens []*Symbol) bool {
+	return runLookup(lemon, tokens, func(stp *State, lookahead *Symbol, _ []*Symbol) *Action {
+		return actionOn(stp, lookahead)
+	})
+}
+
+func tokensOf(t *testing.T, lemon *Lemon, sentence string) []*Symbol {
+	var tokens []*Symbol
+
+	for _, name := range strings.Fields(sentence) {
+		tokens = append(tokens, mustSymbol(t, lemon, name))
+	}
+
+	return tokens
+}
+
+const precGrammar = `%{
+%}
+%token NUM
+%nonassoc EQ
+%left PLUS MINUS
+%left TIMES
+%right POW
+%right UMINUS
+%%
+e: e EQ e | e PLUS e | e MINUS e | e TIMES e | e POW e | MINUS e %prec UMINUS | NUM ;
+%%
+`
+
+func TestResolveByPrecedence(t *testing.T) {
+	lemon := buildActions(t, "prec.y", precGrammar, LALR)
+
+	if lemon.ConflictCount() != 0 {
+		t.Errorf("Expect no conflict, actual %v", lemon.Conflicts())
+	}
+
+	cases := []struct {
+		path      string
+		lookahead string
+		expect    []string
+	}{
+		// Same precedence, left associative: reduce.
+		{"e MINUS e", "PLUS", []string{"shift resolved", "reduce"}},
+		// Higher precedence of the lookahead: shift.
+		{"e PLUS e", "TIMES", []string{"shift", "reduce resolved"}},
+		// Higher precedence of the rule: reduce.
+		{"e TIMES e", "PLUS", []string{"shift resolved", "reduce"}},
+		// Right associative: shift.
+		{"e POW e", "POW", []string{"shift", "reduce resolved"}},
+		// Non-associative: error.
+		{"e EQ e", "EQ", []string{"error", "reduce resolved"}},
+		// The precedence given by %prec.
+		{"MINUS e", "POW", []string{"shift resolved", "reduce"}},
+		{"e", "$", []string{"accept"}},
+	}
+
+	for _, c := range cases {
+		stp := walk(t, lemon, strings.Fields(c.path)...)
+		checkStrings(t, c.path+" on "+c.lookahead, c.expect, actionTypes(stp, mustSymbol(t, lemon, c.lookahead)))
+	}
+
+	sentences := map[string]bool{
+		"NUM":                      true,
+		"NUM PLUS NUM TIMES NUM":   true,
+		"MINUS NUM POW NUM":        true,
+		"NUM EQ NUM":               true,
+		"NUM EQ NUM EQ NUM":        false,
+		"NUM PLUS NUM EQ NUM PLUS": false,
+	}
+
+	for sentence, expect := range sentences {
+		if actual := run(lemon, tokensOf(t, lemon, sentence)); actual != expect {
+			t.Errorf("%s: expect %v, actual %v", sentence, expect, actual)
+		}
+	}
+}
+
+// All the terminals of a declaration have the same precedence, and the
+// precedence of a rule is the one of its last terminal with one.
+func TestPrecedenceLevels(t *testing.T) {
+	lemon := readGrammar(t, "levels.y", `%{
+%}
+%left A B
+%left C
+%%
+s: A s C s | B s | D ;
+%%
+`)
+	lemon.updateRulePrecedences()
+
+	a, b, c := mustSymbol(t, lemon, "A"), mustSymbol(t, lemon, "B"), mustSymbol(t, lemon, "C")
+
+	if a.precedence != b.precedence || c.precedence <= a.precedence || mustSymbol(t, lemon, "D").precedence != -1 {
+		t.Errorf("Unexpected precedences: A %d, B %d, C %d", a.precedence, b.precedence, c.precedence)
+	}
+
+	checkStrings(t, "rules", []string{"s:A s C s.[C]", "s:B s.[B]", "s:D."}, ruleStrings(lemon))
+}
+
+func TestUnresolvedConflicts(t *testing.T) {
+	lemon := buildActions(t, "ambiguous.y", `%{
+%}
+%%
+e: e PLUS e | NUM ;
+%%
+`, LALR)
+
+	conflicts := lemon.Conflicts()
+
+	if lemon.ConflictCount() != 1 || len(conflicts) != 1 {
+		t.Fatalf("Expect a conflict, actual %v", conflicts)
+	}
+
+	stp := walk(t, lemon, "e", "PLUS", "e")
+	expect := "State 4 has a shift/reduce conflict on PLUS: shift, not reduce `e:e PLUS e.`"
+
+	if stp.Index() != 4 || conflicts[0].String() != expect {
+		t.Errorf("Expect %q, actual %q", expect, conflicts[0])
+	}
+
+	checkStrings(t, "actions on PLUS", []string{"shift", "conflict"}, actionTypes(stp, mustSymbol(t, lemon, "PLUS")))
+
+	// The reduce/reduce conflicts are resolved by the order of the rules.
+	lemon = buildActions(t, "lr1.y", lr1Grammar, LALR)
+	stp = walk(t, lemon, "A", "C")
+
+	if sr, rr := lemon.conflictCounts(); sr != 0 || rr != 2 {
+		t.Errorf("Expect 2 reduce/reduce conflicts, actual %v", lemon.Conflicts())
+	}
+
+	for _, name := range []string{"D", "E"} {
+		if ap := actionOn(stp, mustSymbol(t, lemon, name)); ap == nil || ap.rp.lhs.name != "a" {
+			t.Errorf("Expect to reduce a on %s", name)
+		}
+	}
+
+	// Canonical LR(1) has none.
+	if lemon = buildActions(t, "lr1.y", lr1Grammar, Canonical); lemon.ConflictCount() != 0 {
+		t.Errorf("Expect no conflict, actual %v", lemon.Conflicts())
+	}
+}
+
+func TestUnreducedRules(t *testing.T) {
+	lemon := buildActions(t, "unreduced.y", `%{
+%}
+%%
+s: a | b ;
+a: X ;
+b: X ;
+%%
+`, LALR)
+	lemon.reportUnreducedRules()
+
+	findings := lemon.Findings()
+
+	if len(findings) != 1 || findings[0].Message != "Rule `b:X.` can never be reduced" {
+		t.Errorf("Unexpected findings: %v", findings)
+	}
+}
+
+func TestExpectedConflicts(t *testing.T) {
+	cases := []struct {
+		decls  string
+		expect string
+	}{
+		{"", ""},
+		{"%expect 1", ""},
+		{"%expect 1\n%expect-rr 0", ""},
+		{"%expect 2", "Expect 2 shift/reduce and 0 reduce/reduce conflicts, actual 1 and 0"},
+		{"%expect-rr 1", "Expect 0 shift/reduce and 1 reduce/reduce conflicts, actual 1 and 0"},
+	}
+
+	for _, c := range cases {
+		lemon := buildActions(t, "ambiguous.y", "%{\n%}\n"+c.decls+"\n%%\ne: e PLUS e | NUM ;\n%%\n", LALR)
+
+		if actual := lemon.unexpectedConflicts(); actual != c.expect {
+			t.Errorf("%q: expect %q, actual %q", c.decls, c.expect, actual)
+		}
+	}
+
+	lemon := buildActions(t, "lr1.y", "%{\n%}\n%expect-rr 2"+lr1Grammar[6:], LALR)
+
+	if msg := lemon.unexpectedConflicts(); msg != "" || lemon.expectPos.String() != "3:12" {
+		t.Errorf("Unexpected %q at %v", msg, lemon.expectPos)
+	}
+}
@@ -0,0 +1,471 @@
+package parse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The default time limit of the search of an ambiguous sentence.
+const DefaultAmbiguityTimeout = 10 * time.Second
+
+// The multiplier of the hashes of the sentences: the hash of a sentence
+// u v of terminals is hash(u) * base^len(v) + hash(v), so the hash of a
+// sentence is computed from the ones of its parts.
+const sentenceHashBase = 0x9e3779b97f4a7c15
+
+// An ambiguous sentence, and two different parse trees which derive it
+// from the start symbol.
+type Ambiguity struct {
+	Sentence []*Symbol
+	Trees    [2]*Derivation
+	Searched int // The length up to which all the sentences are searched
+}
+
+func (amb Ambiguity) String() string {
+	names := make([]string, len(amb.Sentence))
+
+	for i, symbol := range amb.Sentence {
+		names[i] = symbol.name
+	}
+
+	return fmt.Sprintf("Ambiguous sentence: %s\n  %v\n  %v", strings.Join(names, " "), amb.Trees[0], amb.Trees[1])
+}
+
+// The sentences of a length a symbol derives: the hashes of their
+// terminals, and the number of their parse trees, 2 for 2 or more.
+type sentenceSet map[uint64]uint8
+
+// A sentence of a length derived from a symbol.
+type sentenceKey struct {
+	symbol *Symbol
+	length int
+	hash   uint64
+}
+
+// The search of an ambiguous sentence. The sentences each symbol derives
+// are memoized by length, so the ones of a rule are made of the shorter
+// ones of its symbols.
+type ambiguitySearch struct {
+	lhsRules map[*Symbol][]*Rule       // The rules of each non-terminal, in order
+	order    []*Symbol                 // The non-terminals, each after the ones it derives alone
+	cyclic   bool                      // True if a non-terminal derives itself alone
+	lengths  map[*Symbol]int           // The length of the shortest sentence of each symbol
+	sets     map[*Symbol][]sentenceSet // The sentences of each symbol, by length
+	base     uint64                    // The multiplier of the hashes
+	powers   []uint64                  // The powers of the base of the hashes, by length
+	sorted   map[sentenceKey][]uint64  // The hashes of the sets, sorted to build trees in a stable order
+	onPath   map[sentenceKey]int       // The sentences whose trees are being built
+	deadline time.Time                 // The time limit, zero for none
+	steps    int                       // The sentences composed, to check the time now and then
+	timedOut bool
+}
+
+// Find an ambiguous sentence of at most maxLength terminals: the sentences
+// of each symbol are enumerated by length, with the number of their parse
+// trees, until one of the start symbol has two. The shortest one is found.
+// The number of sentences grows exponentially with their length, and so
+// does the time of the search: on example/expr.y, each token more takes
+// about 4 times longer, some 7 seconds for 15 tokens. The search stops at
+// the timeout, 0 for none, and the length of the sentences searched is
+// then shorter.
+func (lemon *Lemon) FindAmbiguity(maxLength int, timeout time.Duration) (Ambiguity, bool) {
+	return lemon.findAmbiguity(maxLength, timeout, sentenceHashBase)
+}
+
+// Find an ambiguous sentence with the hashes of the sentences computed
+// from a base.
+func (lemon *Lemon) findAmbiguity(maxLength int, timeout time.Duration, base uint64) (Ambiguity, bool) {
+	lemon.computeSets()
+	start := lemon.startSymbol()
+
+	if start == nil {
+		return Ambiguity{}, false
+	}
+
+	s := &ambiguitySearch{
+		lhsRules: make(map[*Symbol][]*Rule),
+		sets:     make(map[*Symbol][]sentenceSet),
+		base:     base,
+		powers:   []uint64{1},
+		sorted:   make(map[sentenceKey][]uint64),
+		onPath:   make(map[sentenceKey]int),
+	}
+
+	if timeout > 0 {
+		s.deadline = time.Now().Add(timeout)
+	}
+
+	_, s.lengths = lemon.shortestRules()
+
+	for rp := lemon.firstRule; rp != nil; rp = rp.next {
+		s.lhsRules[rp.lhs] = append(s.lhsRules[rp.lhs], rp)
+	}
+
+	for _, symbol := range lemon.symTable.SortedSymbols() {
+		if symbol.IsTerminal() {
+			s.sets[symbol] = []sentenceSet{nil, {uint64(symbol.index) + 1: 1}}
+		}
+	}
+
+	s.sortNonTerminals(lemon.symTable.SortedSymbols())
+
+	for length := 0; length <= maxLength; length++ {
+		// The sentences of the length are not all known once the time is
+		// up.
+		if s.addLength(length); s.timedOut {
+			return Ambiguity{Searched: length - 1}, false
+		}
+
+		if amb, ok := s.ambiguity(start, length); ok {
+			amb.Searched = length
+
+			return amb, true
+		}
+	}
+
+	return Ambiguity{Searched: maxLength}, false
+}
+
+// Check the time is up, only now and then as the sentences are composed.
+func (s *ambiguitySearch) expired() bool {
+	if s.steps++; !s.timedOut && !s.deadline.IsZero() && s.steps%4096 == 0 && time.Now().After(s.deadline) {
+		s.timedOut = true
+	}
+
+	return s.timedOut
+}
+
+// Sort the non-terminals so that the ones a non-terminal derives alone,
+// the others of the rule being nullable, come before it. Their sentences
+// of the same length are needed first.
+func (s *ambiguitySearch) sortNonTerminals(symbols []*Symbol) {
+	visits := make(map[*Symbol]int)
+	var visit func(symbol *Symbol)
+
+	visit = func(symbol *Symbol) {
+		visits[symbol] = 1
+
+		for _, rp := range s.lhsRules[symbol] {
+			rhs := rp.rhs[:rp.nrhs]
+
+			for i, other := range rhs {
+				if other.IsTerminal() || !allNullable(rhs[:i]) || !allNullable(rhs[i+1:]) {
+					continue
+				}
+
+				switch visits[other] {
+				case 0:
+					visit(other)
+				case 1:
+					s.cyclic = true
+				}
+			}
+		}
+
+		visits[symbol] = 2
+		s.order = append(s.order, symbol)
+	}
+
+	for _, symbol := range symbols {
+		if symbol.IsNonTerminal() && visits[symbol] == 0 {
+			visit(symbol)
+		}
+	}
+}
+
+// Get the sentences of the length derived from the symbol, or nil.
+func (s *ambiguitySearch) set(symbol *Symbol, length int) sentenceSet {
+	if sets := s.sets[symbol]; length < len(sets) {
+		return sets[length]
+	}
+
+	return nil
+}
+
+// Get the length of the shortest sentence the symbols derive, or -1.
+func (s *ambiguitySearch) minLength(symbols []*Symbol) int {
+	length := 0
+
+	for _, symbol := range symbols {
+		n, ok := s.lengths[symbol]
+
+		if !ok {
+			return -1
+		}
+
+		length += n
+	}
+
+	return length
+}
+
+// Find the sentences of the length of each non-terminal. A non-terminal
+// which derives itself makes the ones of the same length depend on each
+// other, they are then found again until nothing changes.
+func (s *ambiguitySearch) addLength(length int) {
+	s.powers = append(s.powers, s.powers[len(s.powers)-1]*s.base)
+
+	for _, symbol := range s.order {
+		s.sets[symbol] = append(s.sets[symbol], nil)
+	}
+
+	for changed := true; changed; changed = changed && s.cyclic {
+		changed = false
+
+		for _, symbol := range s.order {
+			set := make(sentenceSet)
+
+			for _, rp := range s.lhsRules[symbol] {
+				s.compose(rp.rhs[:rp.nrhs], length, 0, 1, set)
+			}
+
+			if s.timedOut {
+				return
+			}
+
+			if !sameSentences(set, s.sets[symbol][length]) {
+				s.sets[symbol][length] = set
+				changed = true
+			}
+		}
+	}
+}
+
+func sameSentences(x, y sentenceSet) bool {
+	if len(x) != len(y) {
+		return false
+	}
+
+	for hash, count := range x {
+		if y[hash] != count {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Add to the set the sentences of the length the symbols derive after a
+// prefix with the hash, and the number of trees of each.
+func (s *ambiguitySearch) compose(symbols []*Symbol, length int, hash uint64, count uint8, set sentenceSet) {
+	if s.expired() {
+		return
+	}
+
+	if len(symbols) == 0 {
+		if length == 0 {
+			set[hash] = addCount(set[hash], count)
+		}
+
+		return
+	}
+
+	symbol, rest := symbols[0], symbols[1:]
+	first, restLength := s.lengths[symbol], s.minLength(rest)
+
+	if restLength < 0 {
+		return
+	}
+
+	// The last symbol derives the rest of the sentence.
+	if len(rest) == 0 {
+		first = length
+	}
+
+	for n := first; n <= length-restLength; n++ {
+		for h, c := range s.set(symbol, n) {
+			s.compose(rest, length-n, hash*s.powers[n]+h, mulCount(count, c), set)
+		}
+	}
+}
+
+func addCount(x, y uint8) uint8 {
+	if x+y > 2 {
+		return 2
+	}
+
+	return x + y
+}
+
+func mulCount(x, y uint8) uint8 {
+	if x*y > 2 {
+		return 2
+	}
+
+	return x * y
+}
+
+// Get an ambiguous sentence of the length derived from the start symbol,
+// the smallest by the indexes of its terminals, if there are some. Two
+// sentences may have the same hash, so the trees of a hash are only
+// reported if they derive the same terminals.
+func (s *ambiguitySearch) ambiguity(start *Symbol, length int) (Ambiguity, bool) {
+	var best Ambiguity
+	found := false
+
+	for _, hash := range s.sortedHashes(start, length) {
+		if s.sets[start][length][hash] < 2 {
+			continue
+		}
+
+		trees := s.derive(start, length, hash, 2)
+
+		if len(trees) < 2 {
+			continue
+		}
+
+		amb := Ambiguity{Trees: [2]*Derivation{trees[0], trees[1]}}
+		leaves, others := trees[0].leaves(nil), trees[1].leaves(nil)
+
+		if !sameLeaves(leaves, others) {
+			continue
+		}
+
+		for _, leaf := range leaves {
+			amb.Sentence = append(amb.Sentence, leaf.Symbol)
+		}
+
+		if !found || lessSymbols(amb.Sentence, best.Sentence) {
+			best, found = amb, true
+		}
+	}
+
+	return best, found
+}
+
+func sameLeaves(x, y []*Derivation) bool {
+	if len(x) != len(y) {
+		return false
+	}
+
+	for i := range x {
+		if x[i].Symbol != y[i].Symbol {
+			return false
+		}
+	}
+
+	return true
+}
+
+func lessSymbols(x, y []*Symbol) bool {
+	for i := 0; i < len(x) && i < len(y); i++ {
+		if x[i] != y[i] {
+			return x[i].index < y[i].index
+		}
+	}
+
+	return len(x) < len(y)
+}
+
+// Get the hashes of the sentences of the length of the symbol, sorted.
+func (s *ambiguitySearch) sortedHashes(symbol *Symbol, length int) []uint64 {
+	key := sentenceKey{symbol, length, 0}
+
+	if hashes, ok := s.sorted[key]; ok {
+		return hashes
+	}
+
+	set := s.set(symbol, length)
+	hashes := make([]uint64, 0, len(set))
+
+	for hash := range set {
+		hashes = append(hashes, hash)
+	}
+
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	s.sorted[key] = hashes
+
+	return hashes
+}
+
+// Build at most `limit` parse trees of the sentence of the length and hash
+// derived from the symbol. A sentence a non-terminal derives through
+// itself is derived once more at most.
+func (s *ambiguitySearch) derive(symbol *Symbol, length int, hash uint64, limit int) []*Derivation {
+	if symbol.IsTerminal() {
+		return []*Derivation{{Symbol: symbol}}
+	}
+
+	key := sentenceKey{symbol, length, hash}
+
+	if s.onPath[key] > 1 {
+		return nil
+	}
+
+	s.onPath[key]++
+	defer func() { s.onPath[key]-- }()
+	var trees []*Derivation
+
+	for _, rp := range s.lhsRules[symbol] {
+		s.split(rp.rhs[:rp.nrhs], length, hash, nil, func(parts []sentenceKey) bool {
+			children := make([][]*Derivation, len(parts))
+
+			for i, part := range parts {
+				if children[i] = s.derive(part.symbol, part.length, part.hash, limit); len(children[i]) == 0 {
+					return false
+				}
+			}
+
+			// The first trees of the parts, then another one of a part.
+			for i := -1; i < len(parts) && len(trees) < limit; i++ {
+				if i >= 0 && len(children[i]) < 2 {
+					continue
+				}
+
+				d := &Derivation{Symbol: symbol, Rule: rp}
+
+				for j := range parts {
+					if j == i {
+						d.Children = append(d.Children, children[j][1])
+					} else {
+						d.Children = append(d.Children, children[j][0])
+					}
+				}
+
+				trees = append(trees, d)
+			}
+
+			return len(trees) >= limit
+		})
+
+		if len(trees) >= limit {
+			break
+		}
+	}
+
+	return trees
+}
+
+// Call f with each way the symbols derive the sentence of the length and
+// hash, as the sentences of its parts, until it returns true.
+func (s *ambiguitySearch) split(symbols []*Symbol, length int, hash uint64, parts []sentenceKey, f func([]sentenceKey) bool) bool {
+	if len(symbols) == 0 {
+		return length == 0 && hash == 0 && f(parts)
+	}
+
+	symbol, rest := symbols[0], symbols[1:]
+
+	if len(rest) == 0 {
+		if _, ok := s.set(symbol, length)[hash]; !ok {
+			return false
+		}
+
+		return f(append(parts, sentenceKey{symbol, length, hash}))
+	}
+
+	restLength := s.minLength(rest)
+
+	for n := s.lengths[symbol]; restLength >= 0 && n <= length-restLength; n++ {
+		for _, h := range s.sortedHashes(symbol, n) {
+			part := sentenceKey{symbol, n, h}
+
+			if s.split(rest, length-n, hash-h*s.powers[length-n], append(parts, part), f) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
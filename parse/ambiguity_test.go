@@ -0,0 +1,106 @@
+package parse
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Check the ambiguity found in the grammar: its sentence, and two different
+// trees which derive it.
+func checkAmbiguity(t *testing.T, name string, src string, maxLength int, sentence string, trees ...string) {
+	lemon := readGrammar(t, name, src)
+	amb, ok := lemon.FindAmbiguity(maxLength, 0)
+
+	if !ok {
+		t.Fatalf("%s: expect the ambiguous sentence %s", name, sentence)
+	}
+
+	var leaves [2][]string
+
+	for i, tree := range amb.Trees {
+		for _, leaf := range tree.leaves(nil) {
+			leaves[i] = append(leaves[i], leaf.Symbol.name)
+		}
+	}
+
+	checkStrings(t, name+" sentence", strings.Fields(sentence), symbolNames(amb.Sentence))
+	checkStrings(t, name+" first tree", strings.Fields(sentence), leaves[0])
+	checkStrings(t, name+" second tree", strings.Fields(sentence), leaves[1])
+	checkStrings(t, name+" trees", trees, []string{amb.Trees[0].String(), amb.Trees[1].String()})
+}
+
+func TestAmbiguityExpressions(t *testing.T) {
+	src, err := os.ReadFile("../example/ambiguous.y")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkAmbiguity(t, "ambiguous.y", string(src), 15, "'-' NUMBER '*' NUMBER",
+		"exp ::= [ exp ::= [ '-' exp ::= [ NUMBER ] ] '*' exp ::= [ NUMBER ] ]",
+		"exp ::= [ '-' exp ::= [ exp ::= [ NUMBER ] '*' exp ::= [ NUMBER ] ] ]")
+}
+
+func TestAmbiguityDanglingElse(t *testing.T) {
+	checkAmbiguity(t, "else.y", `%%
+s: IF E s | IF E s ELSE s | X ;
+%%
+`, 10, "IF E IF E X ELSE X",
+		"s ::= [ IF E s ::= [ IF E s ::= [ X ] ELSE s ::= [ X ] ] ]",
+		"s ::= [ IF E s ::= [ IF E s ::= [ X ] ] ELSE s ::= [ X ] ]")
+}
+
+// Empty sentences and a non-terminal which derives itself.
+func TestAmbiguityNullableAndCycle(t *testing.T) {
+	checkAmbiguity(t, "nullable.y", `%%
+s: a b X ;
+a: | Y ;
+b: | Y ;
+%%
+`, 10, "Y X",
+		"s ::= [ a ::= [ ε ] b ::= [ Y ] X ]",
+		"s ::= [ a ::= [ Y ] b ::= [ ε ] X ]")
+
+	checkAmbiguity(t, "cycle.y", `%%
+s: X | a ;
+a: s ;
+%%
+`, 10, "X",
+		"s ::= [ X ]",
+		"s ::= [ a ::= [ s ::= [ X ] ] ]")
+}
+
+// The conflicts of a parser don't make its grammar ambiguous.
+func TestNoAmbiguity(t *testing.T) {
+	for name, src := range map[string]string{"expr.y": exprGrammar, "lr1.y": lr1Grammar} {
+		if amb, ok := readGrammar(t, name, src).FindAmbiguity(10, 0); ok {
+			t.Errorf("%s: unexpected %v", name, amb)
+		}
+	}
+}
+
+// With a base of 1, the hash of a sentence is the sum of its terminals:
+// `A B` and `B A` have the same, but they are not the same sentence.
+func TestAmbiguityHashCollision(t *testing.T) {
+	lemon := readGrammar(t, "swap.y", "%%\ns: A B | B A ;\n")
+
+	if amb, ok := lemon.findAmbiguity(4, 0, 1); ok {
+		t.Errorf("swap.y: unexpected %v", amb)
+	}
+}
+
+// The search stops at the time limit, with the sentences of the lengths
+// searched until then.
+func TestAmbiguityTimeout(t *testing.T) {
+	lemon := readGrammar(t, "expr.y", exprGrammar)
+
+	if amb, ok := lemon.FindAmbiguity(30, 50*time.Millisecond); ok || amb.Searched >= 30 {
+		t.Errorf("expr.y: expect the time limit before 30 tokens, actual %d", amb.Searched)
+	}
+
+	if amb, ok := lemon.FindAmbiguity(4, 0); ok || amb.Searched != 4 {
+		t.Errorf("expr.y: expect all the sentences of 4 tokens searched, actual %d", amb.Searched)
+	}
+}
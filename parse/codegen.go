@@ -0,0 +1,264 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/golemon/util"
+)
+
+var packageClause = regexp.MustCompile(`(?m)^package\s+\w+`)
+
+// Get the terminals in the order of their token codes: the code of
+// terminals[i] is i. The end of input `$` has index 0, so its code is 0.
+func (symTable *SymbolTable) terminals() []*Symbol {
+	var terminals []*Symbol
+
+	for _, symbol := range symTable.SortedSymbols() {
+		if symbol.IsTerminal() {
+			terminals = append(terminals, symbol)
+		}
+	}
+
+	return terminals
+}
+
+// Write the generated Go file into the output file.
+func (lemon *Lemon) Generate() {
+	var buf bytes.Buffer
+	lemon.WriteGo(&buf)
+
+	if err := os.WriteFile(lemon.outfile, buf.Bytes(), 0644); err != nil {
+		errorf(lemon.outfile, Position{}, "Fail to write: %v", err)
+	}
+}
+
+// Write the generated Go code: the code inside `%{ %}`, the token codes
+// and the descriptions of the symbols used in syntax error messages.
+// Doc comments of the grammar become doc comments of the token constants.
+func (lemon *Lemon) WriteGo(w io.Writer) error {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "// Code generated by golemon from %s. DO NOT EDIT.\n\n", filepath.Base(lemon.infile))
+
+	if !packageClause.MatchString(lemon.include) {
+		buf.WriteString("package main\n\n")
+	}
+
+	if include := strings.TrimSpace(lemon.include); include != "" {
+		buf.WriteString(include + "\n\n")
+	}
+
+	buf.WriteString("// Token codes returned by the lexer.\nconst (\n")
+	prefix := lemon.prefix()
+
+	for i, symbol := range lemon.symTable.terminals() {
+		if symbol.name == EndSymbolName {
+			writeDoc(&buf, symbol.doc, "\t")
+			fmt.Fprintf(&buf, "\t%sEOF = %d\n", prefix, i)
+			continue
+		}
+
+		if strings.HasPrefix(symbol.name, "\"") {
+			fmt.Fprintf(&buf, "\t// %s.\n\t%s = %d\n", symbol.name, lemon.literalConstName(symbol), i)
+			continue
+		}
+
+		if util.IsStringLiteral(symbol.name) {
+			continue
+		}
+
+		writeDoc(&buf, symbol.doc, "\t")
+		fmt.Fprintf(&buf, "\t%s%s = %d\n", lemon.tokenPrefix, symbol.name, i)
+	}
+
+	buf.WriteString(")\n\n")
+	lemon.writeRuleIDs(&buf)
+
+	fmt.Fprintf(&buf, "// Descriptions of the documented symbols used in syntax error messages.\nvar %sSymbolHints = map[string]string{\n", prefix)
+
+	for _, symbol := range lemon.symTable.SortedSymbols() {
+		if symbol.doc != "" {
+			fmt.Fprintf(&buf, "\t%s: %s,\n", strconv.Quote(symbol.name), strconv.Quote(symbol.Hint()))
+		}
+	}
+
+	buf.WriteString("}\n\n")
+	fmt.Fprintf(&buf, expectedFunc, prefix)
+
+	if lemon.ll1 {
+		lemon.writeDescent(&buf)
+	} else if lemon.glr {
+		lemon.writeGLR(&buf)
+	} else {
+		lemon.writeLR(&buf)
+	}
+
+	// Code from the grammar may not be valid on its own, so the result is
+	// only formatted if it parses.
+	src := buf.Bytes()
+
+	if formatted, err := format.Source(src); err == nil {
+		src = formatted
+	}
+
+	_, err := w.Write(src)
+
+	return err
+}
+
+// Get the name of the RuleID constant of a labelled rule, e.g. RuleExprAdd
+// for `expr: expr '+' expr #Add`.
+func (rule *Rule) constName() string {
+	var buf strings.Builder
+	buf.WriteString("Rule")
+
+	for _, word := range strings.Split(rule.lhs.name, "_") {
+		if r, n := utf8.DecodeRuneInString(word); n > 0 {
+			buf.WriteRune(unicode.ToUpper(r))
+			buf.WriteString(word[n:])
+		}
+	}
+
+	buf.WriteString(rule.label)
+
+	return buf.String()
+}
+
+// Write the RuleID type, which is the index of a rule, a constant for each
+// labelled rule, and the names of the rules by index.
+func (lemon *Lemon) writeRuleIDs(buf *bytes.Buffer) {
+	buf.WriteString("// RuleID identifies a rule of the grammar by its index. The labelled rules\n" +
+		"// have a constant.\ntype RuleID int\n\n")
+
+	if lemon.hasLabels() {
+		buf.WriteString("const (\n")
+
+		for rule := lemon.firstRule; rule != nil; rule = rule.next {
+			if rule.label != "" {
+				writeDoc(buf, rule.doc, "\t")
+				fmt.Fprintf(buf, "\t%s RuleID = %d // %s ::= %s\n", rule.constName(), rule.index, rule.lhs.name, rule.rhsString())
+			}
+		}
+
+		buf.WriteString(")\n\n")
+	}
+
+	prefix := lemon.prefix()
+	fmt.Fprintf(buf, "// The names of the rules by RuleID: `lhs#Label` for the labelled ones,\n"+
+		"// else `lhs ::= rhs`.\nvar %sRuleNames = []string{\n", prefix)
+
+	for rule := lemon.firstRule; rule != nil; rule = rule.next {
+		fmt.Fprintf(buf, "\t%d: %s,\n", rule.index, strconv.Quote(rule.name()))
+	}
+
+	fmt.Fprintf(buf, "}\n\n// Get the name of the rule.\nfunc (id RuleID) String() string {\n"+
+		"\tif id < 0 || int(id) >= len(%[1]sRuleNames) {\n\t\treturn \"unknown rule\"\n\t}\n\n"+
+		"\treturn %[1]sRuleNames[id]\n}\n\n", prefix)
+}
+
+// Check some rule has a label.
+func (lemon *Lemon) hasLabels() bool {
+	for rule := lemon.firstRule; rule != nil; rule = rule.next {
+		if rule.label != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get the name of the rule in the generated code: `lhs#Label` if it has a
+// label, else `lhs ::= rhs`.
+func (rule *Rule) name() string {
+	if rule.label != "" {
+		return rule.lhs.name + "#" + rule.label
+	}
+
+	return rule.lhs.name + " ::= " + rule.rhsString()
+}
+
+// Get the name of the token constant of a string literal: `"if"` is
+// LIT_if, and the other characters are written by their code, `"+="` is
+// LIT_2B_3D.
+func (lemon *Lemon) literalConstName(symbol *Symbol) string {
+	text, err := strconv.Unquote(symbol.name)
+
+	if err != nil {
+		text = symbol.name[1 : len(symbol.name)-1]
+	}
+
+	var b strings.Builder
+	b.WriteString(lemon.tokenPrefix + "LIT")
+	word := false
+
+	for _, r := range text {
+		switch {
+		case r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9':
+			if !word {
+				b.WriteString("_")
+			}
+
+			b.WriteRune(r)
+			word = true
+		default:
+			fmt.Fprintf(&b, "_%X", r)
+			word = false
+		}
+	}
+
+	return b.String()
+}
+
+// The prefix of the names in the generated code.
+func (lemon *Lemon) prefix() string {
+	if lemon.name != "" {
+		return lemon.name
+	}
+
+	return "yy"
+}
+
+// Write a doc comment with each line indented by `indent`.
+func writeDoc(buf *bytes.Buffer, doc string, indent string) {
+	if doc == "" {
+		return
+	}
+
+	for _, line := range strings.Split(doc, "\n") {
+		buf.WriteString(strings.TrimRight(indent+"// "+line, " ") + "\n")
+	}
+}
+
+// Mirrors ExpectedHint for the generated parser.
+const expectedFunc = `// Build the message of a syntax error from the names of the expected symbols.
+func %[1]sExpected(names ...string) string {
+	msg := "syntax error"
+
+	for i, name := range names {
+		if hint, ok := %[1]sSymbolHints[name]; ok {
+			name = hint
+		}
+
+		switch {
+		case i == 0:
+			msg += ": expected " + name
+		case i == len(names)-1:
+			msg += " or " + name
+		default:
+			msg += ", " + name
+		}
+	}
+
+	return msg
+}
+`
@@ -0,0 +1,276 @@
+package parse
+
+import "sort"
+
+// The offset of a state without actions on terminals or non-terminals.
+const noOffset = -2147483647
+
+// An entry of the packed action table, yy_action and yy_lookahead in
+// lemon: an action and the lookahead it is taken on. The lookahead of an
+// empty entry is -1.
+type tableEntry struct {
+	lookahead int
+	action    int
+}
+
+// The packed action table. The actions of a state on the terminals, and
+// the ones on the non-terminals, are each put as a row at an offset where
+// its entries don't overlap the others. A row the same as one put before
+// shares its entries. The action of a state on a lookahead is at the
+// offset of its row plus the lookahead, if the entry there is for it.
+type actionTable struct {
+	entries      []tableEntry
+	row          []tableEntry // The row being added
+	minLookahead int          // The smallest lookahead of the row
+	minAction    int          // The action on it
+}
+
+// Add an action to the row being added.
+func (tab *actionTable) add(lookahead int, action int) {
+	if len(tab.row) == 0 || lookahead < tab.minLookahead {
+		tab.minLookahead, tab.minAction = lookahead, action
+	}
+
+	tab.row = append(tab.row, tableEntry{lookahead, action})
+}
+
+// Get the entry at an index, empty past the end.
+func (tab *actionTable) at(k int) tableEntry {
+	if k < len(tab.entries) {
+		return tab.entries[k]
+	}
+
+	return tableEntry{-1, -1}
+}
+
+// Put the row in the table and return its offset: at the one of the same
+// row if there is one, else at the first offset where its entries are
+// empty.
+func (tab *actionTable) insert() int {
+	i := len(tab.entries) - 1
+
+	for ; i >= 0; i-- {
+		if tab.entries[i] == (tableEntry{tab.minLookahead, tab.minAction}) && tab.fits(i, true) {
+			break
+		}
+	}
+
+	if i < 0 {
+		for i = 0; !tab.fits(i, false); i++ {
+		}
+	}
+
+	offset := i - tab.minLookahead
+
+	for _, entry := range tab.row {
+		k := offset + entry.lookahead
+
+		for len(tab.entries) <= k {
+			tab.entries = append(tab.entries, tableEntry{-1, -1})
+		}
+
+		tab.entries[k] = entry
+	}
+
+	tab.row = tab.row[:0]
+
+	return offset
+}
+
+// Check the row can be put with its smallest lookahead at an index: on
+// the same entries, or on empty ones. The entries of the table read at
+// this offset must be the ones of the row, so that the lookaheads which
+// are not in the row find no action.
+func (tab *actionTable) fits(i int, same bool) bool {
+	offset := i - tab.minLookahead
+
+	for _, entry := range tab.row {
+		k := offset + entry.lookahead
+
+		switch {
+		case k < 0:
+			return false
+		case same && tab.at(k) != entry:
+			return false
+		case !same && tab.at(k).lookahead >= 0:
+			return false
+		}
+	}
+
+	n := 0
+
+	for j, entry := range tab.entries {
+		if entry.lookahead >= 0 && entry.lookahead == j-offset {
+			n++
+		}
+	}
+
+	if same {
+		return n == len(tab.row)
+	}
+
+	return n == 0
+}
+
+// Get the code of an action in the packed table, as in lemon: the state
+// of a shift or a goto, the number of states plus the rule of a reduce,
+// then the error and the accept. -1 if the action isn't in the table.
+func (lemon *Lemon) actionCode(ap *Action) int {
+	switch ap.actionType {
+	case Shift:
+		return ap.stp.index
+	case Reduce:
+		return lemon.nstate + ap.rp.index
+	case Error:
+		return lemon.errorAction()
+	case Accept:
+		return lemon.nstate + lemon.nrule + 1
+	}
+
+	return -1
+}
+
+// Get the code of the error action.
+func (lemon *Lemon) errorAction() int {
+	return lemon.nstate + lemon.nrule
+}
+
+// Make the reduce a state does on the most lookaheads its default action,
+// taken on the lookaheads without an action: the reduces by its rule are
+// no longer used. The error of such a lookahead is then found after the
+// reduces, before the next shift. The default action of the other states
+// is the error.
+func (lemon *Lemon) compressTables() {
+	for _, stp := range lemon.sortedState {
+		counts := make(map[*Rule]int)
+		var best *Rule
+
+		for _, ap := range stp.ap {
+			if ap.actionType != Reduce {
+				continue
+			}
+
+			counts[ap.rp]++
+
+			if best == nil || counts[ap.rp] > counts[best] || counts[ap.rp] == counts[best] && ap.rp.index < best.index {
+				best = ap.rp
+			}
+		}
+
+		stp.iDefAction = lemon.errorAction()
+
+		if best == nil {
+			continue
+		}
+
+		stp.iDefAction = lemon.nstate + best.index
+
+		for i := range stp.ap {
+			if ap := &stp.ap[i]; ap.actionType == Reduce && ap.rp == best {
+				ap.actionType = NotUsed
+			}
+		}
+	}
+}
+
+// Get the lookahead of each symbol in the packed table, by index: the
+// token code of a terminal, and the non-terminals after the terminals.
+func (lemon *Lemon) tableLookaheads() []int {
+	symbols := lemon.symTable.SortedSymbols()
+	lookaheads := make([]int, len(symbols))
+	terminals, nonTerminals := 0, lemon.symTable.TerminalCount()
+
+	for _, symbol := range symbols {
+		if symbol.IsTerminal() {
+			lookaheads[symbol.index] = terminals
+			terminals++
+		} else {
+			lookaheads[symbol.index] = nonTerminals
+			nonTerminals++
+		}
+	}
+
+	return lookaheads
+}
+
+// Pack the actions of the states, once compressed, into one table. The
+// rows with the most actions are put first, where there is the most
+// room. Set the offsets of the rows of each state and the size of the
+// table.
+func (lemon *Lemon) packTables() {
+	lemon.lookaheads = lemon.tableLookaheads()
+
+	type row struct {
+		stp      *State
+		terminal bool
+		n        int
+	}
+
+	var rows []row
+
+	for _, stp := range lemon.sortedState {
+		stp.nTknAct, stp.nNtAct = 0, 0
+
+		for i := range stp.ap {
+			switch ap := &stp.ap[i]; {
+			case lemon.actionCode(ap) < 0:
+			case ap.sp.IsTerminal():
+				stp.nTknAct++
+			default:
+				stp.nNtAct++
+			}
+		}
+
+		rows = append(rows, row{stp, true, stp.nTknAct}, row{stp, false, stp.nNtAct})
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].n > rows[j].n })
+	tab := &actionTable{}
+
+	for _, r := range rows {
+		offset := noOffset
+
+		if r.n > 0 {
+			for i := range r.stp.ap {
+				if ap := &r.stp.ap[i]; ap.sp.IsTerminal() == r.terminal && lemon.actionCode(ap) >= 0 {
+					tab.add(lemon.lookaheads[ap.sp.index], lemon.actionCode(ap))
+				}
+			}
+
+			offset = tab.insert()
+		}
+
+		if r.terminal {
+			r.stp.iTknOffset = offset
+		} else {
+			r.stp.iNtOfst = offset
+		}
+	}
+
+	lemon.actionTable = tab.entries
+	lemon.tableSize = len(tab.entries)
+}
+
+// Get the code of the action of the state on the symbol in the packed
+// table, or the default action of the state. On a non-terminal, it is the
+// state of the goto.
+func (lemon *Lemon) lookupAction(stp *State, sp *Symbol) int {
+	offset, lookahead := stp.iTknOffset, lemon.lookaheads[sp.index]
+
+	if sp.IsNonTerminal() {
+		offset = stp.iNtOfst
+	}
+
+	if offset != noOffset {
+		if k := offset + lookahead; k >= 0 && k < len(lemon.actionTable) && lemon.actionTable[k].lookahead == lookahead {
+			return lemon.actionTable[k].action
+		}
+	}
+
+	return stp.iDefAction
+}
+
+// Get the number of entries of the packed action table.
+func (lemon *Lemon) TableSize() int {
+	return lemon.tableSize
+}
@@ -0,0 +1,119 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+)
+
+// Compress and pack the tables of the grammar. Return the codes of the
+// actions of each state on each symbol before, -1 if there is none.
+func compressActions(t *testing.T, name string, src string) (*Lemon, [][]int) {
+	lemon := buildActions(t, name, src, LALR)
+	symbols := lemon.symTable.SortedSymbols()
+	uncompressed := make([][]int, len(lemon.sortedState))
+
+	for i, stp := range lemon.sortedState {
+		uncompressed[i] = make([]int, len(symbols))
+
+		for j := range uncompressed[i] {
+			uncompressed[i][j] = -1
+		}
+
+		for j := range stp.ap {
+			if code := lemon.actionCode(&stp.ap[j]); code >= 0 {
+				uncompressed[i][stp.ap[j].sp.index] = code
+			}
+		}
+	}
+
+	lemon.compressTables()
+	lemon.packTables()
+
+	return lemon, uncompressed
+}
+
+func TestCompressTables(t *testing.T) {
+	for _, test := range []struct{ name, src string }{
+		{"expr.y", exprGrammar},
+		{"calc.y", calcGrammar},
+		{"else.y", elseGrammar},
+		{"prec.y", `%nonassoc EQ
+%left PLUS
+%%
+e: e EQ e | e PLUS e | NUM ;
+%%
+`},
+	} {
+		lemon, uncompressed := compressActions(t, test.name, test.src)
+		size := 0
+
+		for i, stp := range lemon.sortedState {
+			defaultReduce := stp.iDefAction != lemon.errorAction()
+
+			for _, symbol := range lemon.symTable.SortedSymbols() {
+				expect, actual := uncompressed[i][symbol.index], lemon.lookupAction(stp, symbol)
+
+				// Without an action, the state reduces by default, or the
+				// lookahead is an error.
+				if expect < 0 && symbol.IsTerminal() {
+					expect = stp.iDefAction
+				}
+
+				if expect >= 0 && actual != expect {
+					t.Errorf("%s: state %d on %s: expect %d, actual %d", test.name, i, symbol.name, expect, actual)
+				}
+			}
+
+			for _, ap := range stp.ap {
+				if ap.actionType == NotUsed && (!defaultReduce || lemon.nstate+ap.rp.index != stp.iDefAction) {
+					t.Errorf("%s: state %d: the reduce on %s is not the default", test.name, i, ap.sp.name)
+				}
+			}
+
+			size += stp.nTknAct + stp.nNtAct
+		}
+
+		if lemon.TableSize() > size {
+			t.Errorf("%s: expect at most %d entries, actual %d", test.name, size, lemon.TableSize())
+		}
+	}
+
+	lemon, _ := compressActions(t, "expr.y", exprGrammar)
+
+	if lemon.TableSize() != 18 {
+		t.Errorf("expr.y: expect 18 entries, actual %d", lemon.TableSize())
+	}
+}
+
+// Run the packed table on the tokens.
+func acceptsPacked(lemon *Lemon, tokens []*Symbol) bool {
+	return runLookup(lemon, tokens, func(stp *State, lookahead *Symbol, _ []*Symbol) *Action {
+		code := lemon.lookupAction(stp, lookahead)
+
+		switch {
+		case code < lemon.nstate:
+			return &Action{sp: lookahead, actionType: Shift, stp: lemon.sortedState[code]}
+		case code < lemon.errorAction():
+			return &Action{sp: lookahead, actionType: Reduce, rp: lemon.Rules()[code-lemon.nstate]}
+		case code == lemon.errorAction()+1:
+			return &Action{sp: lookahead, actionType: Accept}
+		}
+
+		return nil
+	})
+}
+
+func TestPackedSameLanguage(t *testing.T) {
+	lemon, _ := compressActions(t, "expr.y", exprGrammar)
+	var terminals []*Symbol
+
+	for _, name := range []string{"PLUS", "TIMES", "LPAREN", "RPAREN", "ID"} {
+		terminals = append(terminals, mustSymbol(t, lemon, name))
+	}
+
+	eachSentence(terminals, 5, func(tokens []*Symbol) {
+		if acceptsPacked(lemon, tokens) != accepts(lemon, tokens) {
+			t.Errorf("expr.y: %s", strings.Join(symbolNames(tokens), " "))
+		}
+	})
+}
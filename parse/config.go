@@ -1,5 +1,11 @@
 package parse
 
+import (
+	"bytes"
+
+	"github.com/golemon/util"
+)
+
 // A followset propagation link indicates that the contents of one
 // configuration followset should be propagated to another whenever
 // the first changes.
@@ -14,10 +20,56 @@ type PLink struct {
 // symbols which are allowed to immediately follow the end of the rule.
 // Every configuration is recorded as an instance of the following:
 type Config struct {
-	rp   *Rule  // The rule upon which the configuration is based
-	dot  int    // The parse point
-	fws  string // Follow-set for this configuration only
-	fplp *PLink // Follow-set forward propagation links
-	bplp *PLink // Follow-set backward propagation links
+	rp         *Rule       // The rule upon which the configuration is based
+	dot        int         // The parse point
+	fws        util.BitSet // Follow-set for this configuration only, by symbol index
+	fplp       *PLink      // Follow-set forward propagation links
+	bplp       *PLink      // Follow-set backward propagation links
+	stp        *State      // The state which contains this configuration
+	incomplete bool        // True if fws has changed since it was last propagated
+}
+
+// Add a propagation link to the configuration at the head of the list.
+func addPLink(plpp **PLink, cfp *Config) {
+	*plpp = &PLink{cfp: cfp, next: *plpp}
+}
+
+// Get the rule of the configuration.
+func (cfp *Config) Rule() *Rule {
+	return cfp.rp
+}
+
+// Get the number of symbols of the rule before the dot.
+func (cfp *Config) Dot() int {
+	return cfp.dot
+}
+
+// Get the symbol after the dot, or nil if the dot is at the end of the rule.
+func (cfp *Config) next() *Symbol {
+	if cfp.dot < cfp.rp.nrhs {
+		return cfp.rp.rhs[cfp.dot]
+	}
+
+	return nil
+}
+
+// Get a string representation of the configuration, like `expr ::= expr * PLUS NUM`.
+func (cfp *Config) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(cfp.rp.lhs.name)
+	buf.WriteString(" ::=")
+
+	for i, symbol := range cfp.rp.rhs[:cfp.rp.nrhs] {
+		if i == cfp.dot {
+			buf.WriteString(" *")
+		}
+
+		buf.WriteString(" " + symbol.name)
+	}
+
+	if cfp.dot == cfp.rp.nrhs {
+		buf.WriteString(" *")
+	}
 
+	return buf.String()
 }
@@ -0,0 +1,762 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golemon/util"
+)
+
+// The time limit of the search of a unifying counterexample of a
+// conflict, used by the command line unless another one is given.
+const DefaultCounterexampleTimeout = 5 * time.Second
+
+// How much longer than the shortest ones the contexts of the conflicting
+// configurations may be, and how many symbols may be expanded to make
+// two examples the same.
+const (
+	maxExtraContext = 8
+	maxExpansions   = 4
+)
+
+// A derivation of a symbol: a leaf if it has no rule, otherwise the
+// derivations of the right hand side of the rule. The point of the
+// conflict in an example is a leaf without symbol.
+type Derivation struct {
+	Symbol   *Symbol
+	Rule     *Rule
+	Children []*Derivation
+}
+
+func (d *Derivation) String() string {
+	if d.Symbol == nil {
+		return "•"
+	}
+
+	if d.Rule == nil {
+		return d.Symbol.name
+	}
+
+	if len(d.Children) == 0 {
+		return d.Symbol.name + " ::= [ ε ]"
+	}
+
+	return d.Symbol.name + " ::= [ " + derivationsString(d.Children) + " ]"
+}
+
+func derivationsString(derivations []*Derivation) string {
+	texts := make([]string, len(derivations))
+
+	for i, d := range derivations {
+		texts[i] = d.String()
+	}
+
+	return strings.Join(texts, " ")
+}
+
+// Append the leaves of the derivation, the point of the conflict included.
+func (d *Derivation) leaves(leaves []*Derivation) []*Derivation {
+	if d.Rule == nil {
+		return append(leaves, d)
+	}
+
+	for _, child := range d.Children {
+		leaves = child.leaves(leaves)
+	}
+
+	return leaves
+}
+
+// Get the sentential form derived, like `exp '+' exp • '+' exp`.
+func (d *Derivation) Example() string {
+	return derivationsString(d.leaves(nil))
+}
+
+// Examples of a conflict, like the counterexamples of bison: the shortest
+// sequence of symbols reaching the state, then a derivation of the start
+// symbol for each action, which reaches the state with the lookahead
+// next. If both derive the same example, it proves the grammar is
+// ambiguous, otherwise they show the contexts of each action.
+type Counterexample struct {
+	Conflict    ParseConflict
+	Prefix      []*Symbol      // The shortest sequence of symbols reaching the state
+	Derivations [2]*Derivation // For the action taken and the dropped one, from the start symbol
+	Unifying    bool           // True if both derive the same example
+	TimedOut    bool           // True if the search of a unifying example ran out of time
+	NoLookahead bool           // True if the lookahead follows a rule reduced in no LR(1) context, as in SLR(1) states
+	NotFound    bool           // True if an action has no context at all
+}
+
+func (cex Counterexample) String() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "%v\n", cex.Conflict)
+	buf.WriteString("  Prefix:")
+
+	for _, symbol := range cex.Prefix {
+		buf.WriteString(" " + symbol.name)
+	}
+
+	actions := [2]*Action{cex.Conflict.Taken, cex.Conflict.Dropped}
+
+	if cex.NotFound {
+		buf.WriteString("\n  No counterexample found")
+
+		return buf.String()
+	}
+
+	if cex.Unifying {
+		fmt.Fprintf(&buf, "\n  Ambiguous example: %s", cex.Derivations[0].Example())
+
+		for i, d := range cex.Derivations {
+			fmt.Fprintf(&buf, "\n  Derivation to %s: %s", actionLabel(actions[i]), d)
+		}
+
+		return buf.String()
+	}
+
+	for i, d := range cex.Derivations {
+		fmt.Fprintf(&buf, "\n  Example to %s: %s", actionLabel(actions[i]), d.Example())
+		fmt.Fprintf(&buf, "\n    %s", d)
+	}
+
+	switch {
+	case cex.NoLookahead:
+		fmt.Fprintf(&buf, "\n  No unifying example: no LR(1) context of the reduce is followed by %s", cex.Conflict.Lookahead.name)
+	case cex.TimedOut:
+		buf.WriteString("\n  No unifying example found before the time limit")
+	default:
+		buf.WriteString("\n  No unifying example found")
+	}
+
+	return buf.String()
+}
+
+func actionLabel(ap *Action) string {
+	if ap.actionType == Shift || ap.actionType == Accept {
+		return ap.actionType.String()
+	}
+
+	return fmt.Sprintf("reduce `%v`", ap.rp)
+}
+
+// A node of the graph searched for the contexts of a configuration: the
+// configuration, and whether the lookahead of the conflict can follow
+// its rule.
+type contextNode struct {
+	cfp       *Config
+	lookahead bool
+}
+
+// The search of the counterexamples of a conflict.
+type cexSearch struct {
+	lemon      *Lemon
+	lookahead  *Symbol
+	deadline   time.Time
+	timedOut   bool
+	successors map[*Config]*Config  // The configuration each one becomes after its next symbol
+	startRules map[*Symbol]ruleAt   // How to derive a string starting with the lookahead
+	shortest   map[*Symbol]*Rule    // How to derive the shortest string, empty if nullable
+	onPath     map[contextNode]bool // The nodes of the path being enumerated
+}
+
+// A rule, and the position in its right hand side of the symbol which
+// derives the string.
+type ruleAt struct {
+	rp  *Rule
+	pos int
+}
+
+// Set the time limit of the search of a unifying counterexample for each
+// conflict. The counterexamples are computed once the actions are built,
+// and reported with the conflicts. 0, the default, disables them.
+func (lemon *Lemon) SetCounterexampleTimeout(timeout time.Duration) {
+	lemon.cexTimeout = timeout
+}
+
+// Get the counterexamples of the conflicts, computed if there is a time
+// limit.
+func (lemon *Lemon) Counterexamples() []Counterexample {
+	return lemon.counterexamples
+}
+
+// Compute the counterexamples of the conflicts, if there is a time limit.
+func (lemon *Lemon) findCounterexamples() {
+	lemon.counterexamples = nil
+
+	if lemon.cexTimeout <= 0 {
+		return
+	}
+
+	for _, conflict := range lemon.conflicts {
+		lemon.counterexamples = append(lemon.counterexamples, lemon.Counterexample(conflict, lemon.cexTimeout))
+	}
+}
+
+// Find the counterexample of a conflict. The shortest derivations of both
+// actions are always found, a unifying example is searched among longer
+// ones until the timeout.
+func (lemon *Lemon) Counterexample(conflict ParseConflict, timeout time.Duration) Counterexample {
+	s := &cexSearch{
+		lemon:      lemon,
+		lookahead:  conflict.Lookahead,
+		deadline:   time.Now().Add(timeout),
+		successors: make(map[*Config]*Config),
+		onPath:     make(map[contextNode]bool),
+	}
+	s.findStartRules()
+	s.shortest, _ = lemon.shortestRules()
+
+	cex := Counterexample{Conflict: conflict, Prefix: lemon.shortestPrefix(conflict.State)}
+	targets := [2]func(contextNode) bool{
+		s.actionTarget(conflict.State, conflict.Taken),
+		s.actionTarget(conflict.State, conflict.Dropped),
+	}
+	shifts := [2]bool{conflict.Taken.actionType == Shift, false}
+	var paths [2][][]contextNode
+
+	for i, target := range targets {
+		path, ok := s.shortestPath(target)
+
+		// The lookahead of a reduce of an SLR(1) state may follow its rule
+		// in no LR(1) context: any context of the rule is shown, and the
+		// examples can't be the same.
+		if !ok {
+			path, ok = s.shortestPath(anyLookahead(target))
+			cex.NoLookahead = true
+		}
+
+		if !ok {
+			cex.NotFound = true
+
+			return cex
+		}
+
+		paths[i] = append(paths[i], path)
+		cex.Derivations[i] = startDerivation(s.derive(path, shifts[i]))
+	}
+
+	if cex.NoLookahead {
+		return cex
+	}
+
+	if d, ok := s.unifyPaths(paths[0][0], shifts[0], paths[1][0], shifts[1]); ok {
+		cex.Derivations, cex.Unifying = d, true
+
+		return cex
+	}
+
+	// Try longer contexts of each action with all the ones of the other.
+	minLength := util.Min(len(paths[0][0]), len(paths[1][0]))
+
+	for length := minLength; length < minLength+maxExtraContext && !s.expired(); length++ {
+		for i, target := range targets {
+			other := 1 - i
+
+			s.paths(target, length, func(path []contextNode) bool {
+				if len(path) == len(paths[i][0]) && samePath(path, paths[i][0]) {
+					return false
+				}
+
+				paths[i] = append(paths[i], path)
+
+				for _, otherPath := range paths[other] {
+					pair := [2][]contextNode{}
+					pair[i], pair[other] = path, otherPath
+
+					if d, ok := s.unifyPaths(pair[0], shifts[0], pair[1], shifts[1]); ok {
+						cex.Derivations, cex.Unifying = d, true
+
+						return true
+					}
+				}
+
+				return s.expired()
+			})
+
+			if cex.Unifying {
+				return cex
+			}
+		}
+	}
+
+	cex.TimedOut = s.timedOut
+
+	return cex
+}
+
+// Get the derivation of the start symbol from the one of the accept
+// rule, unless the point of the conflict is in the accept rule.
+func startDerivation(root *Derivation) *Derivation {
+	if len(root.Children) == 1 {
+		return root.Children[0]
+	}
+
+	return root
+}
+
+func samePath(x, y []contextNode) bool {
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *cexSearch) expired() bool {
+	if !s.timedOut && time.Now().After(s.deadline) {
+		s.timedOut = true
+	}
+
+	return s.timedOut
+}
+
+// Get the shortest sequence of symbols from the first state to the state.
+func (lemon *Lemon) shortestPrefix(target *State) []*Symbol {
+	type step struct {
+		from   *State
+		symbol *Symbol
+	}
+
+	steps := map[*State]step{lemon.sortedState[0]: {}}
+	queue := []*State{lemon.sortedState[0]}
+
+	for len(queue) > 0 && queue[0] != target {
+		stp := queue[0]
+		queue = queue[1:]
+
+		for _, ap := range stp.ap {
+			if _, ok := steps[ap.stp]; ap.stp != nil && !ok {
+				steps[ap.stp] = step{stp, ap.sp}
+				queue = append(queue, ap.stp)
+			}
+		}
+	}
+
+	var prefix []*Symbol
+
+	for stp := target; steps[stp].from != nil; stp = steps[stp].from {
+		prefix = append([]*Symbol{steps[stp].symbol}, prefix...)
+	}
+
+	return prefix
+}
+
+// Get the node the contexts start from: the accept rule in the first
+// state, if it is there.
+func (s *cexSearch) start() (contextNode, bool) {
+	for _, cfp := range s.lemon.sortedState[0].cfp {
+		if cfp.rp == s.lemon.acceptRule && cfp.dot == 0 {
+			return contextNode{cfp, s.lookahead == s.lemon.endSym}, true
+		}
+	}
+
+	return contextNode{}, false
+}
+
+// Get a target which is reached whether the lookahead follows or not.
+func anyLookahead(target func(contextNode) bool) func(contextNode) bool {
+	return func(node contextNode) bool {
+		return target(contextNode{node.cfp, true})
+	}
+}
+
+// Get the nodes of the configurations of the state which make the action
+// on the lookahead.
+func (s *cexSearch) actionTarget(stp *State, ap *Action) func(contextNode) bool {
+	return func(node contextNode) bool {
+		cfp := node.cfp
+
+		if cfp.stp != stp {
+			return false
+		}
+
+		if ap.actionType == Shift {
+			return cfp.next() == s.lookahead
+		}
+
+		rp := ap.rp
+
+		if ap.actionType == Accept {
+			rp = s.lemon.acceptRule
+		}
+
+		return node.lookahead && cfp.rp == rp && cfp.dot == rp.nrhs
+	}
+}
+
+// Get the configuration the configuration becomes after its next symbol.
+func (s *cexSearch) successor(cfp *Config) *Config {
+	if next, ok := s.successors[cfp]; ok {
+		return next
+	}
+
+	var next *Config
+
+	if stp := cfp.stp.Goto(cfp.next()); stp != nil {
+		for _, other := range stp.cfp {
+			if other.rp == cfp.rp && other.dot == cfp.dot+1 {
+				next = other
+				break
+			}
+		}
+	}
+
+	s.successors[cfp] = next
+
+	return next
+}
+
+// Get the nodes following the node: the configuration after its next
+// symbol, and if it's a non-terminal, the configurations of the state
+// which start its rules. The lookahead follows these if it starts the
+// rest of the rule, or if the rest is nullable and it follows the rule.
+func (s *cexSearch) edges(node contextNode) []contextNode {
+	cfp := node.cfp
+	next := cfp.next()
+
+	if next == nil {
+		return nil
+	}
+
+	var nodes []contextNode
+
+	if succ := s.successor(cfp); succ != nil {
+		nodes = append(nodes, contextNode{succ, node.lookahead})
+	}
+
+	if next.IsTerminal() {
+		return nodes
+	}
+
+	rest := cfp.rp.rhs[cfp.dot+1 : cfp.rp.nrhs]
+	first := make(util.IntSet)
+	addFirst(first, rest)
+	lookahead := first.Includes(s.lookahead.index) || allNullable(rest) && node.lookahead
+
+	for _, other := range cfp.stp.cfp {
+		if other.dot == 0 && other.rp.lhs == next {
+			nodes = append(nodes, contextNode{other, lookahead})
+		}
+	}
+
+	return nodes
+}
+
+// Find a shortest path from the start to a target node, if there is one.
+func (s *cexSearch) shortestPath(target func(contextNode) bool) ([]contextNode, bool) {
+	start, ok := s.start()
+
+	if !ok {
+		return nil, false
+	}
+
+	from := map[contextNode]*contextNode{start: nil}
+	queue := []contextNode{start}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		if target(node) {
+			var path []contextNode
+
+			for n := &node; n != nil; n = from[*n] {
+				path = append([]contextNode{*n}, path...)
+			}
+
+			return path, true
+		}
+
+		for _, next := range s.edges(node) {
+			if _, ok := from[next]; !ok {
+				prev := node
+				from[next] = &prev
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// Call f with each path of the length from the start to a target node,
+// which doesn't go through a node twice, until it returns true.
+func (s *cexSearch) paths(target func(contextNode) bool, length int, f func([]contextNode) bool) {
+	start, ok := s.start()
+
+	if !ok {
+		return
+	}
+
+	path := []contextNode{start}
+	var rec func() bool
+
+	rec = func() bool {
+		node := path[len(path)-1]
+
+		if target(node) {
+			return len(path) == length && f(append([]contextNode(nil), path...))
+		}
+
+		if len(path) == length || s.expired() {
+			return s.timedOut
+		}
+
+		s.onPath[node] = true
+		defer delete(s.onPath, node)
+
+		for _, next := range s.edges(node) {
+			if s.onPath[next] {
+				continue
+			}
+
+			path = append(path, next)
+			done := rec()
+			path = path[:len(path)-1]
+
+			if done {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	rec()
+}
+
+// Build the derivation of a path. The configurations of the path either
+// start a rule inside the current one, or follow a symbol of it. At the
+// end, the rule of the target is either reduced, and the lookahead is
+// derived by the rest of the first rule around it which can derive it,
+// or the lookahead is shifted. The other rules around are left as they
+// are.
+func (s *cexSearch) derive(path []contextNode, shift bool) *Derivation {
+	root := &Derivation{Symbol: s.lemon.acceptRule.lhs, Rule: s.lemon.acceptRule}
+	open := []*Derivation{root}
+
+	for _, node := range path[1:] {
+		top := open[len(open)-1]
+		cfp := node.cfp
+
+		if cfp.dot == 0 {
+			child := &Derivation{Symbol: cfp.rp.lhs, Rule: cfp.rp}
+			top.Children = append(top.Children, child)
+			open = append(open, child)
+		} else {
+			top.Children = append(top.Children, &Derivation{Symbol: cfp.rp.rhs[cfp.dot-1]})
+		}
+	}
+
+	inner := open[len(open)-1]
+	inner.Children = append(inner.Children, &Derivation{})
+	placed := shift
+
+	if shift {
+		inner.Children = append(inner.Children, s.leaves(inner.Rule.rhs[len(inner.Children)-1:inner.Rule.nrhs])...)
+	}
+
+	for i := len(open) - 2; i >= 0; i-- {
+		d := open[i]
+		rest := d.Rule.rhs[len(d.Children):d.Rule.nrhs]
+
+		if placed {
+			d.Children = append(d.Children, s.leaves(rest)...)
+			continue
+		}
+
+		first := make(util.IntSet)
+		addFirst(first, rest)
+
+		if !first.Includes(s.lookahead.index) {
+			// The rest is nullable, the lookahead follows the rule.
+			for _, symbol := range rest {
+				d.Children = append(d.Children, s.deriveEmpty(symbol))
+			}
+
+			continue
+		}
+
+		for j, symbol := range rest {
+			if placed {
+				d.Children = append(d.Children, &Derivation{Symbol: symbol})
+			} else if symbol.firstset.Includes(s.lookahead.index) {
+				d.Children = append(d.Children, s.deriveStart(symbol))
+				placed = true
+			} else {
+				d.Children = append(d.Children, s.deriveEmpty(rest[j]))
+			}
+		}
+	}
+
+	return root
+}
+
+func (s *cexSearch) leaves(symbols []*Symbol) []*Derivation {
+	leaves := make([]*Derivation, len(symbols))
+
+	for i, symbol := range symbols {
+		leaves[i] = &Derivation{Symbol: symbol}
+	}
+
+	return leaves
+}
+
+// Find for each non-terminal the shortest way to derive a string which
+// starts with the lookahead.
+func (s *cexSearch) findStartRules() {
+	s.startRules = make(map[*Symbol]ruleAt)
+	depths := make(map[*Symbol]int)
+
+	for changed := true; changed; {
+		changed = false
+
+		for rp := s.lemon.firstRule; rp != nil; rp = rp.next {
+			for j, symbol := range rp.rhs[:rp.nrhs] {
+				depth, ok := 1, symbol == s.lookahead
+
+				if d, found := depths[symbol]; found {
+					depth, ok = d+1, true
+				}
+
+				if old, found := depths[rp.lhs]; ok && (!found || depth < old) {
+					depths[rp.lhs] = depth
+					s.startRules[rp.lhs] = ruleAt{rp, j}
+					changed = true
+				}
+
+				if !symbol.nullable {
+					break
+				}
+			}
+		}
+	}
+}
+
+// Derive a string which starts with the lookahead from the symbol.
+func (s *cexSearch) deriveStart(symbol *Symbol) *Derivation {
+	if symbol == s.lookahead {
+		return &Derivation{Symbol: symbol}
+	}
+
+	at := s.startRules[symbol]
+	d := &Derivation{Symbol: symbol, Rule: at.rp}
+
+	for j, other := range at.rp.rhs[:at.rp.nrhs] {
+		switch {
+		case j < at.pos:
+			d.Children = append(d.Children, s.deriveEmpty(other))
+		case j == at.pos:
+			d.Children = append(d.Children, s.deriveStart(other))
+		default:
+			d.Children = append(d.Children, &Derivation{Symbol: other})
+		}
+	}
+
+	return d
+}
+
+// Derive the empty string from the nullable symbol.
+func (s *cexSearch) deriveEmpty(symbol *Symbol) *Derivation {
+	rp := s.shortest[symbol]
+	d := &Derivation{Symbol: symbol, Rule: rp}
+
+	for _, other := range rp.rhs[:rp.nrhs] {
+		d.Children = append(d.Children, s.deriveEmpty(other))
+	}
+
+	return d
+}
+
+// Try to make the derivations of two paths derive the same example, by
+// expanding some of their symbols. The symbols right before the point of
+// the conflict are not expanded: a rule ending there would be reduced
+// in the state of the conflict, so the derivation wouldn't be the one of
+// its action.
+func (s *cexSearch) unifyPaths(x []contextNode, shiftX bool, y []contextNode, shiftY bool) ([2]*Derivation, bool) {
+	d := [2]*Derivation{s.derive(x, shiftX), s.derive(y, shiftY)}
+	var before, after [2][]*Derivation
+
+	for i := range d {
+		leaves := d[i].leaves(nil)
+
+		for j, leaf := range leaves {
+			if leaf.Symbol == nil {
+				before[i], after[i] = leaves[:j], leaves[j+1:]
+				break
+			}
+		}
+	}
+
+	if len(before[0]) != 0 && len(before[1]) != 0 {
+		last0, last1 := before[0][len(before[0])-1], before[1][len(before[1])-1]
+
+		if last0.Symbol != last1.Symbol {
+			return d, false
+		}
+
+		before[0], before[1] = before[0][:len(before[0])-1], before[1][:len(before[1])-1]
+	} else if len(before[0]) != len(before[1]) {
+		return d, false
+	}
+
+	if !s.unify(before[0], before[1], maxExpansions) || !s.unify(after[0], after[1], maxExpansions) {
+		return d, false
+	}
+
+	d[0], d[1] = startDerivation(d[0]), startDerivation(d[1])
+
+	// Both derivations are different, unless the paths are the same.
+	return d, d[0].String() != d[1].String()
+}
+
+// Expand the non-terminals at the beginning of the leaves until both
+// derive the same symbols, expanding at most `budget` of them.
+func (s *cexSearch) unify(x, y []*Derivation, budget int) bool {
+	if s.expired() {
+		return false
+	}
+
+	if len(x) == 0 && len(y) == 0 {
+		return true
+	}
+
+	if len(x) > 0 && len(y) > 0 && x[0].Symbol == y[0].Symbol {
+		return s.unify(x[1:], y[1:], budget)
+	}
+
+	if budget == 0 {
+		return false
+	}
+
+	for side, leaves := range [2][]*Derivation{x, y} {
+		if len(leaves) == 0 || leaves[0].Symbol.IsTerminal() {
+			continue
+		}
+
+		leaf := leaves[0]
+
+		for rp := leaf.Symbol.rule; rp != nil; rp = rp.nextlhs {
+			leaf.Rule, leaf.Children = rp, s.leaves(rp.rhs[:rp.nrhs])
+			rest := append(append([]*Derivation(nil), leaf.Children...), leaves[1:]...)
+			ok := false
+
+			if side == 0 {
+				ok = s.unify(rest, y, budget-1)
+			} else {
+				ok = s.unify(x, rest, budget-1)
+			}
+
+			if ok {
+				return true
+			}
+
+			leaf.Rule, leaf.Children = nil, nil
+		}
+	}
+
+	return false
+}
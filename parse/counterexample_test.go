@@ -0,0 +1,156 @@
+package parse
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func buildCounterexamples(t *testing.T, name string, src string) *Lemon {
+	lemon := buildActions(t, name, src, LALR)
+	lemon.SetCounterexampleTimeout(time.Second)
+	lemon.findCounterexamples()
+
+	return lemon
+}
+
+func TestCounterexampleAmbiguous(t *testing.T) {
+	src, err := os.ReadFile("../example/ambiguous.y")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lemon := buildCounterexamples(t, "ambiguous.y", string(src))
+	cexs := lemon.Counterexamples()
+
+	if len(cexs) != lemon.ConflictCount() || len(cexs) != 24 {
+		t.Fatalf("Expect 24 counterexamples, actual %d", len(cexs))
+	}
+
+	for _, cex := range cexs {
+		if !cex.Unifying {
+			t.Errorf("Expect an ambiguous example: %v", cex)
+		}
+	}
+
+	stp := walk(t, lemon, "exp", "'+'", "exp")
+	expect := strings.Join([]string{
+		fmt.Sprintf("State %d has a shift/reduce conflict on '+': shift, not reduce `exp:exp '+' exp.`", stp.Index()),
+		"  Prefix: exp '+' exp",
+		"  Ambiguous example: exp '+' exp • '+' exp",
+		"  Derivation to shift: exp ::= [ exp '+' exp ::= [ exp • '+' exp ] ]",
+		"  Derivation to reduce `exp:exp '+' exp.`: exp ::= [ exp ::= [ exp '+' exp • ] '+' exp ]",
+	}, "\n")
+
+	for _, cex := range cexs {
+		if cex.Conflict.State == stp && cex.Conflict.Lookahead.name == "'+'" {
+			if actual := cex.String(); actual != expect {
+				t.Errorf("Expect %q, actual %q", expect, actual)
+			}
+		}
+	}
+}
+
+// The shortest contexts of the actions don't derive the same example, a
+// longer one of the shift does.
+func TestCounterexampleDanglingElse(t *testing.T) {
+	lemon := buildCounterexamples(t, "else.y", `%%
+s: IF E THEN s | IF E THEN s ELSE s | X ;
+`)
+	cexs := lemon.Counterexamples()
+
+	if len(cexs) != 1 || !cexs[0].Unifying {
+		t.Fatalf("Expect an ambiguous example, actual %v", cexs)
+	}
+
+	cex := cexs[0]
+	checkStrings(t, "prefix", []string{"IF", "E", "THEN", "s"}, symbolNames(cex.Prefix))
+	checkStrings(t, "derivations", []string{
+		"s ::= [ IF E THEN s ::= [ IF E THEN s • ELSE s ] ]",
+		"s ::= [ IF E THEN s ::= [ IF E THEN s • ] ELSE s ]",
+	}, []string{cex.Derivations[0].String(), cex.Derivations[1].String()})
+
+	if example := cex.Derivations[0].Example(); example != "IF E THEN IF E THEN s • ELSE s" {
+		t.Errorf("Unexpected example: %s", example)
+	}
+}
+
+// The conflicts of LALR(1) merging are not ambiguities.
+func TestCounterexampleNotUnifying(t *testing.T) {
+	lemon := buildCounterexamples(t, "lr1.y", lr1Grammar)
+	cexs := lemon.Counterexamples()
+
+	if len(cexs) != 2 {
+		t.Fatalf("Expect 2 counterexamples, actual %v", cexs)
+	}
+
+	cex := cexs[0]
+
+	if cex.Unifying || cex.TimedOut {
+		t.Errorf("Expect no unifying example, actual %v", cex)
+	}
+
+	checkStrings(t, "examples", []string{"A C • D", "B C • D"}, []string{cex.Derivations[0].Example(), cex.Derivations[1].Example()})
+	checkStrings(t, "derivations", []string{"s ::= [ A a ::= [ C • ] D ]", "s ::= [ B b ::= [ C • ] D ]"},
+		[]string{cex.Derivations[0].String(), cex.Derivations[1].String()})
+
+	if !strings.HasSuffix(cex.String(), "\n  No unifying example found") {
+		t.Errorf("Unexpected counterexample: %v", cex)
+	}
+}
+
+// The lookahead after a reduce is derived by the rules around, through
+// nullable symbols.
+func TestCounterexampleNullable(t *testing.T) {
+	lemon := buildCounterexamples(t, "nullable.y", `%%
+s: a o b | A o C ;
+a: A ;
+b: C | B ;
+o: | O ;
+`)
+	cexs := lemon.Counterexamples()
+
+	if len(cexs) != 2 || !cexs[0].Unifying || cexs[0].Conflict.Kind != ReduceReduce {
+		t.Fatalf("Expect an ambiguous reduce/reduce conflict, actual %v", cexs)
+	}
+
+	checkStrings(t, "derivations", []string{
+		"s ::= [ a ::= [ A • ] o ::= [ ε ] b ::= [ C ] ]",
+		"s ::= [ A o ::= [ • ] C ]",
+	}, []string{cexs[0].Derivations[0].String(), cexs[0].Derivations[1].String()})
+}
+
+// EQ follows `r ::= l` in FOLLOW(r) only: the SLR(1) conflict has no
+// LR(1) context where the lookahead follows the reduce.
+func TestCounterexampleModes(t *testing.T) {
+	const src = `%%
+s: l EQ r | r ;
+l: STAR r | ID ;
+r: l ;
+`
+
+	for name, mode := range LRModes {
+		lemon := buildActions(t, "slr.y", src, mode)
+		lemon.SetCounterexampleTimeout(time.Second)
+		lemon.findCounterexamples()
+		cexs := lemon.Counterexamples()
+
+		if mode != SLR {
+			if len(cexs) != 0 {
+				t.Errorf("%s: unexpected %v", name, cexs)
+			}
+
+			continue
+		}
+
+		if len(cexs) != 1 || !cexs[0].NoLookahead || cexs[0].Unifying {
+			t.Fatalf("%s: expect a counterexample without the lookahead, actual %v", name, cexs)
+		}
+
+		checkStrings(t, "examples", []string{"l • EQ r", "l •"},
+			[]string{cexs[0].Derivations[0].Example(), cexs[0].Derivations[1].Example()})
+	}
+}
@@ -0,0 +1,367 @@
+package parse
+
+import (
+	"bytes"
+	"io"
+)
+
+type NodeKind int
+
+const (
+	NdToken       NodeKind = iota // A single token, including spaces and comments
+	NdFile                        // The whole grammar file
+	NdPrologue                    // %{ ... %}
+	NdDecl                        // %token <tag> A B
+	NdSeparator                   // %%
+	NdRule                        // lhs: alternative | alternative ;
+	NdAlternative                 // The right hand side of a rule, with its %prec and code
+	NdEpilogue                    // Everything after the second %%
+)
+
+func (kind NodeKind) String() string {
+	switch kind {
+	case NdToken:
+		return "Token"
+	case NdFile:
+		return "File"
+	case NdPrologue:
+		return "Prologue"
+	case NdDecl:
+		return "Decl"
+	case NdSeparator:
+		return "Separator"
+	case NdRule:
+		return "Rule"
+	case NdAlternative:
+		return "Alternative"
+	case NdEpilogue:
+		return "Epilogue"
+	default:
+		return "Not implemented"
+	}
+}
+
+// A node of the concrete syntax tree of a grammar file.
+// Spaces and comments between the children of a node are kept as tokens
+// of that node, while those around a node belong to its parent. So the
+// comments just before a rule are the tokens preceding it in the file.
+type Node struct {
+	Kind     NodeKind
+	Token    Span    // The token of a NdToken node
+	Start    int     // Offset of the first byte covered
+	End      int     // Offset just past the last byte covered
+	Children []*Node // Children in source order
+}
+
+func newLeaf(token Span) *Node {
+	return &Node{Kind: NdToken, Token: token, Start: token.Start, End: token.End}
+}
+
+func (node *Node) IsTrivia() bool {
+	return node.Kind == NdToken && node.Token.IsTrivia()
+}
+
+func (node *Node) append(child *Node) {
+	if len(node.Children) == 0 {
+		node.Start = child.Start
+	}
+
+	node.Children = append(node.Children, child)
+	node.End = child.End
+}
+
+// Call `visit` for each leaf below the node in source order.
+func (node *Node) Leaves(visit func(leaf *Node)) {
+	if node.Kind == NdToken || node.Kind == NdEpilogue {
+		visit(node)
+		return
+	}
+
+	for _, child := range node.Children {
+		child.Leaves(visit)
+	}
+}
+
+// Get the non trivia children.
+func (node *Node) Significant() []*Node {
+	var result []*Node
+
+	for _, child := range node.Children {
+		if !child.IsTrivia() {
+			result = append(result, child)
+		}
+	}
+
+	return result
+}
+
+// A lossless concrete syntax tree. Every byte of the source belongs to
+// exactly one leaf, so writing out the leaves reproduces the source.
+type CST struct {
+	Root    *Node
+	scanner *Scanner
+}
+
+// Parse a grammar file into a concrete syntax tree. The tree is built even
+// for malformed input; `onError` may be nil.
+func ParseCST(src []byte, onError func(pos Position, msg string)) *CST {
+	sc := NewScanner(src)
+	sc.KeepTrivia()
+	sc.SetErrorHandler(onError)
+	builder := &cstBuilder{scanner: sc}
+	builder.read()
+
+	return &CST{
+		Root:    builder.build(),
+		scanner: sc,
+	}
+}
+
+// Get the source text covered by the node. The result shares memory with the source.
+func (cst *CST) Bytes(node *Node) []byte {
+	return cst.scanner.src[node.Start:node.End]
+}
+
+// Get the source text covered by the node as a string.
+func (cst *CST) Text(node *Node) string {
+	return string(cst.Bytes(node))
+}
+
+func (cst *CST) Position(offset int) Position {
+	return cst.scanner.Position(offset)
+}
+
+// Write out all leaves. This gives back the source byte for byte.
+func (cst *CST) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	var err error
+
+	cst.Root.Leaves(func(leaf *Node) {
+		if err == nil {
+			var n int
+			n, err = w.Write(cst.Bytes(leaf))
+			total += int64(n)
+		}
+	})
+
+	return total, err
+}
+
+func (cst *CST) String() string {
+	var buf bytes.Buffer
+	cst.WriteTo(&buf)
+
+	return buf.String()
+}
+
+type cstBuilder struct {
+	scanner  *Scanner
+	tokens   []Span // All tokens before the epilogue
+	sig      []int  // Index into tokens of each non trivia token
+	epilogue *Node  // Everything after the second `%%`, if any
+	done     int    // Index of the first token not added to the tree yet
+}
+
+// Read all tokens. The text after the second `%%` is one epilogue node.
+func (b *cstBuilder) read() {
+	sc := b.scanner
+	separators := 0
+	prevSep := -2
+
+	for token := sc.Next(); token.Kind != TkEOF; token = sc.Next() {
+		b.tokens = append(b.tokens, token)
+
+		if token.IsTrivia() {
+			continue
+		}
+
+		b.sig = append(b.sig, len(b.tokens)-1)
+
+		// `%%%` is a single separator.
+		if p := len(b.sig) - 2; p > prevSep+1 && b.isSeparator(p) {
+			prevSep = p
+
+			if separators++; separators == 2 {
+				b.epilogue = &Node{Kind: NdEpilogue, Start: token.End, End: len(sc.src)}
+				return
+			}
+		}
+	}
+}
+
+// Get the p-th non trivia token. Past the end, an EOF token is returned.
+func (b *cstBuilder) at(p int) (Span, string) {
+	if p < 0 || p >= len(b.sig) {
+		end := len(b.scanner.src)
+		return Span{TkEOF, end, end}, ""
+	}
+
+	token := b.tokens[b.sig[p]]
+
+	return token, string(b.scanner.Bytes(token))
+}
+
+func (b *cstBuilder) isPunct(p int, text string) bool {
+	token, str := b.at(p)
+
+	return token.Kind == TkPunct && str == text
+}
+
+func (b *cstBuilder) isSeparator(p int) bool {
+	return b.isPunct(p, "%") && b.isPunct(p+1, "%")
+}
+
+// Move the trivia before the p-th non trivia token into `parent`.
+func (b *cstBuilder) flush(parent *Node, p int) {
+	end := len(b.tokens)
+
+	if p < len(b.sig) {
+		end = b.sig[p]
+	}
+
+	for ; b.done < end; b.done++ {
+		parent.append(newLeaf(b.tokens[b.done]))
+	}
+}
+
+// Create a leaf for the p-th non trivia token. Trivia before it must
+// have been flushed.
+func (b *cstBuilder) take(p int) *Node {
+	b.done = b.sig[p] + 1
+
+	return newLeaf(b.tokens[b.sig[p]])
+}
+
+// Create a node holding the non trivia tokens from `first` to `last` and
+// all trivia between them.
+func (b *cstBuilder) group(kind NodeKind, first, last int) *Node {
+	node := &Node{Kind: kind}
+
+	if first > last {
+		// An empty alternative sits right after the previous token.
+		node.Start = b.tokens[b.done-1].End
+		node.End = node.Start
+
+		return node
+	}
+
+	for end := b.sig[last]; b.done <= end; b.done++ {
+		node.append(newLeaf(b.tokens[b.done]))
+	}
+
+	return node
+}
+
+func (b *cstBuilder) build() *Node {
+	file := &Node{Kind: NdFile}
+	inRules := false
+
+	for p := 0; p < len(b.sig); {
+		token, _ := b.at(p)
+		b.flush(file, p)
+
+		switch {
+		case b.isSeparator(p):
+			file.append(b.group(NdSeparator, p, p+1))
+			inRules = true
+			p += 2
+
+		case inRules && token.Kind == TkIdent:
+			p = b.buildRule(file, p)
+
+		case !inRules && b.isPunct(p, "%"):
+			last := p
+
+			for next, _ := b.at(last + 1); next.Kind != TkEOF && !b.isPunct(last+1, "%"); next, _ = b.at(last + 1) {
+				last++
+			}
+
+			kind := NdDecl
+
+			if next, _ := b.at(p + 1); next.Kind == TkCode {
+				kind = NdPrologue
+			}
+
+			file.append(b.group(kind, p, last))
+			p = last + 1
+
+		default:
+			// Something unexpected. Keep it as a plain token.
+			file.append(b.take(p))
+			p++
+		}
+	}
+
+	b.flush(file, len(b.sig))
+
+	if b.epilogue != nil {
+		file.append(b.epilogue)
+	}
+
+	file.Start = 0
+
+	return file
+}
+
+// Build the rule starting at the p-th non trivia token and return the
+// index of the token after it. A rule ends with `;`, or without it right
+// before the next rule or `%%`.
+func (b *cstBuilder) buildRule(file *Node, p int) int {
+	rule := &Node{Kind: NdRule}
+	rule.append(b.take(p))
+	p++
+
+	if !b.isPunct(p, ":") {
+		file.append(rule)
+		return p
+	}
+
+	b.flush(rule, p)
+	rule.append(b.take(p))
+	p++
+
+	for {
+		first := p
+
+		for !b.endOfAlternative(p) {
+			p++
+		}
+
+		if first < p {
+			b.flush(rule, first)
+		}
+
+		rule.append(b.group(NdAlternative, first, p-1))
+
+		if !b.isPunct(p, "|") {
+			break
+		}
+
+		b.flush(rule, p)
+		rule.append(b.take(p))
+		p++
+	}
+
+	if b.isPunct(p, ";") {
+		b.flush(rule, p)
+		rule.append(b.take(p))
+		p++
+	}
+
+	file.append(rule)
+
+	return p
+}
+
+func (b *cstBuilder) endOfAlternative(p int) bool {
+	token, _ := b.at(p)
+
+	switch {
+	case token.Kind == TkEOF, b.isPunct(p, "|"), b.isPunct(p, ";"), b.isSeparator(p):
+		return true
+	case token.Kind == TkIdent && b.isPunct(p+1, ":"):
+		return true
+	}
+
+	return false
+}
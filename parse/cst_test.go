@@ -0,0 +1,149 @@
+package parse
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func checkRoundTrip(t *testing.T, name string, src []byte) *CST {
+	cst := ParseCST(src, nil)
+
+	if actual := cst.String(); actual != string(src) {
+		t.Errorf("%s: round trip differs:\n%q\n%q", name, src, actual)
+	}
+
+	// Leaves must be contiguous.
+	offset := 0
+	cst.Root.Leaves(func(leaf *Node) {
+		if leaf.Start != offset {
+			t.Errorf("%s: leaf %q starts at %d, expect %d", name, cst.Text(leaf), leaf.Start, offset)
+		}
+
+		offset = leaf.End
+	})
+
+	return cst
+}
+
+func TestCSTRoundTripExamples(t *testing.T) {
+	files, _ := filepath.Glob("../example/*.y")
+
+	if len(files) == 0 {
+		t.Fatal("No example found")
+	}
+
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkRoundTrip(t, file, src)
+	}
+}
+
+func TestCSTRoundTripEdgeCases(t *testing.T) {
+	inputs := []string{
+		"",
+		"\uFEFF%token A\r\n%%\r\na: A ; // c\r\n%%\r\nrest\r\n",
+		"%%\na: | b { x } ;\nb:\n",
+		"%%\na: '\n",
+		"%%\na: b /* unterminated",
+		"%{ x %}\n%%\n%%",
+		"% % %%% ; :",
+		"a \xff\xfe b",
+		"%%\na: b\nc: d\n%%",
+	}
+
+	for _, input := range inputs {
+		checkRoundTrip(t, input, []byte(input))
+	}
+
+	checkRoundTrip(t, "large grammar", largeGrammar(100))
+}
+
+func kinds(nodes []*Node) []NodeKind {
+	var result []NodeKind
+
+	for _, node := range nodes {
+		result = append(result, node.Kind)
+	}
+
+	return result
+}
+
+func TestCSTStructure(t *testing.T) {
+	src := []byte(`%{
+package main
+%}
+
+%token <num> NUM
+
+%%
+
+// The whole expression.
+expr:
+	expr '+' NUM { $$ = $1 + $3 } // Add
+|	/* empty */
+|	NUM %prec NUM
+;
+
+%%
+func main() {}
+`)
+	cst := checkRoundTrip(t, "structure", src)
+	top := cst.Root.Significant()
+	expect := []NodeKind{NdPrologue, NdDecl, NdSeparator, NdRule, NdSeparator, NdEpilogue}
+
+	if actual := kinds(top); len(actual) != len(expect) {
+		t.Fatalf("Expect %v, actual %v", expect, actual)
+	}
+
+	for i, kind := range expect {
+		if top[i].Kind != kind {
+			t.Errorf("Node %d: expect %v, actual %v", i, kind, top[i].Kind)
+		}
+	}
+
+	rule := top[3]
+	var comment *Node
+
+	for _, child := range cst.Root.Children {
+		if child == rule {
+			break
+		}
+
+		if child.Kind == NdToken && child.Token.Kind == TkComment {
+			comment = child
+		}
+	}
+
+	if comment == nil || cst.Text(comment) != "// The whole expression." {
+		t.Errorf("Expect the comment before the rule to belong to the file")
+	}
+	var alternatives []string
+
+	for _, child := range rule.Children {
+		if child.Kind == NdAlternative {
+			alternatives = append(alternatives, cst.Text(child))
+		}
+	}
+
+	expectAlts := []string{"expr '+' NUM { $$ = $1 + $3 }", "", "NUM %prec NUM"}
+
+	if len(alternatives) != len(expectAlts) {
+		t.Fatalf("Expect alternatives %q, actual %q", expectAlts, alternatives)
+	}
+
+	for i, alt := range expectAlts {
+		if alternatives[i] != alt {
+			t.Errorf("Alternative %d: expect %q, actual %q", i, alt, alternatives[i])
+		}
+	}
+
+	if text := cst.Text(top[5]); text != "\nfunc main() {}\n" {
+		t.Errorf("Unexpected epilogue: %q", text)
+	}
+}
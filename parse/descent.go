@@ -0,0 +1,277 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/golemon/util"
+)
+
+// `$$`, `$1`, `$<tag>$` or `$<tag>1` in the code of a rule.
+var valueRef = regexp.MustCompile(`\$(?:<(\w+)>)?(\$|\d+)`)
+
+// Get the name of a symbol in Go identifiers, e.g. ExprList for
+// `expr_list`.
+func camelName(name string) string {
+	var buf strings.Builder
+
+	for _, word := range strings.Split(name, "_") {
+		if word != "" {
+			buf.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+
+	return buf.String()
+}
+
+// Write a recursive-descent parser for the LL(1) grammar: a method per
+// non-terminal, which chooses the rule by the lookahead, parses its
+// symbols and runs its code. The lexer is the one of goyacc: it returns
+// the token codes, the characters for character literals and 0 at the
+// end of input. The code after the second `%%` follows the parser.
+func (lemon *Lemon) writeDescent(buf *bytes.Buffer) {
+	lemon.writeLexerTypes(buf)
+	fmt.Fprintf(buf, descentParser, lemon.prefix(), camelName(lemon.startSymbol().name), lemon.symTable.TerminalCount())
+	codes := lemon.writeTokenTables(buf)
+	written := make(map[*Symbol]bool)
+
+	for rp := lemon.firstRule; rp != nil; rp = rp.next {
+		if !written[rp.lhs] {
+			written[rp.lhs] = true
+			lemon.writeDescentFunc(buf, rp.lhs, lemon.rulesOf(rp.lhs), codes)
+		}
+	}
+
+	if extra := strings.TrimSpace(lemon.extraCode); extra != "" {
+		buf.WriteString("\n" + extra + "\n")
+	}
+}
+
+// Write the lexer interface of goyacc, and the type of the values of the
+// symbols from `%union`.
+func (lemon *Lemon) writeLexerTypes(buf *bytes.Buffer) {
+	union := strings.TrimSpace(lemon.unionCode)
+
+	if union == "" {
+		union = "{}"
+	}
+
+	fmt.Fprintf(buf, lexerTypes, lemon.prefix(), union)
+}
+
+// Write the names of the terminals by token code, and the token codes of
+// the literals of one character the lexer returns as characters, `'+'` or
+// `"+"`. Return the token codes.
+func (lemon *Lemon) writeTokenTables(buf *bytes.Buffer) map[*Symbol]int {
+	prefix := lemon.prefix()
+	terminals := lemon.symTable.terminals()
+	codes := make(map[*Symbol]int)
+	fmt.Fprintf(buf, "// The names of the terminals, by token code.\nvar %sTerminalNames = []string{\n", prefix)
+
+	for i, symbol := range terminals {
+		codes[symbol] = i
+		fmt.Fprintf(buf, "\t%s,\n", strconv.Quote(symbol.name))
+	}
+
+	fmt.Fprintf(buf, "}\n\n// The token codes of the character literals.\nvar %sLiterals = map[int]int{\n", prefix)
+
+	written := make(map[rune]bool)
+
+	for i, symbol := range terminals {
+		text, err := strconv.Unquote(symbol.name)
+
+		if err != nil || !util.IsStringLiteral(symbol.name) || utf8.RuneCountInString(text) != 1 {
+			continue
+		}
+
+		// `'+'` and `"+"` are the same character: the first one wins.
+		if r, _ := utf8.DecodeRuneInString(text); !written[r] {
+			written[r] = true
+			fmt.Fprintf(buf, "\t%s: %d,\n", strconv.QuoteRune(r), i)
+		}
+	}
+
+	buf.WriteString("}\n")
+
+	return codes
+}
+
+// Write the method of a non-terminal. A rule is chosen by its predict set,
+// the values of its symbols are put in yyS, and the value of the first one
+// is the value of the rule unless its code sets `$$`. The terminals are
+// matched by their token codes.
+func (lemon *Lemon) writeDescentFunc(buf *bytes.Buffer, symbol *Symbol, rules []*Rule, codes map[*Symbol]int) {
+	prefix := lemon.prefix()
+	buf.WriteString("\n")
+	writeDoc(buf, symbol.doc, "")
+
+	for _, rp := range rules {
+		fmt.Fprintf(buf, "// %s ::= %s\n", rp.lhs.name, rp.rhsString())
+	}
+
+	fmt.Fprintf(buf, "func (p *%sParser) parse%s(yyVAL *%sSymType) bool {\n\tswitch p.token {\n", prefix, camelName(symbol.name), prefix)
+	var expected []string
+
+	for _, rp := range rules {
+		lookaheads := lemon.symbolsOf(predictSet(rp))
+		sort.Slice(lookaheads, func(i, j int) bool { return codes[lookaheads[i]] < codes[lookaheads[j]] })
+		cases := make([]string, len(lookaheads))
+		names := make([]string, len(lookaheads))
+
+		for i, lookahead := range lookaheads {
+			cases[i], names[i] = strconv.Itoa(codes[lookahead]), lookahead.name
+		}
+
+		expected = append(expected, names...)
+		fmt.Fprintf(buf, "\tcase %s: // %s\n", strings.Join(cases, ", "), strings.Join(names, " "))
+
+		if rp.nrhs > 0 {
+			calls := make([]string, rp.nrhs)
+
+			for i, rhs := range rp.rhs[:rp.nrhs] {
+				if rhs.IsTerminal() {
+					calls[i] = fmt.Sprintf("!p.match(%d, &yyS[%d])", codes[rhs], i+1)
+				} else {
+					calls[i] = fmt.Sprintf("!p.parse%s(&yyS[%d])", camelName(rhs.name), i+1)
+				}
+			}
+
+			fmt.Fprintf(buf, "\t\tvar yyS [%d]%sSymType\n\n", rp.nrhs+1, prefix)
+			fmt.Fprintf(buf, "\t\tif %s {\n\t\t\treturn false\n\t\t}\n\n\t\t*yyVAL = yyS[1]\n", strings.Join(calls, " || "))
+		}
+
+		if code := strings.TrimSpace(rp.code); code != "" {
+			buf.WriteString("\t\t" + lemon.translateCode(rp, code) + "\n")
+		}
+	}
+
+	for i, name := range expected {
+		expected[i] = strconv.Quote(name)
+	}
+
+	fmt.Fprintf(buf, "\tdefault:\n\t\treturn p.fail(%s)\n\t}\n\n\treturn true\n}\n", strings.Join(expected, ", "))
+}
+
+// Replace the values in the code of the rule: `$$` by the value of the
+// rule, `$1` by the one of its first symbol, with the field of the type
+// of the symbol or of the tag.
+func (lemon *Lemon) translateCode(rp *Rule, code string) string {
+	return valueRef.ReplaceAllStringFunc(code, func(ref string) string {
+		match := valueRef.FindStringSubmatch(ref)
+		tag, value := match[1], "yyVAL"
+
+		if match[2] == "$" {
+			if tag == "" {
+				tag = rp.lhs.datatype
+			}
+		} else {
+			n, err := strconv.Atoi(match[2])
+
+			if err != nil || n < 1 || n > rp.nrhs {
+				errorf(lemon.infile, Position{Line: rp.line}, "`%s` is not a symbol of the rule `%v`", ref, rp)
+			}
+
+			if tag == "" {
+				tag = rp.rhs[n-1].datatype
+			}
+
+			value = fmt.Sprintf("yyS[%d]", n)
+		}
+
+		if tag == "" {
+			if value == "yyVAL" {
+				return "(*yyVAL)"
+			}
+
+			return value
+		}
+
+		return value + "." + tag
+	})
+}
+
+// The lexer and the values of the symbols of the generated parsers.
+const lexerTypes = `
+// The lexer of the parser. Lex returns the code of the next token, or 0
+// at the end of input, and sets its value. Error reports syntax errors.
+type %[1]sLexer interface {
+	Lex(lval *%[1]sSymType) int
+	Error(s string)
+}
+
+// The values of the symbols.
+type %[1]sSymType struct %[2]s
+`
+
+// The parser, apart from the methods of the non-terminals.
+const descentParser = `
+// A recursive-descent parser, which reads the lookahead before the rules
+// which start with it.
+type %[1]sParser struct {
+	lex   %[1]sLexer
+	token int        // The token code of the lookahead, or -1 if unknown
+	lval  %[1]sSymType // The value of the lookahead
+}
+
+// Parse the input of the lexer. Return 0 on success, 1 on a syntax error.
+func %[1]sParse(lex %[1]sLexer) int {
+	p := &%[1]sParser{lex: lex}
+	var val %[1]sSymType
+	p.next()
+
+	if !p.parse%[2]s(&val) {
+		return 1
+	}
+
+	if p.token != %[1]sEOF {
+		p.fail(%[1]sTerminalNames[%[1]sEOF])
+
+		return 1
+	}
+
+	return 0
+}
+
+// Read the next token. The lexer returns 0 at the end of the input, and
+// codes beyond the token codes are characters.
+func (p *%[1]sParser) next() {
+	p.lval = %[1]sSymType{}
+	p.token = p.lex.Lex(&p.lval)
+
+	switch {
+	case p.token == 0:
+		p.token = %[1]sEOF
+	case p.token >= %[3]d || p.token < 0:
+		if token, ok := %[1]sLiterals[p.token]; ok {
+			p.token = token
+		} else {
+			p.token = -1
+		}
+	}
+}
+
+// Read the lookahead and its value if it is the terminal.
+func (p *%[1]sParser) match(token int, val *%[1]sSymType) bool {
+	if p.token != token {
+		return p.fail(%[1]sTerminalNames[token])
+	}
+
+	*val = p.lval
+	p.next()
+
+	return true
+}
+
+// Report a syntax error, the lookahead is none of the expected symbols.
+func (p *%[1]sParser) fail(expected ...string) bool {
+	p.lex.Error(%[1]sExpected(expected...))
+
+	return false
+}
+
+`
@@ -0,0 +1,151 @@
+package parse
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Extract the doc comment from the spaces and comments before a token.
+// `lineStart` tells whether `gap` starts at the beginning of a line. A
+// comment following another token on the same line is not a doc comment,
+// neither is one separated from the token by a blank line.
+func docComment(gap []byte, lineStart bool) string {
+	var block []string
+	lineBlock := false // The block is made of `//` comments
+	newlines := 0      // Newlines since the last comment
+
+	for i := 0; i < len(gap); {
+		c := gap[i]
+
+		if c == NewLine {
+			newlines++
+			lineStart = true
+			i++
+
+			continue
+		}
+
+		if c != '/' || i+1 >= len(gap) || (gap[i+1] != '/' && gap[i+1] != '*') {
+			i++
+			continue
+		}
+
+		end := len(gap)
+
+		if gap[i+1] == '/' {
+			if n := bytes.IndexByte(gap[i:], NewLine); n >= 0 {
+				end = i + n
+			}
+		} else if n := bytes.Index(gap[i+2:], []byte("*/")); n >= 0 {
+			end = i + 2 + n + 2
+		}
+
+		text := string(gap[i:end])
+
+		switch {
+		case !lineStart:
+			block = nil
+		case strings.HasPrefix(text, "//"):
+			if !lineBlock || newlines != 1 {
+				block = nil
+			}
+
+			line := strings.TrimRight(text[2:], " \t\r")
+			block = append(block, strings.TrimPrefix(line, " "))
+			lineBlock = true
+		case strings.HasPrefix(text, "/**") && text != "/**/":
+			block = blockCommentLines(text)
+			lineBlock = false
+		default:
+			block = nil
+		}
+
+		newlines = 0
+		lineStart = false
+		i = end
+	}
+
+	if newlines > 1 {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.Join(block, "\n"))
+}
+
+// Remove `/**`, `*/` and the `*` starting each line of a block comment.
+func blockCommentLines(text string) []string {
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "/**"), "*/")
+	lines := strings.Split(text, "\n")
+
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "*") {
+			line = strings.TrimPrefix(line[1:], " ")
+		}
+
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+
+	return lines
+}
+
+// Get the first sentence of a doc comment on a single line, without the
+// final period.
+func docSummary(doc string) string {
+	paragraph := doc
+
+	if n := strings.Index(doc, "\n\n"); n >= 0 {
+		paragraph = doc[:n]
+	}
+
+	summary := strings.Join(strings.Fields(paragraph), " ")
+
+	if n := strings.Index(summary, ". "); n >= 0 {
+		summary = summary[:n]
+	}
+
+	return strings.TrimSuffix(summary, ".")
+}
+
+// Describe a symbol in a syntax error message such as "expected an
+// expression (a value or operator chain)". The summary of the doc comment
+// is used if there is one, with the first letter in lower case unless the
+// first word is an acronym. Otherwise the name of the symbol is used.
+func (symbol *Symbol) Hint() string {
+	summary := docSummary(symbol.doc)
+
+	if summary == "" {
+		return symbol.name
+	}
+
+	first, n := utf8.DecodeRuneInString(summary)
+	second, _ := utf8.DecodeRuneInString(summary[n:])
+
+	if unicode.IsUpper(first) && !unicode.IsUpper(second) {
+		return string(unicode.ToLower(first)) + summary[n:]
+	}
+
+	return summary
+}
+
+// Build the message of a syntax error listing the symbols which are
+// expected, e.g. "expected NUM or an expression (a value or operator chain)".
+func ExpectedHint(symbols []*Symbol) string {
+	hints := make([]string, len(symbols))
+
+	for i, symbol := range symbols {
+		hints[i] = symbol.Hint()
+	}
+
+	switch len(hints) {
+	case 0:
+		return "unexpected input"
+	case 1:
+		return "expected " + hints[0]
+	}
+
+	return "expected " + strings.Join(hints[:len(hints)-1], ", ") + " or " + hints[len(hints)-1]
+}
@@ -0,0 +1,157 @@
+package parse
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDocComment(t *testing.T) {
+	cases := []struct {
+		gap       string
+		lineStart bool
+		expect    string
+	}{
+		{"\n// A number.\n", false, "A number."},
+		{"\n// First line.\n//   Second line.\n  ", false, "First line.\n  Second line."},
+		{"\n/**\n * A block.\n *\n * More.\n */\n", false, "A block.\n\nMore."},
+		{"/** Same line. */ ", true, "Same line."},
+		{" // Trailing comment of the previous token.\n", false, ""},
+		{"\n// Separated by a blank line.\n\n", false, ""},
+		{"\n/* Not a doc comment. */\n", false, ""},
+		{"\n// Old block.\n\n// New block.\n", false, "New block."},
+		{"\n// Line block.\n/** Block. */\n", false, "Block."},
+	}
+
+	for _, c := range cases {
+		if actual := docComment([]byte(c.gap), c.lineStart); actual != c.expect {
+			t.Errorf("Gap %q: expect %q, actual %q", c.gap, c.expect, actual)
+		}
+	}
+}
+
+const docGrammar = `%{
+package calc
+%}
+
+// A number.
+%token <num> NUM
+
+/** Operators. */
+%token PLUS
+	// Subtraction.
+	MINUS
+
+%%
+
+/**
+ * An expression (a value or operator chain).
+ *
+ * Operators are left associative.
+ */
+expr:
+	// Addition.
+	expr PLUS NUM
+|	// Subtraction.
+	expr MINUS NUM
+|	NUM
+;
+
+%%
+`
+
+func parseDocGrammar(t *testing.T) *Lemon {
+	lemon := NewLemonFromBytes("calc.y", []byte(docGrammar), "calc.go")
+	lemon.Parse()
+
+	return lemon
+}
+
+func TestParseDocs(t *testing.T) {
+	lemon := parseDocGrammar(t)
+	symbols := map[string]string{
+		"NUM":   "A number.",
+		"PLUS":  "Operators.",
+		"MINUS": "Subtraction.",
+		"expr":  "An expression (a value or operator chain).\n\nOperators are left associative.",
+	}
+
+	for name, expect := range symbols {
+		if symbol, _ := lemon.symTable.Get(name); symbol.Doc() != expect {
+			t.Errorf("Symbol %s: expect doc %q, actual %q", name, expect, symbol.Doc())
+		}
+	}
+
+	rules := []string{"Addition.", "Subtraction.", ""}
+	i := 0
+
+	for rule := lemon.firstRule; rule != nil; rule = rule.next {
+		if rule.Doc() != rules[i] {
+			t.Errorf("Rule %v: expect doc %q, actual %q", rule, rules[i], rule.Doc())
+		}
+
+		i++
+	}
+}
+
+func TestExpectedHint(t *testing.T) {
+	lemon := parseDocGrammar(t)
+	expr, _ := lemon.symTable.Get("expr")
+	num, _ := lemon.symTable.Get("NUM")
+	plus, _ := lemon.symTable.Get("PLUS")
+
+	if hint := ExpectedHint([]*Symbol{expr}); hint != "expected an expression (a value or operator chain)" {
+		t.Errorf("Unexpected hint: %s", hint)
+	}
+
+	if hint := ExpectedHint([]*Symbol{num, plus, NewSymbol("'+'", Terminal)}); hint != "expected a number, operators or '+'" {
+		t.Errorf("Unexpected hint: %s", hint)
+	}
+}
+
+func TestWriteGoDocs(t *testing.T) {
+	var buf bytes.Buffer
+	lemon := parseDocGrammar(t)
+	lemon.WriteGo(&buf)
+	out := buf.String()
+
+	for _, expect := range []string{
+		"package calc\n",
+		"\t// The end of the input.\n\tyyEOF = 0\n",
+		"\t// A number.\n\tNUM = ",
+		"\t// Subtraction.\n\tMINUS = ",
+		`"expr":  "an expression (a value or operator chain)",`,
+		"func yyExpected(names ...string) string {",
+	} {
+		if !strings.Contains(out, expect) {
+			t.Errorf("Expect %q in generated code:\n%s", expect, out)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	lemon := parseDocGrammar(t)
+	expects := map[ReportFormat][]string{
+		ReportMarkdown: {
+			"| `NUM` | num | A number. |",
+			"### `expr`\n\nAn expression (a value or operator chain).\n\nOperators are left associative.\n",
+			"- `expr ::= expr PLUS NUM` — Addition.\n",
+		},
+		ReportHTML: {
+			"<td><code>NUM</code></td><td>num</td><td>A number.</td>",
+			"<p>Operators are left associative.</p>",
+			"<li><code>expr ::= expr MINUS NUM</code> — Subtraction.</li>",
+		},
+	}
+
+	for format, strs := range expects {
+		var buf bytes.Buffer
+		lemon.WriteReport(&buf, format)
+
+		for _, expect := range strs {
+			if !strings.Contains(buf.String(), expect) {
+				t.Errorf("Expect %q in report:\n%s", expect, buf.String())
+			}
+		}
+	}
+}
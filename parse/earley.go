@@ -0,0 +1,288 @@
+package parse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golemon/util"
+)
+
+// The result of parsing a sentence with the Earley interpreter.
+type EarleyResult struct {
+	Accepted  bool
+	Position  int           // The index of the token which can't come next, or the number of tokens at the end of input
+	Expected  []*Symbol     // The terminals which may come at Position instead, `$` for the end of input
+	Trees     []*Derivation // The parse trees of an accepted sentence, at most the limit
+	Ambiguous bool          // True if the sentence has two parse trees or more
+	Cyclic    bool          // True if a part of the sentence derives itself: it has infinitely many parse trees
+	tokens    []*Symbol
+}
+
+func (result *EarleyResult) String() string {
+	if !result.Accepted {
+		at := "the end of the input"
+
+		if result.Position < len(result.tokens) {
+			at = fmt.Sprintf("token %d `%s`", result.Position+1, result.tokens[result.Position].name)
+		}
+
+		return fmt.Sprintf("Syntax error at %s: %s", at, ExpectedHint(result.Expected))
+	}
+
+	var b strings.Builder
+	b.WriteString("Accepted")
+
+	if result.Ambiguous {
+		b.WriteString(", ambiguous")
+	}
+
+	if result.Cyclic {
+		b.WriteString(", cyclic")
+	}
+
+	for _, tree := range result.Trees {
+		fmt.Fprintf(&b, "\n  %v", tree)
+	}
+
+	return b.String()
+}
+
+// A rule with the number of its symbols recognized, from a token.
+type earleyItem struct {
+	rp     *Rule
+	dot    int
+	origin int
+}
+
+// A non-terminal and the tokens it derives, from one to another.
+type earleySpan struct {
+	symbol   *Symbol
+	from, to int
+}
+
+// An Earley parser: the items of each position in the tokens, and the
+// spans of the non-terminals recognized.
+type earleyParser struct {
+	lemon    *Lemon
+	tokens   []*Symbol
+	lhsRules map[*Symbol][]*Rule
+	sets     [][]earleyItem
+	seen     []map[earleyItem]bool
+	starts   map[earleySpan][]*Rule // The rules completed on each span
+	onPath   map[earleySpan]bool    // The spans whose trees are being built
+	cut      bool                   // True if a tree of a span was not built within itself
+}
+
+// Parse the tokens with the rules of the grammar, whatever the conflicts
+// of its parser: accept or reject them, and build at most `limit` of
+// their parse trees. A tree is not built within itself, so the trees of
+// a cyclic grammar are finite: the sentence is then reported as cyclic and
+// ambiguous.
+func (lemon *Lemon) EarleyParse(tokens []*Symbol, limit int) *EarleyResult {
+	lemon.computeSets()
+	result := &EarleyResult{tokens: tokens}
+	start := lemon.startSymbol()
+
+	if start == nil {
+		return result
+	}
+
+	p := &earleyParser{
+		lemon:    lemon,
+		tokens:   tokens,
+		lhsRules: make(map[*Symbol][]*Rule),
+		sets:     make([][]earleyItem, len(tokens)+1),
+		seen:     make([]map[earleyItem]bool, len(tokens)+1),
+		starts:   make(map[earleySpan][]*Rule),
+		onPath:   make(map[earleySpan]bool),
+	}
+
+	for rp := lemon.firstRule; rp != nil; rp = rp.next {
+		p.lhsRules[rp.lhs] = append(p.lhsRules[rp.lhs], rp)
+	}
+
+	for i := range p.seen {
+		p.seen[i] = make(map[earleyItem]bool)
+	}
+
+	for _, rp := range p.lhsRules[start] {
+		p.add(0, earleyItem{rp, 0, 0})
+	}
+
+	for i := 0; i <= len(tokens); i++ {
+		p.process(i)
+
+		if i < len(tokens) && len(p.sets[i+1]) == 0 {
+			result.Position, result.Expected = i, p.expected(i, start)
+
+			return result
+		}
+	}
+
+	full := earleySpan{start, 0, len(tokens)}
+
+	if len(p.starts[full]) == 0 {
+		result.Position, result.Expected = len(tokens), p.expected(len(tokens), start)
+
+		return result
+	}
+
+	result.Accepted = true
+	result.Trees = p.derive(full, util.Max(limit, 1)+1)
+	result.Cyclic = p.cut
+	result.Ambiguous = len(result.Trees) > 1 || result.Cyclic
+
+	if len(result.Trees) > limit {
+		result.Trees = result.Trees[:limit]
+	}
+
+	return result
+}
+
+// Add an item to the set of a position, if it is new.
+func (p *earleyParser) add(i int, item earleyItem) {
+	if !p.seen[i][item] {
+		p.seen[i][item] = true
+		p.sets[i] = append(p.sets[i], item)
+	}
+}
+
+// Predict, scan and complete the items of a position, as they are added.
+// A nullable non-terminal is also skipped when it is predicted, so the
+// items completed by empty rules need not be completed again.
+func (p *earleyParser) process(i int) {
+	for j := 0; j < len(p.sets[i]); j++ {
+		item := p.sets[i][j]
+
+		if item.dot == item.rp.nrhs {
+			p.complete(i, item)
+			continue
+		}
+
+		next := item.rp.rhs[item.dot]
+
+		switch {
+		case next.IsTerminal():
+			if i < len(p.tokens) && p.tokens[i] == next {
+				p.add(i+1, earleyItem{item.rp, item.dot + 1, item.origin})
+			}
+		default:
+			for _, rp := range p.lhsRules[next] {
+				p.add(i, earleyItem{rp, 0, i})
+			}
+
+			if next.nullable {
+				p.add(i, earleyItem{item.rp, item.dot + 1, item.origin})
+			}
+		}
+	}
+}
+
+// Advance the items which wait for the left hand side of the completed
+// rule where it starts, and record its span.
+func (p *earleyParser) complete(i int, item earleyItem) {
+	span := earleySpan{item.rp.lhs, item.origin, i}
+	p.starts[span] = append(p.starts[span], item.rp)
+
+	for _, waiting := range p.sets[item.origin] {
+		if waiting.dot < waiting.rp.nrhs && waiting.rp.rhs[waiting.dot] == item.rp.lhs {
+			p.add(i, earleyItem{waiting.rp, waiting.dot + 1, waiting.origin})
+		}
+	}
+}
+
+// Get the terminals the items of a position wait for, and `$` if the
+// start symbol derives the tokens before it, in index order.
+func (p *earleyParser) expected(i int, start *Symbol) []*Symbol {
+	found := make(map[*Symbol]bool)
+
+	if len(p.starts[earleySpan{start, 0, i}]) > 0 {
+		found[p.lemon.endSym] = true
+	}
+
+	for _, item := range p.sets[i] {
+		if item.dot < item.rp.nrhs && item.rp.rhs[item.dot].IsTerminal() {
+			found[item.rp.rhs[item.dot]] = true
+		}
+	}
+
+	expected := make([]*Symbol, 0, len(found))
+
+	for symbol := range found {
+		expected = append(expected, symbol)
+	}
+
+	sort.Slice(expected, func(i, j int) bool { return expected[i].index < expected[j].index })
+
+	return expected
+}
+
+// Build at most `limit` parse trees of the span, by the order of the
+// rules, then of the positions where their symbols start.
+func (p *earleyParser) derive(span earleySpan, limit int) []*Derivation {
+	if p.onPath[span] {
+		p.cut = true
+
+		return nil
+	}
+
+	p.onPath[span] = true
+	defer delete(p.onPath, span)
+	rules := append([]*Rule(nil), p.starts[span]...)
+	sort.Slice(rules, func(i, j int) bool { return rules[i].index < rules[j].index })
+	var trees []*Derivation
+
+	for _, rp := range rules {
+		p.split(rp, rp.nrhs, span.from, span.to, limit, nil, func(children []*Derivation) bool {
+			trees = append(trees, &Derivation{Symbol: rp.lhs, Rule: rp, Children: children})
+
+			return len(trees) >= limit
+		})
+
+		if len(trees) >= limit {
+			break
+		}
+	}
+
+	return trees
+}
+
+// Call f with each way the first n symbols of the rule derive the tokens
+// from one position to another, as the trees of the symbols, until it
+// returns true. The symbols are split from the last one, on the items of
+// the rule the sets have, so each split leads to a tree.
+func (p *earleyParser) split(rp *Rule, n int, from int, to int, limit int, children []*Derivation, f func([]*Derivation) bool) bool {
+	if n == 0 {
+		return from == to && f(children)
+	}
+
+	symbol := rp.rhs[n-1]
+	before := earleyItem{rp, n - 1, from}
+
+	if symbol.IsTerminal() {
+		if to == from || p.tokens[to-1] != symbol || !p.seen[to-1][before] {
+			return false
+		}
+
+		return p.split(rp, n-1, from, to-1, limit, append([]*Derivation{{Symbol: symbol}}, children...), f)
+	}
+
+	for m := from; m <= to; m++ {
+		span := earleySpan{symbol, m, to}
+
+		if len(p.starts[span]) == 0 || !p.seen[m][before] {
+			continue
+		}
+
+		// More trees of a symbol than the limit make more trees of the
+		// rule than the limit.
+		for _, tree := range p.derive(span, limit) {
+			if p.split(rp, n-1, from, m, limit, append([]*Derivation{tree}, children...), f) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,121 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+)
+
+func earleyParse(t *testing.T, lemon *Lemon, sentence string, limit int) *EarleyResult {
+	var tokens []*Symbol
+
+	for _, name := range strings.Fields(sentence) {
+		tokens = append(tokens, mustSymbol(t, lemon, name))
+	}
+
+	return lemon.EarleyParse(tokens, limit)
+}
+
+func derivationStrings(trees []*Derivation) []string {
+	strs := make([]string, len(trees))
+
+	for i, tree := range trees {
+		strs[i] = tree.String()
+	}
+
+	return strs
+}
+
+func TestEarleyParse(t *testing.T) {
+	lemon := readGrammar(t, "ambiguous.y", `%%
+e: e PLUS e | e TIMES e | NUM ;
+%%
+`)
+	result := earleyParse(t, lemon, "NUM PLUS NUM TIMES NUM", 10)
+
+	if !result.Accepted || !result.Ambiguous || result.Cyclic {
+		t.Errorf("ambiguous.y: expect an ambiguous sentence, actual %v", result)
+	}
+
+	checkStrings(t, "ambiguous.y trees", []string{
+		"e ::= [ e ::= [ NUM ] PLUS e ::= [ e ::= [ NUM ] TIMES e ::= [ NUM ] ] ]",
+		"e ::= [ e ::= [ e ::= [ NUM ] PLUS e ::= [ NUM ] ] TIMES e ::= [ NUM ] ]",
+	}, derivationStrings(result.Trees))
+
+	// Only the first tree, but it is still known to be ambiguous.
+	result = earleyParse(t, lemon, "NUM PLUS NUM PLUS NUM PLUS NUM", 1)
+
+	if len(result.Trees) != 1 || !result.Ambiguous {
+		t.Errorf("ambiguous.y: expect 1 tree of an ambiguous sentence, actual %v", result)
+	}
+
+	for _, test := range []struct{ sentence, expected string }{
+		{"NUM PLUS PLUS", "Syntax error at token 3 `PLUS`: expected NUM"},
+		{"NUM PLUS", "Syntax error at the end of the input: expected NUM"},
+		{"NUM NUM", "Syntax error at token 2 `NUM`: expected the end of the input, PLUS or TIMES"},
+		{"", "Syntax error at the end of the input: expected NUM"},
+	} {
+		if result := earleyParse(t, lemon, test.sentence, 10); result.String() != test.expected {
+			t.Errorf("ambiguous.y: expect %q for %q, actual %q", test.expected, test.sentence, result)
+		}
+	}
+}
+
+func TestEarleyParseNullable(t *testing.T) {
+	// The trees of the cycle s ::= s are not built within themselves, and
+	// the empty rules are derived.
+	lemon := readGrammar(t, "nullable.y", `%%
+s: s | a X b ;
+a: | Y ;
+b: | a ;
+%%
+`)
+	result := earleyParse(t, lemon, "X", 10)
+	checkStrings(t, "nullable.y trees", []string{
+		"s ::= [ a ::= [ ε ] X b ::= [ ε ] ]",
+		"s ::= [ a ::= [ ε ] X b ::= [ a ::= [ ε ] ] ]",
+	}, derivationStrings(result.Trees))
+
+	result = earleyParse(t, lemon, "Y X Y", 10)
+	checkStrings(t, "nullable.y trees", []string{
+		"s ::= [ a ::= [ Y ] X b ::= [ a ::= [ Y ] ] ]",
+	}, derivationStrings(result.Trees))
+
+	if !result.Cyclic || !result.Ambiguous {
+		t.Errorf("nullable.y: expect a cyclic sentence, actual %v", result)
+	}
+}
+
+// `s ::= s s` derives s from s with an empty s: the trees cut there are
+// reported.
+func TestEarleyParseCyclic(t *testing.T) {
+	lemon := readGrammar(t, "cyclic.y", `%%
+s: s s | A | ;
+%%
+`)
+	result := earleyParse(t, lemon, "A A", 10)
+
+	if !result.Accepted || !result.Cyclic || !result.Ambiguous || len(result.Trees) == 0 {
+		t.Errorf("cyclic.y: expect a cyclic sentence, actual %v", result)
+	}
+
+	if result = earleyParse(t, lemon, "A", 10); !result.Cyclic {
+		t.Errorf("cyclic.y: expect a cyclic sentence, actual %v", result)
+	}
+}
+
+func TestEarleySameLanguage(t *testing.T) {
+	lemon := buildMode(t, "expr.y", exprGrammar, LALR)
+	var terminals []*Symbol
+
+	for _, name := range []string{"PLUS", "TIMES", "LPAREN", "RPAREN", "ID"} {
+		terminals = append(terminals, mustSymbol(t, lemon, name))
+	}
+
+	eachSentence(terminals, 5, func(tokens []*Symbol) {
+		result := lemon.EarleyParse(tokens, 2)
+
+		if result.Accepted != accepts(lemon, tokens) || result.Accepted && len(result.Trees) != 1 {
+			t.Errorf("expr.y: %s: %v", strings.Join(symbolNames(tokens), " "), result)
+		}
+	})
+}
@@ -0,0 +1,473 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Write a GLR parser: the tables keep every action the precedence leaves
+// on a lookahead, and the parser follows all of them on a graph-structured
+// stack. The code of the rules runs once the parse is deterministic.
+func (lemon *Lemon) writeGLR(buf *bytes.Buffer) {
+	prefix := lemon.prefix()
+	lemon.writeLexerTypes(buf)
+	fmt.Fprintf(buf, glrParser, prefix, lemon.symTable.TerminalCount(), -lemon.acceptRule.index-1)
+	codes := lemon.writeTokenTables(buf)
+
+	fmt.Fprintf(buf, "\n// The rules, by index.\nvar %sRules = []%sRule{\n", prefix, prefix)
+
+	for rp := lemon.firstRule; rp != nil; rp = rp.next {
+		fmt.Fprintf(buf, "\t%d: {%d, %d, %s, %s},\n", rp.index, rp.lhs.index, rp.nrhs, strconv.Quote(rp.lhs.name),
+			strconv.Quote(rp.lhs.name+" ::= "+rp.rhsString()))
+	}
+
+	fmt.Fprintf(buf, "}\n\n// The actions of each state on the token codes: a state to shift to,\n"+
+		"// or -1 minus a rule to reduce.\nvar %sActions = []map[int][]int{\n", prefix)
+
+	for _, stp := range lemon.sortedState {
+		var entries []string
+
+		for i := 0; i < len(stp.ap); {
+			sp := stp.ap[i].sp
+			var actions []string
+
+			for ; i < len(stp.ap) && stp.ap[i].sp == sp; i++ {
+				ap := &stp.ap[i]
+
+				switch ap.actionType {
+				case Shift:
+					if sp.IsTerminal() {
+						actions = append(actions, strconv.Itoa(ap.stp.index))
+					}
+				case Accept:
+					actions = append(actions, strconv.Itoa(-lemon.acceptRule.index-1))
+				case Reduce, Conflict, LookaheadDecided, NotUsed:
+					actions = append(actions, strconv.Itoa(-ap.rp.index-1))
+				}
+			}
+
+			if len(actions) > 0 {
+				entries = append(entries, fmt.Sprintf("%d: {%s}", codes[sp], strings.Join(actions, ", ")))
+			}
+		}
+
+		fmt.Fprintf(buf, "\t{%s},\n", strings.Join(entries, ", "))
+	}
+
+	fmt.Fprintf(buf, "}\n\n// The state after each non-terminal, by state.\nvar %sGotos = []map[int]int{\n", prefix)
+
+	for _, stp := range lemon.sortedState {
+		var entries []string
+
+		for _, ap := range stp.ap {
+			if ap.actionType == Shift && ap.sp.IsNonTerminal() {
+				entries = append(entries, fmt.Sprintf("%d: %d", ap.sp.index, ap.stp.index))
+			}
+		}
+
+		fmt.Fprintf(buf, "\t{%s},\n", strings.Join(entries, ", "))
+	}
+
+	buf.WriteString("}\n")
+	lemon.writeRuleActions(buf)
+
+	if extra := strings.TrimSpace(lemon.extraCode); extra != "" {
+		buf.WriteString("\n" + extra + "\n")
+	}
+}
+
+// Write the function which runs the code of the rules, by index.
+func (lemon *Lemon) writeRuleActions(buf *bytes.Buffer) {
+	prefix := lemon.prefix()
+	fmt.Fprintf(buf, "\n// If set, called with each rule before its code runs, e.g. to trace the\n"+
+		"// parse.\nvar %[1]sTrace func(rule RuleID)\n\n"+
+		"// Run the code of the rule on the values of its symbols, in yyS[1:],\n"+
+		"// to set the value of the rule.\nfunc %[1]sAction(yyRule int, yyS []%[1]sSymType, yyVAL *%[1]sSymType) {\n"+
+		"\tif %[1]sTrace != nil {\n\t\t%[1]sTrace(RuleID(yyRule))\n\t}\n\n\tswitch yyRule {\n", prefix)
+
+	for rp := lemon.firstRule; rp != nil; rp = rp.next {
+		if code := strings.TrimSpace(rp.code); code != "" {
+			fmt.Fprintf(buf, "\tcase %d: // %s ::= %s\n\t\t%s\n", rp.index, rp.lhs.name, rp.rhsString(), lemon.translateCode(rp, code))
+		}
+	}
+
+	buf.WriteString("\t}\n}\n")
+}
+
+// The parser, apart from the tables and the code of the rules.
+const glrParser = `
+// A rule: the index of its left hand side, its length, and its text.
+type %[1]sRule struct {
+	lhs  int
+	n    int
+	name string
+	text string
+}
+
+// A derivation of an ambiguous part of the input: its rule and value.
+type %[1]sAlternative struct {
+	Rule  RuleID
+	Value %[1]sSymType
+}
+
+// A lexer which also chooses or merges the values of the derivations of
+// an ambiguous part of the input. Without it, an ambiguity is an error.
+type %[1]sAmbiguityHandler interface {
+	Ambiguity(symbol string, alternatives []%[1]sAlternative) %[1]sSymType
+}
+
+// The value of a symbol, computed once the parse is deterministic: the
+// value of a token, the values of the symbols of a rule, or the
+// derivations of the same tokens by the same non-terminal.
+type %[1]sValue struct {
+	rule int          // The rule, or -1
+	kids []*%[1]sValue // The values of the symbols of the rule
+	alts []*%[1]sValue // The derivations of an ambiguity
+	val  %[1]sSymType
+	done  bool
+	busy  bool // True while the value is computed, to break cycles
+	clean bool // True if no ambiguity is left to compute in it
+}
+
+// A node of the graph-structured stack: a state, and the edges to the
+// nodes below with the value of the symbol in between.
+type %[1]sNode struct {
+	state int
+	edges []*%[1]sEdge
+}
+
+type %[1]sEdge struct {
+	to      *%[1]sNode
+	value   *%[1]sValue
+	visited bool // True once its value is computed
+}
+
+// A GLR parser: the stacks of all the parses of the input so far share
+// their nodes.
+type %[1]sGLR struct {
+	lex    %[1]sLexer
+	token  int         // The token code of the lookahead, or -1 if unknown
+	lval   %[1]sSymType // The value of the lookahead
+	heads  []*%[1]sNode // The tops of the stacks
+}
+
+// Parse the input of the lexer. Return 0 on success, 1 on a syntax error
+// or an ambiguity the lexer doesn't handle.
+func %[1]sParse(lex %[1]sLexer) int {
+	p := &%[1]sGLR{lex: lex, heads: []*%[1]sNode{{state: 0}}}
+
+	for {
+		p.next()
+		p.reduce()
+
+		if p.token == %[1]sEOF {
+			for _, head := range p.heads {
+				for _, action := range %[1]sActions[head.state][%[1]sEOF] {
+					if action == %[3]d {
+						// The code of the rules doesn't run on a parse
+						// which fails.
+						if amb := p.ambiguity(head.edges[0].value); amb != nil {
+							p.lex.Error(%[1]sAmbiguityMessage(amb))
+
+							return 1
+						}
+
+						p.eval(head.edges[0].value)
+
+						return 0
+					}
+				}
+			}
+		}
+
+		if !p.shift() {
+			return 1
+		}
+
+		p.evalDeterministic()
+	}
+}
+
+// Read the next token. The lexer returns 0 at the end of the input, and
+// codes beyond the token codes are characters.
+func (p *%[1]sGLR) next() {
+	p.lval = %[1]sSymType{}
+	p.token = p.lex.Lex(&p.lval)
+
+	switch {
+	case p.token == 0:
+		p.token = %[1]sEOF
+	case p.token >= %[2]d || p.token < 0:
+		if token, ok := %[1]sLiterals[p.token]; ok {
+			p.token = token
+		} else {
+			p.token = -1
+		}
+	}
+}
+
+// Do the reductions of all the stacks on the lookahead. A reduction may
+// add an edge to a node reduced before, so they are done again until no
+// node or edge is added. The same derivation is only kept once.
+func (p *%[1]sGLR) reduce() {
+	for changed := true; changed; {
+		changed = false
+
+		for i := 0; i < len(p.heads); i++ {
+			head := p.heads[i]
+
+			for _, action := range %[1]sActions[head.state][p.token] {
+				if action >= 0 || action == %[3]d {
+					continue
+				}
+
+				rule := -action - 1
+				p.paths(head, %[1]sRules[rule].n, nil, func(below *%[1]sNode, kids []*%[1]sValue) {
+					if p.goTo(below, rule, kids) {
+						changed = true
+					}
+				})
+			}
+		}
+	}
+}
+
+// Call f with each node n edges below the node, and the values of the
+// edges in between, from the lowest.
+func (p *%[1]sGLR) paths(node *%[1]sNode, n int, values []*%[1]sValue, f func(*%[1]sNode, []*%[1]sValue)) {
+	if n == 0 {
+		f(node, values)
+
+		return
+	}
+
+	for _, edge := range node.edges {
+		p.paths(edge.to, n-1, append([]*%[1]sValue{edge.value}, values...), f)
+	}
+}
+
+// Push the left hand side of the rule on the node. A top with the state
+// after it gets an edge to the node, or another derivation on the edge it
+// has. Return true if a top or an edge is added.
+func (p *%[1]sGLR) goTo(below *%[1]sNode, rule int, kids []*%[1]sValue) bool {
+	state := %[1]sGotos[below.state][%[1]sRules[rule].lhs]
+	value := &%[1]sValue{rule: rule, kids: kids}
+
+	for _, head := range p.heads {
+		if head.state != state {
+			continue
+		}
+
+		for _, edge := range head.edges {
+			if edge.to == below {
+				edge.value.pack(value)
+
+				return false
+			}
+		}
+
+		head.edges = append(head.edges, &%[1]sEdge{to: below, value: value})
+
+		return true
+	}
+
+	p.heads = append(p.heads, &%[1]sNode{state: state, edges: []*%[1]sEdge{{to: below, value: value}}})
+
+	return true
+}
+
+// Add another derivation of the same tokens to the value.
+func (v *%[1]sValue) pack(other *%[1]sValue) {
+	alts := v.alts
+
+	if alts == nil {
+		alts = []*%[1]sValue{v}
+	}
+
+	for _, alt := range alts {
+		if alt.rule == other.rule && %[1]sSameValues(alt.kids, other.kids) {
+			return
+		}
+	}
+
+	if v.alts == nil {
+		first := *v
+		*v = %[1]sValue{rule: -1, alts: []*%[1]sValue{&first}}
+	}
+
+	v.alts = append(v.alts, other)
+}
+
+// Check the values are the same ones.
+func %[1]sSameValues(x, y []*%[1]sValue) bool {
+	if len(x) != len(y) {
+		return false
+	}
+
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Shift the lookahead on the stacks which can, the others are dropped.
+// Report a syntax error if none can.
+func (p *%[1]sGLR) shift() bool {
+	var heads []*%[1]sNode
+	next := make(map[int]*%[1]sNode)
+	value := &%[1]sValue{rule: -1, val: p.lval, done: true}
+
+	for _, head := range p.heads {
+		for _, action := range %[1]sActions[head.state][p.token] {
+			if action < 0 {
+				continue
+			}
+
+			node, ok := next[action]
+
+			if !ok {
+				node = &%[1]sNode{state: action}
+				next[action] = node
+				heads = append(heads, node)
+			}
+
+			node.edges = append(node.edges, &%[1]sEdge{to: head, value: value})
+		}
+	}
+
+	if len(heads) == 0 {
+		p.fail()
+
+		return false
+	}
+
+	p.heads = heads
+
+	return true
+}
+
+// Report a syntax error: the lookahead is none of the tokens the stacks
+// expect.
+func (p *%[1]sGLR) fail() {
+	expected := make([]bool, len(%[1]sTerminalNames))
+	var names []string
+
+	for _, head := range p.heads {
+		for token := range %[1]sActions[head.state] {
+			expected[token] = true
+		}
+	}
+
+	for token, ok := range expected {
+		if ok {
+			names = append(names, %[1]sTerminalNames[token])
+		}
+	}
+
+	p.lex.Error(%[1]sExpected(names...))
+}
+
+// Compute the values of the stack while there is only one: from the top,
+// until the stack splits below, or a value has an ambiguity the lexer
+// doesn't handle. The parse then fails at the end of the input.
+func (p *%[1]sGLR) evalDeterministic() {
+	if len(p.heads) != 1 {
+		return
+	}
+
+	for node := p.heads[0]; len(node.edges) == 1 && !node.edges[0].visited; node = node.edges[0].to {
+		if p.ambiguity(node.edges[0].value) != nil {
+			return
+		}
+
+		node.edges[0].visited = true
+		p.eval(node.edges[0].value)
+	}
+}
+
+// Find an ambiguity the lexer doesn't handle in a value not computed yet,
+// or nil.
+func (p *%[1]sGLR) ambiguity(v *%[1]sValue) *%[1]sValue {
+	if _, ok := p.lex.(%[1]sAmbiguityHandler); ok || v.done || v.clean {
+		return nil
+	}
+
+	if v.alts != nil {
+		return v
+	}
+
+	// A value within itself is clean until one of its kids is not.
+	v.clean = true
+
+	for _, kid := range v.kids {
+		if amb := p.ambiguity(kid); amb != nil {
+			v.clean = false
+
+			return amb
+		}
+	}
+
+	return nil
+}
+
+// Get the error message of an ambiguity.
+func %[1]sAmbiguityMessage(v *%[1]sValue) string {
+	msg := "syntax is ambiguous: " + %[1]sRules[v.alts[0].rule].name + " derives the same tokens by "
+
+	for i, alt := range v.alts {
+		if i > 0 {
+			msg += " and "
+		}
+
+		msg += "` + "`" + `" + %[1]sRules[alt.rule].text + "` + "`" + `"
+	}
+
+	return msg
+}
+
+// Compute a value: run the code of its rule, or let the lexer choose
+// between the derivations of an ambiguity. An ambiguity is only computed
+// with a lexer which handles it.
+func (p *%[1]sGLR) eval(v *%[1]sValue) %[1]sSymType {
+	if v.done || v.busy {
+		return v.val
+	}
+
+	v.busy = true
+
+	if v.alts != nil {
+		alternatives := make([]%[1]sAlternative, len(v.alts))
+
+		for i, alt := range v.alts {
+			alternatives[i] = %[1]sAlternative{RuleID(alt.rule), p.eval(alt)}
+		}
+
+		v.val = p.lex.(%[1]sAmbiguityHandler).Ambiguity(%[1]sRules[v.alts[0].rule].name, alternatives)
+	} else {
+		yyS := make([]%[1]sSymType, len(v.kids)+1)
+
+		for i, kid := range v.kids {
+			yyS[i+1] = p.eval(kid)
+		}
+
+		// The value of the rule is the one of its first symbol unless
+		// the code sets it.
+		if len(v.kids) > 0 {
+			v.val = yyS[1]
+		}
+
+		%[1]sAction(v.rule, yyS, &v.val)
+	}
+
+	v.busy, v.done = false, true
+	v.kids, v.alts = nil, nil
+
+	return v.val
+}
+
+`
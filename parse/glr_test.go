@@ -0,0 +1,252 @@
+package parse
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// An ambiguous grammar: the lexer with an Ambiguity method chooses the
+// derivation of the sums, the other one reports them.
+const sumGrammar = `%{
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+%}
+%union {
+	s string
+}
+%type <s> e
+%token <s> NUM
+%glr
+%%
+top: e { fmt.Println($1) } ;
+e: e '+' e { $$ = "(" + $1 + "+" + $3 + ")" } | NUM ;
+%%
+type lexer struct {
+	tokens []string
+}
+
+func (l *lexer) Lex(lval *yySymType) int {
+	if len(l.tokens) == 0 {
+		return 0
+	}
+
+	token := l.tokens[0]
+	l.tokens = l.tokens[1:]
+
+	if token == "+" {
+		return '+'
+	}
+
+	lval.s = token
+
+	return NUM
+}
+
+func (l *lexer) Error(s string) {
+	fmt.Println(s)
+}
+
+type chooser struct {
+	lexer
+}
+
+func (c *chooser) Ambiguity(symbol string, alternatives []yyAlternative) yySymType {
+	var values []string
+
+	for _, alt := range alternatives {
+		values = append(values, alt.Value.s)
+	}
+
+	fmt.Println(symbol, strings.Join(values, " "))
+
+	return alternatives[len(alternatives)-1].Value
+}
+
+func main() {
+	fmt.Println(yyParse(&chooser{lexer{strings.Fields("1 + 2 + 3")}}))
+	fmt.Println(yyParse(&lexer{strings.Fields("1 + 2")}))
+	fmt.Println(yyParse(&lexer{strings.Fields("1 + 2 + 3")}))
+	fmt.Println(yyParse(&lexer{strings.Fields("1 + +")}))
+}
+`
+
+// LR(2): the code of the rules of the stacks which are dropped never runs,
+// and the code of the others runs in order.
+const lr2GLRGrammar = `%{
+package main
+
+import "fmt"
+%}
+%glr
+%%
+s: a X Y { fmt.Println("s ::= a X Y") } | b X Z { fmt.Println("s ::= b X Z") } | W X V { fmt.Println("s ::= W X V") } ;
+a: W { fmt.Println("a ::= W") } ;
+b: W { fmt.Println("b ::= W") } ;
+%%
+type lexer struct {
+	tokens []int
+}
+
+func (l *lexer) Lex(lval *yySymType) int {
+	if len(l.tokens) == 0 {
+		return 0
+	}
+
+	token := l.tokens[0]
+	l.tokens = l.tokens[1:]
+
+	return token
+}
+
+func (l *lexer) Error(s string) {
+	fmt.Println(s)
+}
+
+func main() {
+	fmt.Println(yyParse(&lexer{[]int{W, X, Y}}))
+	fmt.Println(yyParse(&lexer{[]int{W, X, Z}}))
+	fmt.Println(yyParse(&lexer{[]int{W, X, V}}))
+	fmt.Println(yyParse(&lexer{[]int{W, X, W}}))
+}
+`
+
+// String literals: the lexer returns `"+"` as a character, and `"**"` by
+// its token constant. The end of input isn't one of them.
+const literalGLRGrammar = `%{
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+%}
+%union {
+	s string
+}
+%type <s> e
+%token <s> N
+%left "+"
+%right "**"
+%glr
+%%
+top: e { fmt.Println($1) } ;
+e: e "+" e { $$ = "(" + $1 + "+" + $3 + ")" } | e "**" e { $$ = "(" + $1 + "**" + $3 + ")" } | N ;
+%%
+type lexer struct {
+	tokens []string
+}
+
+func (l *lexer) Lex(lval *yySymType) int {
+	if len(l.tokens) == 0 {
+		return 0
+	}
+
+	token := l.tokens[0]
+	l.tokens = l.tokens[1:]
+
+	switch token {
+	case "+":
+		return '+'
+	case "**":
+		return LIT_2A_2A
+	}
+
+	lval.s = token
+
+	return N
+}
+
+func (l *lexer) Error(s string) {
+	fmt.Println(s)
+}
+
+func main() {
+	fmt.Println(yyParse(&lexer{strings.Fields("1")}))
+	fmt.Println(yyParse(&lexer{strings.Fields("1 + 2 ** 3 ** 4 + 5")}))
+	fmt.Println(yyParse(&lexer{strings.Fields("1 +")}))
+}
+`
+
+// Generate the GLR parser of the grammar, run it and get its output.
+func runGLR(t *testing.T, name string, src string) []string {
+	t.Helper()
+	lemon := buildActions(t, name, src, LALR)
+
+	if !lemon.glr {
+		t.Fatalf("%s: expect `%%glr`", name)
+	}
+
+	return runParser(t, name, lemon)
+}
+
+// Generate the parser, check it with go vet, run it and get its output.
+func runParser(t *testing.T, name string, lemon *Lemon) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	lemon.WriteGo(&buf)
+
+	if testing.Short() {
+		t.Skip("the generated parser is not run in short mode")
+	}
+
+	gobin, err := exec.LookPath("go")
+
+	if err != nil {
+		t.Skip("the go command is not found")
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module parser\n\ngo 1.19\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "parser.go"), buf.Bytes(), 0644)
+	var out []byte
+
+	for _, args := range [][]string{{"vet", "."}, {"run", "."}} {
+		cmd := exec.Command(gobin, args...)
+		cmd.Dir = dir
+
+		if out, err = cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s: go %s: %v: %s\n%s", name, args[0], err, out, buf.String())
+		}
+	}
+
+	return strings.Split(strings.TrimSpace(string(out)), "\n")
+}
+
+func TestGLRAmbiguity(t *testing.T) {
+	checkStrings(t, "sum.y", []string{
+		"e ((1+2)+3) (1+(2+3))",
+		"(1+(2+3))", "0",
+		"(1+2)", "0",
+		"syntax is ambiguous: e derives the same tokens by `e ::= e '+' e` and `e ::= e '+' e`", "1",
+		"syntax error: expected NUM", "1",
+	}, runGLR(t, "sum.y", sumGrammar))
+}
+
+func TestGLRDeferredActions(t *testing.T) {
+	checkStrings(t, "lr2.y", []string{
+		"a ::= W", "s ::= a X Y", "0",
+		"b ::= W", "s ::= b X Z", "0",
+		"s ::= W X V", "0",
+		"syntax error: expected V, Y or Z", "1",
+	}, runGLR(t, "lr2.y", lr2GLRGrammar))
+}
+
+func TestGLRStringLiterals(t *testing.T) {
+	checkStrings(t, "literal.y", []string{
+		"1", "0",
+		"((1+(2**(3**4)))+5)", "0",
+		"syntax error: expected N", "1",
+	}, runGLR(t, "literal.y", literalGLRGrammar))
+}
+
+func TestGLRTrace(t *testing.T) {
+	checkStrings(t, "trace.y", traceLROutput, runGLR(t, "trace.y", strings.Replace(traceLRGrammar, "%%\n", "%glr\n%%\n", 1)))
+}
@@ -0,0 +1,86 @@
+package parse
+
+import "github.com/golemon/util"
+
+// Turn the backward propagation links made by buildShifts into forward
+// links: the follow set of a configuration propagates to the
+// configuration it becomes in the next state.
+func (lemon *Lemon) findLinks() {
+	for _, stp := range lemon.sortedState {
+		for _, cfp := range stp.cfp {
+			for plp := cfp.bplp; plp != nil; plp = plp.next {
+				addPLink(&plp.cfp.fplp, cfp)
+			}
+		}
+	}
+}
+
+// Propagate the follow sets along the forward links until nothing
+// changes. The follow sets start with the lookaheads generated
+// spontaneously by the closures, so at the end they are the LALR(1)
+// lookaheads of the configurations.
+func (lemon *Lemon) findFollowSets() {
+	var configs []*Config
+
+	for _, stp := range lemon.sortedState {
+		configs = append(configs, stp.cfp...)
+	}
+
+	propagate(configs)
+}
+
+// Propagate the follow sets of the configurations along their forward
+// links until nothing changes.
+func propagate(configs []*Config) {
+	stack := make([]*Config, len(configs))
+
+	for i, cfp := range configs {
+		cfp.incomplete = true
+		stack[len(configs)-1-i] = cfp
+	}
+
+	for len(stack) > 0 {
+		cfp := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		cfp.incomplete = false
+
+		for plp := cfp.fplp; plp != nil; plp = plp.next {
+			if plp.cfp.fws.AddSet(cfp.fws) && !plp.cfp.incomplete {
+				plp.cfp.incomplete = true
+				stack = append(stack, plp.cfp)
+			}
+		}
+	}
+}
+
+// Build the LALR(1) automaton: the LR(0) states, with the lookaheads of
+// their configurations.
+func (lemon *Lemon) buildLALR() {
+	lemon.findStates(LALR)
+	lemon.findLinks()
+	lemon.findFollowSets()
+}
+
+// Get the terminals of a follow set, in index order.
+func (lemon *Lemon) terminalsOf(set util.BitSet) []*Symbol {
+	sortedSymbols := lemon.symTable.SortedSymbols()
+	symbols := make([]*Symbol, 0, set.Len())
+
+	for _, i := range set.AsSlice() {
+		symbols = append(symbols, sortedSymbols[i])
+	}
+
+	return symbols
+}
+
+// Get the lookaheads on which the rule is reduced in the state, in index
+// order. They are empty if the state has no configuration `rule *`.
+func (lemon *Lemon) Lookaheads(stp *State, rule *Rule) []*Symbol {
+	for _, cfp := range stp.cfp {
+		if cfp.rp == rule && cfp.dot == rule.nrhs {
+			return lemon.terminalsOf(cfp.fws)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package parse
+
+import (
+	"testing"
+)
+
+func buildLALR(t *testing.T, name string, src string) *Lemon {
+	lemon := readGrammar(t, name, src)
+	lemon.buildLALR()
+
+	return lemon
+}
+
+// Find the state reached from state 0 by the symbols.
+func walk(t *testing.T, lemon *Lemon, names ...string) *State {
+	stp := lemon.States()[0]
+
+	for _, name := range names {
+		if stp = stp.Goto(mustSymbol(t, lemon, name)); stp == nil {
+			t.Fatalf("No transition on %s", name)
+		}
+	}
+
+	return stp
+}
+
+func checkLookaheads(t *testing.T, lemon *Lemon, stp *State, lhs string, label string, expects []string) {
+	rule, ok := lemon.FindRule(lhs, label)
+
+	if !ok {
+		t.Fatalf("Rule %s#%s not found", lhs, label)
+	}
+
+	checkStrings(t, "lookaheads of "+rule.String(), expects, symbolNames(lemon.Lookaheads(stp, rule)))
+}
+
+func TestLookaheadsExpr(t *testing.T) {
+	lemon := buildLALR(t, "expr.y", `%{
+%}
+%token PLUS TIMES LPAREN RPAREN ID
+%%
+e: e PLUS t #Add | t #Term ;
+t: t TIMES f #Mul | f #Factor ;
+f: LPAREN e RPAREN #Paren | ID #Id ;
+%%
+`)
+
+	// The states after t and ID are shared by both contexts.
+	checkLookaheads(t, lemon, walk(t, lemon, "t"), "e", "Term", []string{"$", "PLUS", "RPAREN"})
+	checkLookaheads(t, lemon, walk(t, lemon, "LPAREN", "t"), "e", "Term", []string{"$", "PLUS", "RPAREN"})
+
+	all := []string{"$", "PLUS", "RPAREN", "TIMES"}
+	checkLookaheads(t, lemon, walk(t, lemon, "ID"), "f", "Id", all)
+	checkLookaheads(t, lemon, walk(t, lemon, "LPAREN", "ID"), "f", "Id", all)
+	checkLookaheads(t, lemon, walk(t, lemon, "e", "PLUS", "t", "TIMES", "f"), "t", "Mul", all)
+
+	// Only completed configurations have lookaheads.
+	checkLookaheads(t, lemon, walk(t, lemon, "e"), "e", "Add", nil)
+}
+
+// The grammar is LALR(1) but not SLR(1): `=` follows R, but not in the
+// state where `R ::= L` competes with the shift of `=`.
+func TestLookaheadsNotSLR(t *testing.T) {
+	lemon := buildLALR(t, "assign.y", `%{
+%}
+%token EQ STAR ID
+%%
+s: l EQ r #Assign | r #Value ;
+l: STAR r #Deref | ID #Id ;
+r: l #L ;
+%%
+`)
+
+	checkStrings(t, "FOLLOW(r)", []string{"$", "EQ"}, symbolNames(lemon.Follow(mustSymbol(t, lemon, "r"))))
+	checkLookaheads(t, lemon, walk(t, lemon, "l"), "r", "L", []string{"$"})
+	checkLookaheads(t, lemon, walk(t, lemon, "STAR", "l"), "r", "L", []string{"$", "EQ"})
+
+	// The state is shared with the one after STAR l.
+	checkLookaheads(t, lemon, walk(t, lemon, "l", "EQ", "l"), "r", "L", []string{"$", "EQ"})
+
+	if accept := lemon.Lookaheads(walk(t, lemon, "s"), lemon.acceptRule); len(accept) != 1 || accept[0] != lemon.endSym {
+		t.Errorf("Expect to accept on $, actual %q", symbolNames(accept))
+	}
+}
+
+// Lookaheads propagate through nullable symbols.
+func TestLookaheadsNullable(t *testing.T) {
+	lemon := buildLALR(t, "nullable.y", `%{
+%}
+%%
+s: a b X #S ;
+a: Y #Y | #Empty ;
+b: Z #Z | #Empty ;
+%%
+`)
+
+	checkLookaheads(t, lemon, lemon.States()[0], "a", "Empty", []string{"X", "Z"})
+	checkLookaheads(t, lemon, walk(t, lemon, "Y"), "a", "Y", []string{"X", "Z"})
+	checkLookaheads(t, lemon, walk(t, lemon, "a"), "b", "Empty", []string{"X"})
+}
@@ -3,8 +3,7 @@ package parse
 import (
 	"fmt"
 	"os"
-
-	"github.com/golemon/util"
+	"time"
 )
 
 // The state vector for the entire parser generator is recorded as
@@ -16,245 +15,264 @@ import (
 
 // preccounter:
 type Lemon struct {
-	sortedState *[]State // Table of states sorted by state number
-	rule        []Rule   // List of all rules
-	nstate      int      // Number of states
-	nrule       int      // Number of rules
-	nsymbol     int      // Number of terminal and nonterminal symbols
-	nterminal   int      // Number of terminal symbols
-	errSym      *Symbol  // The error symbol
-	name        string   // Name of the generated parser
-	arg         string   // Declaration of the 3th argument to parser
-	tokenType   string   // Type of terminal symbols in the parser stack
-	varType     string   // The default type of non-terminal symbols
-	start       string   // Name of the start symbol for the grammar
-	include     string   // Code to put at the start of the C file
-	includeLn   int      // Line nunmber for start of include code
-	errorCode   string   // Code to execyte when an error is seen
-	errorLn     int      // Line number for start of error code
-	failure     string   // Code to execute on parser failure
-	failureLn   int      // Line number for start of failure code
-	accept      string   // Code to execute when the parser accepts
-	acceptLn    int      // Line number for the start of accept code
-	extraCode   string   // Code appended to the generated file
-	extraCodeLn int      // Line number for the start of the extra code
-	overflow    string   // Code to execute on a stack overflow
-	overflowLn  int      // Line number for start of overflow code
-	tokenDest   string   // Code to execute to destroy token data
-	tokenDestLn int      // Line number for token destroyer code
-	varDest     string   // Code for the default non-terminal destructor code
-	varDestLn   int      // Line number for default non-term destructor code
-	infile      string   // Name of the input file
-	outfile     string   // Name of the current output file
-	tokenPrefix string   // A prefix added to token names in the .h file
-	nconflict   int      // Number of parsing conflicts
-	tableSize   int      // Size of the parse table
-	basisFlag   bool     // Print only basis configurations
-	argv0       string   // Name of the program
-	runeBuf     *RuneBuffer
-	lineno      int
+	sortedState     []*State             // Table of states sorted by state number
+	rule            []Rule               // List of all rules
+	nstate          int                  // Number of states
+	nrule           int                  // Number of rules
+	nsymbol         int                  // Number of terminal and nonterminal symbols
+	nterminal       int                  // Number of terminal symbols
+	errSym          *Symbol              // The error symbol
+	name            string               // Name of the generated parser
+	arg             string               // Declaration of the 3th argument to parser
+	tokenType       string               // Type of terminal symbols in the parser stack
+	varType         string               // The default type of non-terminal symbols
+	start           string               // Name of the start symbol for the grammar
+	startPos        Position             // Position of the `%start` declaration
+	include         string               // Code to put at the start of the C file
+	includeLn       int                  // Line nunmber for start of include code
+	errorCode       string               // Code to execyte when an error is seen
+	errorLn         int                  // Line number for start of error code
+	failure         string               // Code to execute on parser failure
+	failureLn       int                  // Line number for start of failure code
+	accept          string               // Code to execute when the parser accepts
+	acceptLn        int                  // Line number for the start of accept code
+	extraCode       string               // Code appended to the generated file
+	extraCodeLn     int                  // Line number for the start of the extra code
+	overflow        string               // Code to execute on a stack overflow
+	overflowLn      int                  // Line number for start of overflow code
+	tokenDest       string               // Code to execute to destroy token data
+	tokenDestLn     int                  // Line number for token destroyer code
+	varDest         string               // Code for the default non-terminal destructor code
+	varDestLn       int                  // Line number for default non-term destructor code
+	infile          string               // Name of the input file
+	outfile         string               // Name of the current output file
+	tokenPrefix     string               // A prefix added to token names in the .h file
+	nconflict       int                  // Number of parsing conflicts
+	tableSize       int                  // Size of the parse table
+	actionTable     []tableEntry         // The packed actions of the states, yy_action and yy_lookahead
+	lookaheads      []int                // The lookahead of each symbol in the packed table, by index
+	basisFlag       bool                 // Print only basis configurations
+	argv0           string               // Name of the program
+	src             []byte               // Content of the input file
+	classifier      SymbolClassifier     // Type of symbols neither declared nor defined by a rule
+	symTable        *SymbolTable         // All symbols of the grammar
+	firstRule       *Rule                // First rule of the grammar, the others are linked by next
+	findings        []Finding            // Problems found by the verification of the symbols
+	endSym          *Symbol              // The end of input `$`
+	setsDone        bool                 // True if nullable, FIRST and FOLLOW sets are computed
+	acceptRule      *Rule                // The rule `$accept ::= start` of the augmented grammar
+	lrMode          LRMode               // How the states are built
+	lalrStates      int                  // Number of LALR(1) states, to compare with other modes
+	splits          []StateSplit         // Minimal LR(1) states kept apart, and why
+	slrConflicts    []SLRConflict        // Conflicts of the SLR(1) states the LALR(1) states don't have
+	conflicts       []ParseConflict      // Conflicts not resolved by precedence
+	reported        int                  // Number of findings already printed
+	expectSR        int                  // Number of shift/reduce conflicts declared by `%expect`, or -1
+	expectRR        int                  // Number of reduce/reduce conflicts declared by `%expect-rr`, or -1
+	expectPos       Position             // Where the last of them is declared
+	cexTimeout      time.Duration        // Time limit of the search of a unifying counterexample, 0 for none
+	counterexamples []Counterexample     // Counterexamples of the conflicts, if searched
+	unionCode       string               // The fields of the semantic values, from `%union`
+	ll1             bool                 // True to generate a recursive-descent parser
+	glr             bool                 // True to generate a GLR parser, from `%glr`
+	maxLookahead    int                  // Maximum number of tokens of lookahead to decide conflicts
+	decisions       []*LookaheadDecision // Conflicts tried with more tokens of lookahead
+	verbose         bool                 // True to print the grammar and its FIRST sets while parsing
 }
 
 func NewLemon(infile string, outfile string) *Lemon {
-	fp, err := os.Open(infile)
+	src, err := os.ReadFile(infile)
 
 	if err != nil {
-		errorf(infile, 0, "Fail to open: "+infile)
+		errorf(infile, Position{}, "Fail to open: "+infile)
 	}
 
+	return NewLemonFromBytes(infile, src, outfile)
+}
+
+// Create a generator for a grammar which is already in memory.
+// `infile` is only used in messages.
+func NewLemonFromBytes(infile string, src []byte, outfile string) *Lemon {
 	return &Lemon{
-		lineno:  1,
-		infile:  infile,
-		outfile: outfile,
-		runeBuf: NewRuneBuffer(fp),
+		infile:     infile,
+		outfile:    outfile,
+		src:        src,
+		classifier: ClassifyByCase,
+		expectSR:   -1,
+		expectRR:   -1,
 	}
 }
 
+// Set how symbols neither declared by `%token` nor defined by a rule are
+// classified. Must be called before Parse.
+func (lemon *Lemon) SetSymbolClassifier(classifier SymbolClassifier) {
+	lemon.classifier = classifier
+}
+
+// Print the grammar and the FIRST sets of its symbols while parsing.
+func (lemon *Lemon) SetVerbose(verbose bool) {
+	lemon.verbose = verbose
+}
+
 func (lemon *Lemon) Parse() {
+	lemon.ReadGrammar()
+
+	if lemon.verbose {
+		lemon.Reprint()
+		lemon.PrintFirstSets()
+	}
+
+	lemon.buildStates()
+	lemon.findActions()
+	lemon.decideByLookahead()
+	lemon.reportUnreducedRules()
+	lemon.reportFindings()
+	lemon.findCounterexamples()
+	lemon.reportConflicts()
+	lemon.compressTables()
+	lemon.packTables()
+}
+
+// Read the grammar, without its useless rules, for the tools which don't
+// build the parser. Errors stop the generator.
+func (lemon *Lemon) ReadGrammar() {
+	lemon.read()
+	lemon.removeUselessRules()
+	lemon.updateRulePrecedences()
+	lemon.reportFindings()
+}
+
+// Read the grammar file and verify its symbols. Malformed input stops the
+// generator, while the findings of the verification are only recorded.
+func (lemon *Lemon) read() {
 	ps := NewParserState(lemon)
 	filename := lemon.infile
-	runeBuf := lemon.runeBuf
-	token := NewToken()
+	scanner := NewScanner(lemon.src)
 
-	for {
-		curRune := runeBuf.GetRune()
+	scanner.SetErrorHandler(func(pos Position, msg string) {
+		ps.errorCnt++
+		errorf(filename, pos, "%s", msg)
+	})
 
-		// TODO: verify outside this for loop.
-		if curRune == EOF {
-			break
-		}
+	for token := scanner.Next(); token.Kind != TkEOF; token = scanner.Next() {
+		ps.startTokPos = scanner.Position(token.Start)
+		ps.doc = scanner.Doc()
+		ps.parseOneToken(token, scanner.Text(token))
 
-		// Keep track of the line number.
-		if curRune == NewLine {
-			lemon.lineno++
+		// Everything after the second `%%` is copied as is.
+		if ps.curState == WaitSubRoutine2 {
+			ps.subroutine.Write(scanner.Rest())
+			break
 		}
+	}
 
-		// Skip the space and newline is also a space.
-		// So newline check is done before.
-		if util.IsSpace(curRune) {
-			continue
-		}
+	lemon.findings = ps.symTable.verifyAllSymbols()
+	lemon.symTable = ps.symTable
+	lemon.firstRule = ps.firstRule
+	lemon.include = ps.importCode
+	lemon.unionCode = ps.unionCode
+	lemon.extraCode = ps.subroutine.String()
+	lemon.endSym = ps.symTable.insertEnd()
+}
 
-		// Skip comment.
-		if curRune == '/' {
-			lemon.skipComment()
-			continue
-		}
+// Return the number of rules defined in `.y` file.
+// Note that, given the `expr : expr '+' expr | expr '-' expr`
+// The number of rules will be 2.
+func (lemon *Lemon) RuleCount() int {
+	return lemon.nrule
+}
 
-		ps.startTokLineno = lemon.lineno
-		token.AppendRune(curRune)
-
-		// TODO: `'` and `"`
-		// TODO: check content between ''
-		if curRune == '\'' {
-			for curRune = runeBuf.GetRune(); curRune != EOF && curRune != '\''; curRune = runeBuf.GetRune() {
-				token.AppendRune(curRune)
-			}
-
-			if curRune == EOF {
-				ps.errorCnt++
-				errorf(filename, lemon.lineno, "String starting on this line is not terminated before the end of the file.")
-			} else {
-				token.AppendRune(curRune)
-			}
-		} else if curRune == '<' {
-			for curRune = runeBuf.GetRune(); curRune != EOF && curRune != '>'; curRune = runeBuf.GetRune() {
-				token.AppendRune(curRune)
-			}
-
-			if curRune == EOF {
-				ps.errorCnt++
-				errorf(filename, lemon.lineno, "Type specifier `<type>` on this line is not terminated before the end of the file.")
-			} else {
-				token.AppendRune(curRune)
-			}
-		} else if curRune == '{' {
-			level := 1
-			for curRune = runeBuf.GetRune(); curRune != EOF && (level > 1 || curRune != '}'); curRune = runeBuf.GetRune() {
-				token.AppendRune(curRune)
-
-				if curRune == NewLine {
-					lemon.lineno++
-				} else if curRune == '}' {
-					level--
-				} else if curRune == '{' {
-					level++
-				} else if curRune == '/' {
-					lemon.skipComment()
-				} else if curRune == '\'' || curRune == '"' {
-					var prevRune rune
-					expRune := curRune
-
-					for curRune = runeBuf.GetRune(); curRune != EOF && (curRune != expRune || prevRune == '\\'); curRune = runeBuf.GetRune() {
-						token.AppendRune(curRune)
-
-						if curRune == NewLine {
-							lemon.lineno++
-						}
-
-						if prevRune == '\\' {
-							prevRune = 0
-						} else {
-							prevRune = curRune
-						}
-					}
-
-					if curRune != EOF {
-						token.AppendRune(curRune)
-					}
-				}
-			}
-
-			if curRune == EOF {
-				ps.errorCnt++
-				errorf(lemon.infile, lemon.lineno, "Code starting on this line is not terminated before the end of the file.")
-			} else {
-				token.AppendRune(curRune)
-			}
-		} else if util.IsAlphaNum(curRune) {
-			for curRune = runeBuf.GetRune(); curRune != EOF && (util.IsAlphaNum(curRune) || curRune == '_'); curRune = runeBuf.GetRune() {
-				token.AppendRune(curRune)
-			}
-
-			if curRune != EOF {
-				runeBuf.UngetRune(curRune)
-			}
-		}
+// Get the rules of the grammar, in the order of the grammar file.
+func (lemon *Lemon) Rules() []*Rule {
+	var rules []*Rule
 
-		ps.parseOneToken(token)
-		token.Reset()
+	for rp := lemon.firstRule; rp != nil; rp = rp.next {
+		rules = append(rules, rp)
 	}
 
-	ps.Reprint()
-	ps.PrintFirstSets()
+	return rules
 }
 
-// Skip over space.
-func (lemon *Lemon) skipSpace() {
-	var r rune
-	lineno := 0
-	runeBuf := lemon.runeBuf
+// Get the symbols of the grammar, sorted by name. The end of input `$`
+// is the first one.
+func (lemon *Lemon) Symbols() []*Symbol {
+	return lemon.symTable.SortedSymbols()
+}
 
-	for r = runeBuf.GetRune(); util.IsSpace(r); r = runeBuf.GetRune() {
-		if r == NewLine {
-			lineno++
-		}
-	}
+// Get the name of the parser declared by `%name`, or "".
+func (lemon *Lemon) Name() string {
+	return lemon.name
+}
 
-	runeBuf.UngetRune(r)
-	lemon.lineno += lineno
+// Get the code between `%{` and `%}`, or "".
+func (lemon *Lemon) Include() string {
+	return lemon.include
 }
 
-// Skip over comments.
-// skipComment is called after reading a '/'.
-func (lemon *Lemon) skipComment() {
-	runeBuf := lemon.runeBuf
-	curRune := runeBuf.GetRune()
+// Get the fields of the values of the symbols declared by `%union`, with
+// their braces, or "".
+func (lemon *Lemon) Union() string {
+	return lemon.unionCode
+}
 
-	if curRune == '/' {
-		for curRune != EOF {
-			if curRune == NewLine {
-				lemon.lineno++
-				return
-			}
+// Get the code after the second `%%`, or "".
+func (lemon *Lemon) ExtraCode() string {
+	return lemon.extraCode
+}
 
-			curRune = runeBuf.GetRune()
-		}
+// Get the symbol declared by `%start`, or else the left hand side of the
+// first rule.
+func (lemon *Lemon) StartSymbol() *Symbol {
+	return lemon.startSymbol()
+}
 
-		errorf(lemon.infile, lemon.lineno, "EOF inside comment.")
-		return
+// Find the rule of the non-terminal `lhs` labelled `label`, so tests and
+// traces can refer to "the Add rule" rather than to a rule index.
+func (lemon *Lemon) FindRule(lhs string, label string) (*Rule, bool) {
+	if symbol, ok := lemon.symTable.Get(lhs); ok {
+		if rule := symbol.ruleByLabel(label); rule != nil {
+			return rule, true
+		}
 	}
 
-	if curRune != '*' {
-		errorf(lemon.infile, lemon.lineno, "Illegal comment: `%q`", curRune)
-	}
+	return nil, false
+}
+
+// Get the problems found by the verification of the symbols.
+func (lemon *Lemon) Findings() []Finding {
+	return lemon.findings
+}
+
+// Print the findings not printed yet, the use and then the declaration.
+// Warnings don't stop the generator, errors do once all are printed.
+func (lemon *Lemon) reportFindings() {
+	errorCnt := 0
+	findings := lemon.findings[lemon.reported:]
+	lemon.reported = len(lemon.findings)
 
-	var prevRune rune
+	for _, finding := range findings {
+		fmt.Fprintf(os.Stderr, "%v: %s: %s:%v", finding.Severity, finding.Message, lemon.infile, finding.Position())
 
-	for curRune = runeBuf.GetRune(); curRune != EOF && (prevRune != '*' || curRune != '/'); curRune = runeBuf.GetRune() {
-		if curRune == NewLine {
-			lemon.lineno++
+		if finding.Use.IsValid() && finding.Decl.IsValid() {
+			fmt.Fprintf(os.Stderr, " (declared at %s:%v)", lemon.infile, finding.Decl)
 		}
 
-		prevRune = curRune
-	}
+		fmt.Fprintln(os.Stderr)
 
-	if curRune == EOF {
-		errorf(lemon.infile, lemon.lineno, "EOF inside comment.")
+		if finding.Severity == SevError {
+			errorCnt++
+		}
 	}
-}
 
-// Return the number of rules defined in `.y` file.
-// Note that, given the `expr : expr '+' expr | expr '-' expr`
-// The number of rules will be 2.
-func (lemon *Lemon) RuleCount() int {
-	return lemon.nrule
+	if errorCnt > 0 {
+		os.Exit(1)
+	}
 }
 
 // Write out error comment.
-func errorf(filename string, lineno int, format string, args ...interface{}) {
+func errorf(filename string, pos Position, format string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, format, args...)
-	fmt.Fprintf(os.Stderr, ": %v:%v\n", filename, lineno)
+	fmt.Fprintf(os.Stderr, ": %v:%v\n", filename, pos)
 	os.Exit(1)
 }
 
@@ -267,3 +285,54 @@ func (lemon *Lemon) InputFile() string {
 func (lemon *Lemon) OutputFile() string {
 	return lemon.outfile
 }
+
+// Duplicate the input file without comments and without actions on rules.
+func (lemon *Lemon) Reprint() {
+	maxLen := 10
+	sortedSymbols := lemon.symTable.SortedSymbols()
+
+	fmt.Printf("// Reprint of input file \"%s\".\n// Symbols:\n", lemon.infile)
+
+	for _, sym := range sortedSymbols {
+		nameLen := len(sym.Name())
+
+		if nameLen > maxLen {
+			maxLen = nameLen
+		}
+	}
+
+	ncolumns := 76 / (maxLen + 5)
+
+	if ncolumns < 1 {
+		ncolumns = 1
+	}
+
+	skip := (len(sortedSymbols) + ncolumns - 1) / ncolumns
+	for i := 0; i < skip; i++ {
+		fmt.Printf("//")
+		for j := i; j < len(sortedSymbols); j += skip {
+			fmt.Printf(" %3d %-*.*s", j, maxLen, maxLen, sortedSymbols[j].Name())
+		}
+		fmt.Println()
+	}
+
+	for rule := lemon.firstRule; rule != nil; rule = rule.next {
+		fmt.Println(rule.String())
+	}
+}
+
+// Those rules which have a precedence symbol coded in the input
+// grammar using the "%prec symbol" construct will already have the
+// rp->precsym field filled. Other rules take as their precedence
+// symbol the last RHS symbol with a defined precedence, like yacc. If
+// there are not RHS symbols with a defined precedence, the precedece
+// symbol field is left blank.
+func (lemon *Lemon) updateRulePrecedences() {
+	for rp := lemon.firstRule; rp != nil; rp = rp.next {
+		if rp.precSym != nil {
+			continue
+		}
+
+		rp.updatePrecedence()
+	}
+}
@@ -0,0 +1,197 @@
+package parse
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golemon/util"
+)
+
+type LL1ConflictKind int
+
+const (
+	LeftRecursion LL1ConflictKind = iota
+	FirstFirst
+	FirstFollow
+)
+
+func (kind LL1ConflictKind) String() string {
+	switch kind {
+	case LeftRecursion:
+		return "left recursion"
+	case FirstFirst:
+		return "FIRST/FIRST"
+	case FirstFollow:
+		return "FIRST/FOLLOW"
+	}
+
+	return "Not implemented"
+}
+
+// A reason the grammar is not LL(1): a non-terminal which derives a
+// sentential form starting with itself, or two rules of a non-terminal
+// predicted by the same lookaheads.
+type LL1Conflict struct {
+	Kind       LL1ConflictKind
+	Symbol     *Symbol   // The non-terminal
+	Lookaheads []*Symbol // The lookaheads which predict both rules
+	Rules      []*Rule   // The cycle of the left recursion, or the two rules, the one predicted by FOLLOW last
+}
+
+func (conflict LL1Conflict) String() string {
+	rules := make([]string, len(conflict.Rules))
+
+	for i, rp := range conflict.Rules {
+		rules[i] = fmt.Sprintf("`%v`", rp)
+	}
+
+	if conflict.Kind == LeftRecursion {
+		return fmt.Sprintf("%s is left recursive: %s", conflict.Symbol.name, strings.Join(rules, ", "))
+	}
+
+	lookaheads := make([]string, len(conflict.Lookaheads))
+
+	for i, symbol := range conflict.Lookaheads {
+		lookaheads[i] = symbol.name
+	}
+
+	return fmt.Sprintf("%v conflict of %s on %s: %s", conflict.Kind, conflict.Symbol.name,
+		strings.Join(lookaheads, " "), strings.Join(rules, " and "))
+}
+
+// Get FIRST of the right hand side of the rule.
+func firstSet(rp *Rule) util.IntSet {
+	set := make(util.IntSet)
+	addFirst(set, rp.rhs[:rp.nrhs])
+
+	return set
+}
+
+// Get the lookaheads which predict the rule: FIRST of its right hand side,
+// and FOLLOW of its left hand side if it is nullable.
+func predictSet(rp *Rule) util.IntSet {
+	set := firstSet(rp)
+
+	if allNullable(rp.rhs[:rp.nrhs]) {
+		set.AddSet(rp.lhs.followset)
+	}
+
+	return set
+}
+
+// Find the reasons the grammar can't be parsed by a recursive-descent
+// parser with one lookahead: the left recursions, then the rules of a
+// non-terminal predicted by the same lookaheads. The lookaheads in FIRST
+// of both rules make a FIRST/FIRST conflict, the others, in FOLLOW of a
+// nullable rule, a FIRST/FOLLOW conflict.
+func (lemon *Lemon) LL1Conflicts() []LL1Conflict {
+	lemon.computeSets()
+	conflicts := lemon.findLeftRecursions()
+
+	for _, symbol := range lemon.symTable.SortedSymbols() {
+		rules := lemon.rulesOf(symbol)
+
+		for i, x := range rules {
+			for _, y := range rules[i+1:] {
+				firsts := firstSet(x).Intersect(firstSet(y))
+				follows := predictSet(x).Intersect(predictSet(y))
+				follows.RemoveAll(firsts.AsSlice())
+
+				if firsts.Len() > 0 {
+					conflicts = append(conflicts, LL1Conflict{FirstFirst, symbol, lemon.symbolsOf(firsts), []*Rule{x, y}})
+				}
+
+				if follows.Len() > 0 {
+					pair := []*Rule{x, y}
+
+					if allNullable(x.rhs[:x.nrhs]) && !allNullable(y.rhs[:y.nrhs]) {
+						pair = []*Rule{y, x}
+					}
+
+					conflicts = append(conflicts, LL1Conflict{FirstFollow, symbol, lemon.symbolsOf(follows), pair})
+				}
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// Find the non-terminals which derive a sentential form starting with
+// themselves, and the shortest cycle of rules of each. A cycle is only
+// reported for its first non-terminal.
+func (lemon *Lemon) findLeftRecursions() []LL1Conflict {
+	var conflicts []LL1Conflict
+	reported := make(map[*Symbol]bool)
+
+	for _, symbol := range lemon.symTable.SortedSymbols() {
+		if reported[symbol] {
+			continue
+		}
+
+		if cycle := lemon.leftCycle(symbol); cycle != nil {
+			for _, rp := range cycle {
+				reported[rp.lhs] = true
+			}
+
+			conflicts = append(conflicts, LL1Conflict{Kind: LeftRecursion, Symbol: symbol, Rules: cycle})
+		}
+	}
+
+	return conflicts
+}
+
+// Find the shortest cycle of rules which derives a sentential form
+// starting with the non-terminal from itself, or nil. Each rule starts
+// with the left hand side of the next one, after nullable symbols.
+func (lemon *Lemon) leftCycle(symbol *Symbol) []*Rule {
+	via := make(map[*Symbol]*Rule) // The rule which reaches each non-terminal first
+	queue := []*Symbol{symbol}
+
+	for len(queue) > 0 {
+		lhs := queue[0]
+		queue = queue[1:]
+
+		for _, rp := range lemon.rulesOf(lhs) {
+			for _, other := range rp.rhs[:rp.nrhs] {
+				if other == symbol {
+					cycle := []*Rule{rp}
+
+					for s := lhs; s != symbol; s = via[s].lhs {
+						cycle = append([]*Rule{via[s]}, cycle...)
+					}
+
+					return cycle
+				}
+
+				if _, ok := via[other]; !ok && other.IsNonTerminal() {
+					via[other] = rp
+					queue = append(queue, other)
+				}
+
+				if !other.nullable {
+					break
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Read the grammar of a recursive-descent parser. The reasons it is not
+// LL(1) are printed as errors, which stop the generator.
+func (lemon *Lemon) ParseLL1() {
+	lemon.ReadGrammar()
+	lemon.ll1 = true
+	conflicts := lemon.LL1Conflicts()
+
+	for _, conflict := range conflicts {
+		fmt.Fprintf(os.Stderr, "error: %v: %s:%v\n", conflict, lemon.infile, rulePosition(conflict.Rules[0]))
+	}
+
+	if len(conflicts) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,160 @@
+package parse
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func ll1Conflicts(t *testing.T, name string, src string) []string {
+	var conflicts []string
+
+	for _, conflict := range readGrammar(t, name, src).LL1Conflicts() {
+		conflicts = append(conflicts, conflict.String())
+	}
+
+	return conflicts
+}
+
+func TestLL1Conflicts(t *testing.T) {
+	checkStrings(t, "expr.y", []string{
+		"e is left recursive: `e:e PLUS t.`",
+		"t is left recursive: `t:t TIMES f.`",
+		"FIRST/FIRST conflict of e on ID LPAREN: `e:e PLUS t.` and `e:t.`",
+		"FIRST/FIRST conflict of t on ID LPAREN: `t:t TIMES f.` and `t:f.`",
+	}, ll1Conflicts(t, "expr.y", exprGrammar))
+
+	// Through a nullable symbol and another non-terminal.
+	checkStrings(t, "indirect.y", []string{
+		"a is left recursive: `a:o b X.`, `b:a Z.`",
+		"FIRST/FIRST conflict of b on Y: `b:a Z.` and `b:Y.`",
+		"FIRST/FOLLOW conflict of o on W: `o:W.` and `o:.`",
+	}, ll1Conflicts(t, "indirect.y", `%%
+a: o b X ;
+b: a Z | Y ;
+o: | W ;
+%%
+`))
+
+	checkStrings(t, "else.y", []string{
+		"FIRST/FOLLOW conflict of else on ELSE: `else:ELSE s.` and `else:.`",
+	}, ll1Conflicts(t, "else.y", `%%
+s: IF E s else | X ;
+else: | ELSE s ;
+%%
+`))
+}
+
+const calcGrammar = `%{
+package main
+
+import "fmt"
+%}
+%union {
+	n int
+}
+%type <n> expr terms term factors factor
+%token <n> NUM
+%%
+top: expr { fmt.Println($1) } ;
+expr: term terms { $$ = $1 + $2 } ;
+terms: { $$ = 0 } | '+' term terms { $$ = $2 + $3 } | '-' term terms { $$ = $3 - $2 } ;
+term: factor factors { $$ = $1 * $2 } ;
+factors: { $$ = 1 } | '*' factor factors { $$ = $2 * $3 } ;
+factor: NUM | '(' expr ')' { $$ = $2 } ;
+%%
+type lexer struct {
+	s string
+}
+
+func (l *lexer) Lex(lval *yySymType) int {
+	l.s = strings.TrimLeft(l.s, " ")
+
+	if l.s == "" {
+		return 0
+	}
+
+	c := l.s[0]
+	l.s = l.s[1:]
+
+	if c < '0' || c > '9' {
+		return int(c)
+	}
+
+	for lval.n = int(c - '0'); l.s != "" && l.s[0] >= '0' && l.s[0] <= '9'; l.s = l.s[1:] {
+		lval.n = lval.n*10 + int(l.s[0]-'0')
+	}
+
+	return NUM
+}
+
+func (l *lexer) Error(s string) {
+	fmt.Println(s)
+}
+
+func main() {
+	for _, s := range []string{"1 + 2 * (3 - 1)", "12 * 3 - 4 - 1", "1 +", "(1", "1 2"} {
+		fmt.Println(yyParse(&lexer{s}))
+	}
+}
+`
+
+func TestDescentParser(t *testing.T) {
+	lemon := readGrammar(t, "calc.y", strings.Replace(calcGrammar, `import "fmt"`, `import (
+	"fmt"
+	"strings"
+)`, 1))
+
+	if conflicts := lemon.LL1Conflicts(); len(conflicts) != 0 {
+		t.Fatalf("Unexpected conflicts: %v", conflicts)
+	}
+
+	lemon.ll1 = true
+	var buf bytes.Buffer
+	lemon.WriteGo(&buf)
+	src := buf.String()
+
+	for _, expect := range []string{
+		"// terms ::= ε\n// terms ::= '+' term terms\n// terms ::= '-' term terms\nfunc (p *yyParser) parseTerms(yyVAL *yySymType) bool {",
+		"\tcase 0, 2: // $ ')'\n\t\t{\n\t\t\tyyVAL.n = 0\n\t\t}",
+		"\t\tif !p.match(4, &yyS[1]) || !p.parseTerm(&yyS[2]) || !p.parseTerms(&yyS[3]) {",
+		"\t\t*yyVAL = yyS[1]\n\t\t{\n\t\t\tyyVAL.n = yyS[2].n + yyS[3].n\n\t\t}",
+		"\t\treturn p.fail(\"$\", \"')'\", \"'+'\", \"'-'\")",
+	} {
+		if !strings.Contains(src, expect) {
+			t.Errorf("Expect %q in:\n%s", expect, src)
+		}
+	}
+
+	if testing.Short() {
+		t.Skip("the generated parser is not run in short mode")
+	}
+
+	gobin, err := exec.LookPath("go")
+
+	if err != nil {
+		t.Skip("the go command is not found")
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module calc\n\ngo 1.19\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "calc.go"), buf.Bytes(), 0644)
+	cmd := exec.Command(gobin, "run", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+
+	checkStrings(t, "output", []string{
+		"5", "0",
+		"31", "0",
+		"syntax error: expected '(' or NUM", "1",
+		"syntax error: expected ')'", "1",
+		"syntax error: expected the end of the input, ')', '+', '-' or '*'", "1",
+	}, strings.Split(strings.TrimSpace(string(out)), "\n"))
+}
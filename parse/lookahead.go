@@ -0,0 +1,435 @@
+package parse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A string of at most k terminals which may come next. A shorter one ends
+// with `$`, or is being built.
+type lookString []*Symbol
+
+func (str lookString) key() string {
+	names := make([]string, len(str))
+
+	for i, symbol := range str {
+		names[i] = symbol.name
+	}
+
+	return strings.Join(names, " ")
+}
+
+// Check nothing can be appended to the string.
+func (str lookString) complete(k int) bool {
+	return len(str) >= k || len(str) > 0 && str[len(str)-1].name == EndSymbolName
+}
+
+// A set of strings of terminals, by key.
+type lookSet map[string]lookString
+
+// Add a string, return true if it is new.
+func (set lookSet) add(str lookString) bool {
+	key := str.key()
+
+	if _, ok := set[key]; ok {
+		return false
+	}
+
+	set[key] = str
+
+	return true
+}
+
+// Add the strings of another set, return true if the set has changed.
+func (set lookSet) addSet(other lookSet) bool {
+	changed := false
+
+	for _, str := range other {
+		if set.add(str) {
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// Get the strings sorted by the indexes of their terminals.
+func (set lookSet) sorted() [][]*Symbol {
+	strs := make([][]*Symbol, 0, len(set))
+
+	for _, str := range set {
+		strs = append(strs, str)
+	}
+
+	sort.Slice(strs, func(i, j int) bool { return lessSymbols(strs[i], strs[j]) })
+
+	return strs
+}
+
+// Concatenate the strings of x with the ones of y, truncated to k
+// terminals.
+func concatK(x, y lookSet, k int) lookSet {
+	set := make(lookSet)
+
+	for _, prefix := range x {
+		if prefix.complete(k) {
+			set.add(prefix)
+			continue
+		}
+
+		for _, suffix := range y {
+			str := append(append(lookString(nil), prefix...), suffix...)
+
+			if len(str) > k {
+				str = str[:k]
+			}
+
+			set.add(str)
+		}
+	}
+
+	return set
+}
+
+// A conflict on a lookahead, with the strings of more tokens of lookahead
+// on which each action may be taken. It is decided if no string is shared
+// by two actions: the parser then reads K tokens in this state to choose.
+type LookaheadDecision struct {
+	State     *State
+	Lookahead *Symbol
+	K         int           // The number of tokens of lookahead compared
+	Actions   []*Action     // The actions in conflict, the one taken on a single token first
+	Strings   [][][]*Symbol // The strings of at most K tokens of each action, sorted
+	Shared    [][]*Symbol   // The strings of several actions, if it is not decided
+}
+
+// Check no string of the lookahead leads to two actions.
+func (decision *LookaheadDecision) Decided() bool {
+	return len(decision.Shared) == 0
+}
+
+func (decision *LookaheadDecision) String() string {
+	if !decision.Decided() {
+		labels := make([]string, len(decision.Actions))
+
+		for i, ap := range decision.Actions {
+			labels[i] = actionLabel(ap)
+		}
+
+		return fmt.Sprintf("State %d still has a conflict on %s with %d tokens of lookahead: %s on %s",
+			decision.State.index, decision.Lookahead.name, decision.K, strings.Join(labels, " and "),
+			lookString(decision.Shared[0]).key())
+	}
+
+	choices := make([]string, len(decision.Actions))
+
+	for i, ap := range decision.Actions {
+		strs := make([]string, len(decision.Strings[i]))
+
+		for j, str := range decision.Strings[i] {
+			strs[j] = lookString(str).key()
+		}
+
+		choices[i] = fmt.Sprintf("%s on %s", actionLabel(ap), strings.Join(strs, ", "))
+	}
+
+	return fmt.Sprintf("State %d decides on %s with %d tokens of lookahead: %s",
+		decision.State.index, decision.Lookahead.name, decision.K, strings.Join(choices, "; "))
+}
+
+// The search of the strings of k terminals which may follow the
+// configurations. The contexts of a configuration are the ones of the
+// states it is in, so they are merged like the states are.
+type lookaheadSearch struct {
+	lemon  *Lemon
+	k      int
+	first  map[*Symbol]lookSet // FIRST of k terminals of each symbol
+	follow map[*Config]lookSet // The strings which may follow the rule of each configuration
+	starts map[*Rule][]*Config // The configurations of each rule with the dot first
+}
+
+// A configuration which adds the rule of another to its state, and FIRST
+// of its symbols after the left hand side of the rule.
+type lookaheadLink struct {
+	cfp   *Config
+	first lookSet
+}
+
+func (lemon *Lemon) newLookaheadSearch(k int) *lookaheadSearch {
+	s := &lookaheadSearch{
+		lemon:  lemon,
+		k:      k,
+		first:  make(map[*Symbol]lookSet),
+		follow: make(map[*Config]lookSet),
+		starts: make(map[*Rule][]*Config),
+	}
+
+	for _, stp := range lemon.sortedState {
+		for _, cfp := range stp.cfp {
+			if cfp.dot == 0 {
+				s.starts[cfp.rp] = append(s.starts[cfp.rp], cfp)
+			}
+		}
+	}
+
+	for _, symbol := range lemon.symTable.SortedSymbols() {
+		if symbol.IsTerminal() {
+			s.first[symbol] = lookSet{symbol.name: {symbol}}
+		} else {
+			s.first[symbol] = make(lookSet)
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for rp := lemon.firstRule; rp != nil; rp = rp.next {
+			if s.first[rp.lhs].addSet(s.firstOf(rp.rhs[:rp.nrhs])) {
+				changed = true
+			}
+		}
+	}
+
+	return s
+}
+
+// Get FIRST of k terminals of the symbols. It has the empty string if
+// they are nullable.
+func (s *lookaheadSearch) firstOf(symbols []*Symbol) lookSet {
+	set := lookSet{"": nil}
+
+	for _, symbol := range symbols {
+		set = concatK(set, s.first[symbol], s.k)
+	}
+
+	return set
+}
+
+// Get the configurations which add the rule of the configuration to the
+// states where its dot is first, and from which it reaches its state.
+func (s *lookaheadSearch) links(cfp *Config) []lookaheadLink {
+	var links []lookaheadLink
+
+	for _, start := range s.starts[cfp.rp] {
+		stp := start.stp
+
+		for _, symbol := range cfp.rp.rhs[:cfp.dot] {
+			if stp = stp.Goto(symbol); stp == nil {
+				break
+			}
+		}
+
+		if stp != cfp.stp {
+			continue
+		}
+
+		for _, parent := range start.stp.cfp {
+			if parent.next() == cfp.rp.lhs {
+				links = append(links, lookaheadLink{parent, s.firstOf(parent.rp.rhs[parent.dot+1 : parent.rp.nrhs])})
+			}
+		}
+	}
+
+	return links
+}
+
+// Get the strings of k terminals which may follow the rule of the
+// configuration: the ones which follow its left hand side in the
+// configurations which add the rule. They depend on each other through
+// recursive rules, so they are found for all those configurations
+// together, until nothing changes.
+func (s *lookaheadSearch) followOf(cfp *Config) lookSet {
+	if set, ok := s.follow[cfp]; ok {
+		return set
+	}
+
+	links := make(map[*Config][]lookaheadLink)
+	var configs []*Config
+	var visit func(cfp *Config)
+
+	visit = func(cfp *Config) {
+		if _, ok := links[cfp]; ok {
+			return
+		}
+
+		if _, ok := s.follow[cfp]; ok {
+			return
+		}
+
+		links[cfp] = s.links(cfp)
+		configs = append(configs, cfp)
+
+		for _, link := range links[cfp] {
+			visit(link.cfp)
+		}
+	}
+
+	visit(cfp)
+
+	for _, cfp := range configs {
+		s.follow[cfp] = make(lookSet)
+
+		if cfp.rp == s.lemon.acceptRule {
+			s.follow[cfp].add(lookString{s.lemon.endSym})
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, cfp := range configs {
+			for _, link := range links[cfp] {
+				if s.follow[cfp].addSet(concatK(link.first, s.follow[link.cfp], s.k)) {
+					changed = true
+				}
+			}
+		}
+	}
+
+	return s.follow[cfp]
+}
+
+// Get the strings of k terminals, starting with the lookahead, on which
+// the state may take the action: the strings of the configurations which
+// shift the lookahead, or of the one completed by the rule reduced.
+func (s *lookaheadSearch) actionStrings(stp *State, ap *Action, lookahead *Symbol) lookSet {
+	set := make(lookSet)
+
+	for _, cfp := range stp.cfp {
+		switch ap.actionType {
+		case Shift:
+			if cfp.next() != lookahead {
+				continue
+			}
+		case Accept:
+			if cfp.rp != s.lemon.acceptRule || cfp.dot < cfp.rp.nrhs {
+				continue
+			}
+		default:
+			if cfp.rp != ap.rp || cfp.dot < cfp.rp.nrhs {
+				continue
+			}
+		}
+
+		for _, str := range concatK(s.firstOf(cfp.rp.rhs[cfp.dot:cfp.rp.nrhs]), s.followOf(cfp), s.k) {
+			if len(str) > 0 && str[0] == lookahead {
+				set.add(str)
+			}
+		}
+	}
+
+	return set
+}
+
+// Set the maximum number of tokens of lookahead used to decide the
+// conflicts left by precedence. 1, the default, leaves them. Must be
+// called before Parse.
+func (lemon *Lemon) SetMaxLookahead(k int) {
+	lemon.maxLookahead = k
+}
+
+// Try to decide the conflicts left by precedence with more tokens of
+// lookahead, for their actions only: with 2 tokens, then 3, up to the
+// maximum. A decided conflict is no longer a conflict: its actions are
+// kept in the state, with the strings of tokens which choose them.
+func (lemon *Lemon) decideByLookahead() {
+	lemon.decisions = nil
+
+	for _, stp := range lemon.sortedState {
+		stp.decisions = nil
+	}
+
+	if lemon.maxLookahead < 2 || len(lemon.conflicts) == 0 {
+		return
+	}
+
+	searches := make([]*lookaheadSearch, lemon.maxLookahead+1)
+	var conflicts []ParseConflict
+
+	// The conflicts on a lookahead of a state follow each other.
+	for i, j := 0, 0; i < len(lemon.conflicts); i = j {
+		first := lemon.conflicts[i]
+		decision := &LookaheadDecision{State: first.State, Lookahead: first.Lookahead, Actions: []*Action{first.Taken}}
+
+		for j = i; j < len(lemon.conflicts) && lemon.conflicts[j].State == first.State && lemon.conflicts[j].Lookahead == first.Lookahead; j++ {
+			decision.Actions = append(decision.Actions, lemon.conflicts[j].Dropped)
+		}
+
+		for k := 2; k <= lemon.maxLookahead; k++ {
+			if searches[k] == nil {
+				searches[k] = lemon.newLookaheadSearch(k)
+			}
+
+			sets := make([]lookSet, len(decision.Actions))
+			decision.K, decision.Strings = k, make([][][]*Symbol, len(sets))
+
+			for a, ap := range decision.Actions {
+				sets[a] = searches[k].actionStrings(first.State, ap, first.Lookahead)
+				decision.Strings[a] = sets[a].sorted()
+			}
+
+			if decision.Shared = sharedStrings(sets); decision.Decided() {
+				break
+			}
+		}
+
+		lemon.decisions = append(lemon.decisions, decision)
+
+		if !decision.Decided() {
+			conflicts = append(conflicts, lemon.conflicts[i:j]...)
+			continue
+		}
+
+		for _, ap := range decision.Actions[1:] {
+			ap.actionType = LookaheadDecided
+			ap.rp.canReduce = true
+		}
+
+		first.State.decisions = append(first.State.decisions, decision)
+	}
+
+	lemon.conflicts = conflicts
+	lemon.nconflict = len(conflicts)
+}
+
+// Get the strings which are in several of the sets, sorted.
+func sharedStrings(sets []lookSet) [][]*Symbol {
+	seen := make(lookSet)
+	shared := make(lookSet)
+
+	for _, set := range sets {
+		for key, str := range set {
+			if _, ok := seen[key]; ok {
+				shared.add(str)
+			}
+		}
+
+		seen.addSet(set)
+	}
+
+	return shared.sorted()
+}
+
+// Get the conflicts more tokens of lookahead don't decide.
+func (lemon *Lemon) undecided() []*LookaheadDecision {
+	var undecided []*LookaheadDecision
+
+	for _, decision := range lemon.decisions {
+		if !decision.Decided() {
+			undecided = append(undecided, decision)
+		}
+	}
+
+	return undecided
+}
+
+// Get the conflicts tried with more tokens of lookahead, decided or not.
+func (lemon *Lemon) LookaheadDecisions() []*LookaheadDecision {
+	return lemon.decisions
+}
+
+// Get the conflicts of the state decided by more tokens of lookahead.
+func (stp *State) LookaheadDecisions() []*LookaheadDecision {
+	return stp.decisions
+}
@@ -0,0 +1,161 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+)
+
+// LR(2): after W, X Y reduces a, X Z reduces b and X V is shifted.
+const lr2Grammar = `%%
+s: a X Y | b X Z | W X V ;
+a: W ;
+b: W ;
+%%
+`
+
+// LR(3): a and b are only told apart by the third token.
+const lr3Grammar = `%%
+s: a X X Y | b X X Z ;
+a: W ;
+b: W ;
+%%
+`
+
+// The dangling else is ambiguous, no number of tokens decides it.
+const elseGrammar = `%%
+s: IF E s | IF E s ELSE s | X ;
+%%
+`
+
+func decideByLookahead(t *testing.T, name string, src string, k int) *Lemon {
+	lemon := buildActions(t, name, src, LALR)
+	lemon.SetMaxLookahead(k)
+	lemon.decideByLookahead()
+
+	return lemon
+}
+
+func decisionStrings(lemon *Lemon) []string {
+	var decisions []string
+
+	for _, decision := range lemon.LookaheadDecisions() {
+		decisions = append(decisions, decision.String())
+	}
+
+	return decisions
+}
+
+// Run the automaton on the tokens, reading more tokens in the states with
+// decisions. The grammar must have no conflicts left.
+func acceptsK(lemon *Lemon, tokens []*Symbol) bool {
+	return runLookup(lemon, tokens, func(stp *State, lookahead *Symbol, tokens []*Symbol) *Action {
+		for _, decision := range stp.decisions {
+			if decision.Lookahead == lookahead {
+				return decide(lemon, decision, tokens)
+			}
+		}
+
+		return actionOn(stp, lookahead)
+	})
+}
+
+// Get the action of the decision whose string starts the tokens, or nil.
+func decide(lemon *Lemon, decision *LookaheadDecision, tokens []*Symbol) *Action {
+	next := append(append([]*Symbol(nil), tokens...), lemon.endSym)
+
+	for i, strs := range decision.Strings {
+		for _, str := range strs {
+			if len(str) <= len(next) && lookString(str).key() == lookString(next[:len(str)]).key() {
+				return decision.Actions[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+func TestDecideByLookahead(t *testing.T) {
+	lemon := decideByLookahead(t, "lr2.y", lr2Grammar, 2)
+	checkStrings(t, "lr2.y", []string{
+		"State 1 decides on X with 2 tokens of lookahead: shift on X V; reduce `a:W.` on X Y; reduce `b:W.` on X Z",
+	}, decisionStrings(lemon))
+
+	if lemon.ConflictCount() != 0 {
+		t.Errorf("lr2.y: unexpected conflicts: %v", lemon.Conflicts())
+	}
+
+	stp := walk(t, lemon, "W")
+
+	if len(stp.LookaheadDecisions()) != 1 {
+		t.Errorf("lr2.y: expect a decision in %v", stp)
+	}
+
+	for _, ap := range stp.Actions() {
+		if ap.Type() == Reduce || ap.Type() == Conflict {
+			t.Errorf("lr2.y: %v on %s is not decided", ap.Type(), ap.Symbol().name)
+		}
+	}
+
+	var terminals []*Symbol
+
+	for _, name := range []string{"V", "W", "X", "Y", "Z"} {
+		terminals = append(terminals, mustSymbol(t, lemon, name))
+	}
+
+	var accepted []string
+
+	eachSentence(terminals, 4, func(tokens []*Symbol) {
+		if acceptsK(lemon, tokens) {
+			accepted = append(accepted, strings.Join(symbolNames(tokens), " "))
+		}
+	})
+
+	checkStrings(t, "lr2.y sentences", []string{"W X V", "W X Y", "W X Z"}, accepted)
+
+	// Without more lookahead, the conflicts are left.
+	lemon = decideByLookahead(t, "lr2.y", lr2Grammar, 1)
+
+	if lemon.ConflictCount() != 2 || len(lemon.LookaheadDecisions()) != 0 {
+		t.Errorf("lr2.y: expect 2 conflicts left, actual %v", lemon.Conflicts())
+	}
+}
+
+func TestUndecidedByLookahead(t *testing.T) {
+	lemon := decideByLookahead(t, "lr3.y", lr3Grammar, 2)
+	checkStrings(t, "lr3.y", []string{
+		"State 1 still has a conflict on X with 2 tokens of lookahead: reduce `a:W.` and reduce `b:W.` on X X",
+	}, decisionStrings(lemon))
+
+	if lemon.ConflictCount() != 1 {
+		t.Errorf("lr3.y: expect the conflict to be left, actual %v", lemon.Conflicts())
+	}
+
+	lemon = decideByLookahead(t, "lr3.y", lr3Grammar, 3)
+	checkStrings(t, "lr3.y", []string{
+		"State 1 decides on X with 3 tokens of lookahead: reduce `a:W.` on X X Y; reduce `b:W.` on X X Z",
+	}, decisionStrings(lemon))
+
+	lemon = decideByLookahead(t, "else.y", elseGrammar, 4)
+	checkStrings(t, "else.y", []string{
+		"State 5 still has a conflict on ELSE with 4 tokens of lookahead: shift and reduce `s:IF E s.` on ELSE IF E IF",
+	}, decisionStrings(lemon))
+
+	if lemon.ConflictCount() != 1 {
+		t.Errorf("else.y: expect the conflict to be left, actual %v", lemon.Conflicts())
+	}
+}
+
+func TestLookaheadStrings(t *testing.T) {
+	// The strings which follow a reduce come from the states the rule
+	// starts in: here through the recursion of l, and the nullable o.
+	lemon := decideByLookahead(t, "list.y", `%%
+s: l o X Y | W X Z ;
+l: a | l Q a ;
+a: W ;
+o: | V ;
+%%
+`, 3)
+	checkStrings(t, "list.y", []string{
+		"State 1 decides on X with 2 tokens of lookahead: shift on X Z; reduce `a:W.` on X Y",
+	}, decisionStrings(lemon))
+}
@@ -0,0 +1,92 @@
+package parse
+
+import "fmt"
+
+// How the states of the parser are built.
+type LRMode int
+
+const (
+	// LR(0) states with LALR(1) lookaheads, like lemon. This is the default.
+	LALR LRMode = iota
+	// LR(1) states: states with the same configurations but different
+	// lookaheads are kept apart, which avoids the reduce/reduce conflicts
+	// LALR merging may cause, at the cost of many more states.
+	Canonical
+	// LR(1) states, merged like LALR(1) states unless that might make a
+	// reduce/reduce conflict. This has about as many states as LALR(1).
+	Minimal
+	// LR(0) states, reduced on the FOLLOW sets of the rules. This is
+	// for teaching and comparison, it has more conflicts than LALR(1).
+	SLR
+)
+
+// LR modes selectable by name, e.g. from the command line.
+var LRModes = map[string]LRMode{
+	"lalr":      LALR,
+	"canonical": Canonical,
+	"minimal":   Minimal,
+	"slr":       SLR,
+}
+
+func (mode LRMode) String() string {
+	switch mode {
+	case LALR:
+		return "LALR(1)"
+	case Canonical:
+		return "canonical LR(1)"
+	case Minimal:
+		return "minimal LR(1)"
+	case SLR:
+		return "SLR(1)"
+	}
+
+	return "Not implemented"
+}
+
+// Set how the states of the parser are built. Must be called before Parse.
+func (lemon *Lemon) SetLRMode(mode LRMode) {
+	lemon.lrMode = mode
+}
+
+// Build the states of the parser, with the lookaheads of their
+// configurations, as selected by the LR mode. The number of LALR(1)
+// states is kept for comparison.
+func (lemon *Lemon) buildStates() {
+	switch lemon.lrMode {
+	case Canonical:
+		lemon.findStates(LALR)
+		lemon.lalrStates = lemon.nstate
+		lemon.findStates(Canonical)
+	case Minimal:
+		lemon.findStates(LALR)
+		lemon.lalrStates = lemon.nstate
+		lalrStates := lemon.sortedState
+		lemon.findStates(Minimal)
+		lemon.findSplitOrigins(lalrStates)
+	case SLR:
+		lemon.buildLALR()
+		lemon.lalrStates = lemon.nstate
+		lalrStates := lemon.sortedState
+		lemon.findStates(LALR)
+		lemon.findSLRFollowSets()
+		lemon.findSLRConflicts(lalrStates)
+	default:
+		lemon.buildLALR()
+		lemon.lalrStates = lemon.nstate
+	}
+}
+
+// Get the number of states of the parser.
+func (lemon *Lemon) StateCount() int {
+	return lemon.nstate
+}
+
+// Get the number of states of the parser, and of the LALR(1) parser if
+// it is built otherwise, e.g. "12 canonical LR(1) states (LALR(1): 10)".
+func (lemon *Lemon) StateSummary() string {
+	if lemon.lrMode == LALR {
+		return fmt.Sprintf("%d %v states", lemon.nstate, lemon.lrMode)
+	}
+
+	return fmt.Sprintf("%d %v states (%v: %d)", lemon.nstate, lemon.lrMode, LALR, lemon.lalrStates)
+}
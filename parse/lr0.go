@@ -0,0 +1,414 @@
+package parse
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+
+	"github.com/golemon/util"
+)
+
+// The name of the left hand side of the accept rule `$accept ::= start`.
+// Like `$`, it can't clash with a grammar symbol.
+const AcceptSymbolName = "$accept"
+
+// A configuration before it is made part of a state: states are looked up
+// by their basis before any configuration is allocated. The follow set is
+// only part of the identity of canonical LR(1) states, it is nil otherwise.
+type item struct {
+	rp  *Rule
+	dot int
+	fws util.BitSet
+}
+
+func configLess(a, b *Config) bool {
+	if a.rp.index != b.rp.index {
+		return a.rp.index < b.rp.index
+	}
+
+	return a.dot < b.dot
+}
+
+// Hash the basis of a state. The items must be sorted. The follow sets
+// are only hashed if they are part of the identity of the states.
+func hashBasis(basis []item, lookaheads bool) uint64 {
+	var buf [2 * binary.MaxVarintLen64]byte
+	h := fnv.New64a()
+
+	for _, it := range basis {
+		n := binary.PutUvarint(buf[:], uint64(it.rp.index))
+		n += binary.PutUvarint(buf[n:], uint64(it.dot))
+		h.Write(buf[:n])
+
+		for i := 0; lookaheads && i < len(it.fws); i++ {
+			binary.LittleEndian.PutUint64(buf[:8], it.fws[i])
+			h.Write(buf[:8])
+		}
+	}
+
+	return h.Sum64()
+}
+
+// Check the state has the basis, without regard to the follow sets.
+func sameCore(stp *State, basis []item) bool {
+	if len(stp.bp) != len(basis) {
+		return false
+	}
+
+	for i, cfp := range stp.bp {
+		if cfp.rp != basis[i].rp || cfp.dot != basis[i].dot {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameLookaheads(stp *State, basis []item) bool {
+	for i, cfp := range stp.bp {
+		if !cfp.fws.Equal(basis[i].fws) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// The state of the construction of the automaton.
+type stateBuilder struct {
+	mode     LRMode              // How states with the same basis but different follow sets are merged
+	lhsRules [][]*Rule           // Rules of each non-terminal by symbol index, in the order of the grammar file
+	table    map[uint64][]*State // States by the hash of their basis
+	states   []*State            // States by index
+	queue    []*State            // States to expand
+	queued   map[*State]bool     // States in the queue
+	stamp    int                 // Number of closures computed so far
+	closed   []int               // Last closure, by stamp, which includes the rules of the non-terminal
+	closedAt []int               // Position of the first configuration of the non-terminal in that closure
+	gotos    [][]*Config         // Configurations shifting each symbol, while a state is expanded
+	basis    []item              // Basis of the successor, while a state is expanded
+	nsymbol  int                 // Capacity of the follow sets
+	symbols  []*Symbol           // Symbols by index
+	splits   []StateSplit        // Minimal LR(1) states which are not merged, and why
+}
+
+// Check whether the state with the same core as the basis can be used
+// for it: LR(0) states are merged whatever their follow sets, canonical
+// LR(1) states only if the follow sets are the same, and minimal LR(1)
+// states if they are weakly compatible.
+func (builder *stateBuilder) mergeable(stp *State, basis []item) bool {
+	switch builder.mode {
+	case Canonical:
+		return sameLookaheads(stp, basis)
+	case Minimal:
+		i, _, _ := incompatibility(stp, basis)
+
+		return i < 0
+	}
+
+	return true
+}
+
+// Get the state with the basis, creating it if it is new. The items
+// must be sorted by rule and dot.
+func (builder *stateBuilder) getState(basis []item) *State {
+	hash := hashBasis(basis, builder.mode == Canonical)
+	var apart []*State
+
+	for _, stp := range builder.table[hash] {
+		if !sameCore(stp, basis) {
+			continue
+		}
+
+		if builder.mergeable(stp, basis) {
+			builder.merge(stp, basis)
+
+			return stp
+		}
+
+		apart = append(apart, stp)
+	}
+
+	stp := &State{index: len(builder.states), bp: make([]*Config, len(basis))}
+
+	for i, it := range basis {
+		stp.bp[i] = &Config{rp: it.rp, dot: it.dot, fws: util.NewBitSet(builder.nsymbol), stp: stp}
+		stp.bp[i].fws.AddSet(it.fws)
+	}
+
+	builder.closure(stp)
+	builder.table[hash] = append(builder.table[hash], stp)
+	builder.states = append(builder.states, stp)
+	builder.enqueue(stp)
+
+	if builder.mode == Minimal && len(apart) > 0 {
+		builder.splits = append(builder.splits, builder.newStateSplit(stp, apart[0], basis))
+	}
+
+	return stp
+}
+
+func (builder *stateBuilder) enqueue(stp *State) {
+	if !builder.queued[stp] {
+		builder.queued[stp] = true
+		builder.queue = append(builder.queue, stp)
+	}
+}
+
+// Add the follow sets of the basis to the state. If they grow, the
+// closure and the successors of the state are computed again.
+func (builder *stateBuilder) merge(stp *State, basis []item) {
+	if builder.mode != Minimal {
+		return
+	}
+
+	changed := false
+
+	for i, cfp := range stp.bp {
+		if cfp.fws.AddSet(basis[i].fws) {
+			changed = true
+		}
+	}
+
+	if changed {
+		builder.closure(stp)
+		builder.enqueue(stp)
+	}
+}
+
+// Add the configurations `N ::= * γ` for each non-terminal N after a dot
+// of the state, recursively, then sort them all by rule and dot. As in
+// lemon, FIRST(β) of each `A ::= α * N β` is added to the follow sets of
+// the new configurations, and if β is nullable, the follow set of
+// `A ::= α * N β` propagates to them.
+//
+// The follow sets of LR(1) states are complete once their basis is, so
+// they are propagated inside the state right away.
+func (builder *stateBuilder) closure(stp *State) {
+	builder.stamp++
+	stamp := builder.stamp
+	stp.cfp = append(make([]*Config, 0, len(stp.bp)), stp.bp...)
+
+	for _, cfp := range stp.bp {
+		cfp.fplp = nil
+	}
+
+	for i := 0; i < len(stp.cfp); i++ {
+		cfp := stp.cfp[i]
+		symbol := cfp.next()
+
+		if symbol == nil || symbol.IsTerminal() {
+			continue
+		}
+
+		rules := builder.lhsRules[symbol.index]
+
+		if builder.closed[symbol.index] != stamp {
+			builder.closed[symbol.index] = stamp
+			builder.closedAt[symbol.index] = len(stp.cfp)
+
+			for _, rp := range rules {
+				stp.cfp = append(stp.cfp, &Config{rp: rp, fws: util.NewBitSet(builder.nsymbol), stp: stp})
+			}
+		}
+
+		rest := cfp.rp.rhs[cfp.dot+1 : cfp.rp.nrhs]
+		first := util.NewBitSet(builder.nsymbol)
+
+		for _, symbol := range rest {
+			first.AddInts(symbol.firstset)
+
+			if !symbol.nullable {
+				break
+			}
+		}
+
+		nullable := allNullable(rest)
+		at := builder.closedAt[symbol.index]
+
+		for _, newcfp := range stp.cfp[at : at+len(rules)] {
+			newcfp.fws.AddSet(first)
+
+			if nullable {
+				addPLink(&cfp.fplp, newcfp)
+			}
+		}
+	}
+
+	sort.SliceStable(stp.cfp, func(i, j int) bool {
+		return configLess(stp.cfp[i], stp.cfp[j])
+	})
+
+	if builder.mode != LALR {
+		propagate(stp.cfp)
+	}
+}
+
+// Record the shifts and gotos of the state, in symbol order, creating the
+// states they lead to.
+func (builder *stateBuilder) buildShifts(stp *State) {
+	var symbols []*Symbol
+
+	for _, cfp := range stp.cfp {
+		symbol := cfp.next()
+
+		if symbol == nil {
+			continue
+		}
+
+		if len(builder.gotos[symbol.index]) == 0 {
+			symbols = append(symbols, symbol)
+		}
+
+		builder.gotos[symbol.index] = append(builder.gotos[symbol.index], cfp)
+	}
+
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].index < symbols[j].index
+	})
+
+	stp.ap = make([]Action, 0, len(symbols))
+
+	for _, symbol := range symbols {
+		sources := builder.gotos[symbol.index]
+		basis := builder.basis[:0]
+
+		// The configurations are sorted, so is the basis of the successor.
+		for _, cfp := range sources {
+			if builder.mode == LALR {
+				basis = append(basis, item{cfp.rp, cfp.dot + 1, nil})
+			} else {
+				basis = append(basis, item{cfp.rp, cfp.dot + 1, cfp.fws})
+			}
+		}
+
+		newstp := builder.getState(basis)
+		stp.ap = append(stp.ap, Action{sp: symbol, actionType: Shift, stp: newstp})
+
+		// The follow set of each configuration propagates to the one
+		// it becomes after the shift. The link is made backward, from the
+		// new configuration, and reversed by findLinks. LR(1) states
+		// already have their follow sets.
+		if builder.mode == LALR {
+			for i, cfp := range sources {
+				addPLink(&newstp.bp[i].bplp, cfp)
+			}
+		}
+
+		builder.gotos[symbol.index] = sources[:0]
+		builder.basis = basis
+	}
+}
+
+// Build the LR(0) automaton: the sets of configurations (the states) and
+// the shifts and gotos between them. The grammar is augmented with the
+// accept rule `$accept ::= start`, whose configuration `$accept ::= * start`
+// is the basis of state 0. States are identified by their basis, each
+// is built once and expanded once, so the time is linear in the size of
+// the automaton.
+//
+// In the other modes, the follow sets are part of the basis, and they are
+// complete once the states are built. Canonical LR(1) states are
+// identified by their basis and follow sets. Minimal LR(1) states with
+// the same basis are merged unless that might make a reduce/reduce
+// conflict (Pager's weak compatibility). Merging makes follow sets grow,
+// so states are expanded again, and some may end up unreachable.
+func (lemon *Lemon) findStates(mode LRMode) {
+	start := lemon.startSymbol()
+	lemon.sortedState = nil
+	lemon.nstate = 0
+	lemon.splits = nil
+
+	if start == nil {
+		return
+	}
+
+	lemon.computeSets()
+	symbols := lemon.symTable.SortedSymbols()
+	lemon.nsymbol = len(symbols)
+	lemon.nterminal = lemon.symTable.TerminalCount()
+
+	acceptSym := NewSymbol(AcceptSymbolName, NonTerminal)
+	acceptSym.index = lemon.nsymbol
+	lemon.acceptRule = &Rule{lhs: acceptSym, nrhs: 1, rhs: []*Symbol{start}, index: lemon.nrule}
+
+	builder := &stateBuilder{
+		mode:     mode,
+		lhsRules: make([][]*Rule, lemon.nsymbol),
+		table:    make(map[uint64][]*State),
+		queued:   make(map[*State]bool),
+		closed:   make([]int, lemon.nsymbol),
+		closedAt: make([]int, lemon.nsymbol),
+		gotos:    make([][]*Config, lemon.nsymbol),
+		nsymbol:  lemon.nsymbol,
+		symbols:  symbols,
+	}
+
+	for rule := lemon.firstRule; rule != nil; rule = rule.next {
+		builder.lhsRules[rule.lhs.index] = append(builder.lhsRules[rule.lhs.index], rule)
+	}
+
+	end := util.NewBitSet(lemon.nsymbol)
+	end.Add(lemon.endSym.index)
+	builder.getState([]item{{lemon.acceptRule, 0, end}})
+
+	// New states are queued while the earlier ones are expanded.
+	for len(builder.queue) > 0 {
+		stp := builder.queue[0]
+		builder.queue = builder.queue[1:]
+		builder.queued[stp] = false
+		builder.buildShifts(stp)
+	}
+
+	if mode == Minimal {
+		builder.removeUnreachable()
+	}
+
+	lemon.sortedState = builder.states
+	lemon.nstate = len(builder.states)
+	lemon.splits = builder.splits
+}
+
+// Remove the states which are no longer reached from state 0, and
+// renumber the others.
+func (builder *stateBuilder) removeUnreachable() {
+	reachable := map[*State]bool{builder.states[0]: true}
+	stack := []*State{builder.states[0]}
+
+	for len(stack) > 0 {
+		stp := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for _, ap := range stp.ap {
+			if !reachable[ap.stp] {
+				reachable[ap.stp] = true
+				stack = append(stack, ap.stp)
+			}
+		}
+	}
+
+	states := builder.states[:0]
+
+	for _, stp := range builder.states {
+		if reachable[stp] {
+			stp.index = len(states)
+			states = append(states, stp)
+		}
+	}
+
+	builder.states = states
+	splits := builder.splits[:0]
+
+	for _, split := range builder.splits {
+		if reachable[split.State] && reachable[split.Other] {
+			splits = append(splits, split)
+		}
+	}
+
+	builder.splits = splits
+}
+
+// Get the states of the parser, by index. State 0 is the initial state.
+func (lemon *Lemon) States() []*State {
+	return lemon.sortedState
+}
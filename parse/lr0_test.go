@@ -0,0 +1,139 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const exprGrammar = `%{
+%}
+%token PLUS TIMES LPAREN RPAREN ID
+%%
+e: e PLUS t | t ;
+t: t TIMES f | f ;
+f: LPAREN e RPAREN | ID ;
+%%
+`
+
+func buildStates(t *testing.T, name string, src string) *Lemon {
+	lemon := readGrammar(t, name, src)
+	lemon.computeSets()
+	lemon.findStates(LALR)
+
+	return lemon
+}
+
+func configStrings(configs []*Config) []string {
+	strs := make([]string, len(configs))
+
+	for i, cfp := range configs {
+		strs[i] = cfp.String()
+	}
+
+	return strs
+}
+
+// The dragon book builds 12 sets of items for this grammar.
+func TestFindStatesExpr(t *testing.T) {
+	lemon := buildStates(t, "expr.y", exprGrammar)
+	states := lemon.States()
+
+	if len(states) != 12 || lemon.nstate != 12 {
+		t.Fatalf("Expect 12 states, actual %d", len(states))
+	}
+
+	checkStrings(t, "state 0", []string{
+		"e ::= * e PLUS t",
+		"e ::= * t",
+		"t ::= * t TIMES f",
+		"t ::= * f",
+		"f ::= * LPAREN e RPAREN",
+		"f ::= * ID",
+		"$accept ::= * e",
+	}, configStrings(states[0].Configs()))
+
+	for i, stp := range states {
+		if stp.Index() != i {
+			t.Errorf("State %d has index %d", i, stp.Index())
+		}
+	}
+
+	// Every state is reached by a single symbol, the one before the dots of its basis.
+	for _, stp := range states {
+		for _, ap := range stp.Actions() {
+			for _, cfp := range ap.State().Basis() {
+				if cfp.rp.rhs[cfp.dot-1] != ap.Symbol() {
+					t.Errorf("State %d: unexpected basis %v after %s", ap.State().Index(), cfp, ap.Symbol().name)
+				}
+			}
+		}
+	}
+
+	// The states after `(` are shared.
+	lparen := mustSymbol(t, lemon, "LPAREN")
+	inner := states[0].Goto(lparen)
+
+	if inner == nil || inner.Goto(lparen) != inner {
+		t.Errorf("Expect the state after LPAREN to loop on LPAREN")
+	}
+
+	e := mustSymbol(t, lemon, "e")
+	checkStrings(t, "goto(0, e)", []string{"e ::= e * PLUS t", "$accept ::= e *"}, configStrings(states[0].Goto(e).Basis()))
+	checkStrings(t, "goto(goto(0, LPAREN), e)", []string{"e ::= e * PLUS t", "f ::= LPAREN e * RPAREN"}, configStrings(inner.Goto(e).Basis()))
+}
+
+// The accept rule keeps the state after the start symbol apart from the
+// state with the same basis elsewhere.
+func TestFindStatesAccept(t *testing.T) {
+	lemon := buildStates(t, "accept.y", `%{
+%}
+%%
+s: LPAREN a | a ;
+a: s X | Y ;
+%%
+`)
+	states := lemon.States()
+	s := mustSymbol(t, lemon, "s")
+	lparen := mustSymbol(t, lemon, "LPAREN")
+
+	top := states[0].Goto(s)
+	nested := states[0].Goto(lparen).Goto(s)
+
+	if top == nil || nested == nil || top == nested {
+		t.Fatalf("Expect distinct states after s")
+	}
+
+	checkStrings(t, "nested", []string{"a ::= s * X"}, configStrings(nested.Basis()))
+}
+
+// A grammar with thousands of rules and states.
+func TestFindStatesLarge(t *testing.T) {
+	const n = 3000
+	var buf strings.Builder
+	buf.WriteString("%{\n%}\n%%\ns: a0 ;\n")
+
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, "a%d: a%d PLUS T%d | T%d a%d ;\n", i, i, i, i, i+1)
+	}
+
+	fmt.Fprintf(&buf, "a%d: END ;\n%%%%\n", n)
+
+	begin := time.Now()
+	lemon := buildStates(t, "large.y", buf.String())
+
+	if lemon.RuleCount() != 2*n+2 {
+		t.Errorf("Expect %d rules, actual %d", 2*n+2, lemon.RuleCount())
+	}
+
+	// Per level, the states after a_i, T_i, a_i PLUS and a_i PLUS T_i.
+	// Then state 0 and the states after s, END and T_(n-1) a_n.
+	if expect := 4*n + 4; lemon.nstate != expect {
+		t.Errorf("Expect %d states, actual %d", expect, lemon.nstate)
+	}
+
+	if elapsed := time.Since(begin); elapsed > 10*time.Second {
+		t.Errorf("Building the states took %v", elapsed)
+	}
+}
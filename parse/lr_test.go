@@ -0,0 +1,304 @@
+package parse
+
+import (
+	"fmt"
+	"testing"
+)
+
+func buildMode(t *testing.T, name string, src string, mode LRMode) *Lemon {
+	lemon := readGrammar(t, name, src)
+	lemon.SetLRMode(mode)
+	lemon.buildStates()
+
+	return lemon
+}
+
+func conflictCount(lemon *Lemon) int {
+	n := 0
+
+	for _, stp := range lemon.States() {
+		if conflictingLookaheads(stp, lemon.nsymbol).Len() > 0 {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Get the action of a state on the lookahead, the first of the tokens left
+// or the non-terminal just reduced, or nil for an error.
+type actionLookup func(stp *State, lookahead *Symbol, tokens []*Symbol) *Action
+
+// Run an LR parser on the tokens, with the actions of the lookup. The goto
+// after a reduce is the shift of its left hand side.
+func runLookup(lemon *Lemon, tokens []*Symbol, lookup actionLookup) bool {
+	stack := []*State{lemon.States()[0]}
+	i := 0
+
+	for {
+		lookahead := lemon.endSym
+
+		if i < len(tokens) {
+			lookahead = tokens[i]
+		}
+
+		ap := lookup(stack[len(stack)-1], lookahead, tokens[i:])
+
+		switch {
+		case ap == nil || ap.actionType == Error:
+			return false
+		case ap.actionType == Accept:
+			return true
+		case ap.actionType == Shift:
+			if lookahead == lemon.endSym {
+				return false
+			}
+
+			stack = append(stack, ap.stp)
+			i++
+		default:
+			stack = stack[:len(stack)-ap.rp.nrhs]
+			stack = append(stack, lookup(stack[len(stack)-1], ap.rp.lhs, nil).stp)
+		}
+	}
+}
+
+// Run the automaton on the tokens, reducing by the configurations. The
+// grammar must have no conflicts.
+func accepts(lemon *Lemon, tokens []*Symbol) bool {
+	return runLookup(lemon, tokens, func(stp *State, lookahead *Symbol, _ []*Symbol) *Action {
+		for _, cfp := range stp.cfp {
+			if cfp.dot == cfp.rp.nrhs && cfp.fws.Includes(lookahead.index) {
+				if cfp.rp == lemon.acceptRule {
+					return &Action{sp: lookahead, actionType: Accept}
+				}
+
+				return &Action{sp: lookahead, actionType: Reduce, rp: cfp.rp}
+			}
+		}
+
+		if next := stp.Goto(lookahead); next != nil {
+			return &Action{sp: lookahead, actionType: Shift, stp: next}
+		}
+
+		return nil
+	})
+}
+
+// Call f with every sequence of the terminals up to the length.
+func eachSentence(terminals []*Symbol, length int, f func(tokens []*Symbol)) {
+	var sentence []*Symbol
+	var rec func()
+
+	rec = func() {
+		f(sentence)
+
+		if len(sentence) == length {
+			return
+		}
+
+		for _, terminal := range terminals {
+			sentence = append(sentence, terminal)
+			rec()
+			sentence = sentence[:len(sentence)-1]
+		}
+	}
+
+	rec()
+}
+
+func TestCanonicalSameLanguage(t *testing.T) {
+	lalr := buildMode(t, "expr.y", exprGrammar, LALR)
+	canonical := buildMode(t, "expr.y", exprGrammar, Canonical)
+
+	if lalr.StateCount() != 12 || canonical.StateCount() != 22 {
+		t.Errorf("Unexpected state counts: %s", canonical.StateSummary())
+	}
+
+	if conflictCount(lalr) != 0 || conflictCount(canonical) != 0 {
+		t.Fatalf("Expect no conflict")
+	}
+
+	var terminals []*Symbol
+
+	for _, name := range []string{"PLUS", "TIMES", "LPAREN", "RPAREN", "ID"} {
+		terminals = append(terminals, mustSymbol(t, lalr, name))
+	}
+
+	accepted := 0
+
+	eachSentence(terminals, 6, func(tokens []*Symbol) {
+		// The symbols of both are the same but for their pointers.
+		other := make([]*Symbol, len(tokens))
+
+		for i, token := range tokens {
+			other[i] = mustSymbol(t, canonical, token.name)
+		}
+
+		ok := accepts(lalr, tokens)
+
+		if ok != accepts(canonical, other) {
+			t.Errorf("%q: LALR(1) and canonical LR(1) differ", symbolNames(tokens))
+		}
+
+		if ok {
+			accepted++
+		}
+	})
+
+	// E.g. "ID", "LPAREN ID RPAREN" and "ID PLUS ID TIMES ID".
+	if accepted == 0 {
+		t.Errorf("Expect some sentences to be accepted")
+	}
+}
+
+// The grammar is LR(1), but merging the states after `A C` and `B C`
+// makes a reduce/reduce conflict.
+const lr1Grammar = `%{
+%}
+%%
+s: A a D | B b D | A b E | B a E ;
+a: C ;
+b: C ;
+%%
+`
+
+func TestCanonicalSplitsStates(t *testing.T) {
+	lalr := buildMode(t, "lr1.y", lr1Grammar, LALR)
+	canonical := buildMode(t, "lr1.y", lr1Grammar, Canonical)
+
+	if conflictCount(lalr) != 1 {
+		t.Errorf("Expect a LALR(1) conflict")
+	}
+
+	if conflictCount(canonical) != 0 {
+		t.Errorf("Expect no canonical LR(1) conflict")
+	}
+
+	if summary := canonical.StateSummary(); summary != "14 canonical LR(1) states (LALR(1): 13)" {
+		t.Errorf("Unexpected summary: %s", summary)
+	}
+
+	checkLookaheads(t, canonical, walk(t, canonical, "A", "C"), "a", "", []string{"D"})
+	checkLookaheads(t, canonical, walk(t, canonical, "B", "C"), "a", "", []string{"E"})
+}
+
+func TestMinimalSplitsOnlyConflicts(t *testing.T) {
+	lalr := buildMode(t, "lr1.y", lr1Grammar, LALR)
+	minimal := buildMode(t, "lr1.y", lr1Grammar, Minimal)
+
+	if conflictCount(minimal) != 0 {
+		t.Errorf("Expect no minimal LR(1) conflict")
+	}
+
+	if summary := minimal.StateSummary(); summary != "14 minimal LR(1) states (LALR(1): 13)" {
+		t.Errorf("Unexpected summary: %s", summary)
+	}
+
+	splits := minimal.Splits()
+
+	if len(splits) != 1 {
+		t.Fatalf("Expect a split, actual %v", splits)
+	}
+
+	merged := walk(t, lalr, "A", "C").Index()
+	expect := fmt.Sprintf("LALR(1) state %d is split: state %d is kept apart from state %d, as `a ::= C *` and `b ::= C *` would share the lookaheads D E",
+		merged, walk(t, minimal, "B", "C").Index(), walk(t, minimal, "A", "C").Index())
+
+	if actual := splits[0].String(); actual != expect {
+		t.Errorf("Expect %q, actual %q", expect, actual)
+	}
+
+	// Without such conflicts, the states are the LALR(1) ones.
+	minimal = buildMode(t, "expr.y", exprGrammar, Minimal)
+
+	if minimal.StateCount() != 12 || len(minimal.Splits()) != 0 {
+		t.Errorf("Unexpected minimal LR(1) states: %s, %v", minimal.StateSummary(), minimal.Splits())
+	}
+}
+
+// Merging the states after `A` and `B` makes the follow sets of the
+// states after them grow, after they are built.
+func TestMinimalSameLanguage(t *testing.T) {
+	src := `%{
+%}
+%%
+s: A x D | B x E | A y E | B y D | C z ;
+x: a ;
+y: b ;
+z: a F | b G ;
+a: H I ;
+b: H I ;
+%%
+`
+	canonical := buildMode(t, "lr1.y", src, Canonical)
+	minimal := buildMode(t, "lr1.y", src, Minimal)
+
+	if conflictCount(canonical) != 0 || conflictCount(minimal) != 0 {
+		t.Fatalf("Expect no conflict")
+	}
+
+	if minimal.StateCount() >= canonical.StateCount() {
+		t.Errorf("Expect fewer minimal states: %s and %s", minimal.StateSummary(), canonical.StateSummary())
+	}
+
+	var terminals []*Symbol
+
+	for _, name := range []string{"A", "B", "C", "D", "E", "F", "G", "H", "I"} {
+		terminals = append(terminals, mustSymbol(t, minimal, name))
+	}
+
+	eachSentence(terminals, 5, func(tokens []*Symbol) {
+		other := make([]*Symbol, len(tokens))
+
+		for i, token := range tokens {
+			other[i] = mustSymbol(t, canonical, token.name)
+		}
+
+		if accepts(minimal, tokens) != accepts(canonical, other) {
+			t.Errorf("%q: minimal and canonical LR(1) differ", symbolNames(tokens))
+		}
+	})
+}
+
+const assignGrammar = `%{
+%}
+%token EQ STAR ID
+%%
+s: l EQ r | r ;
+l: STAR r | ID ;
+r: l ;
+%%
+`
+
+func TestSLRConflicts(t *testing.T) {
+	slr := buildMode(t, "assign.y", assignGrammar, SLR)
+	lalr := buildMode(t, "assign.y", assignGrammar, LALR)
+
+	if slr.StateCount() != lalr.StateCount() {
+		t.Errorf("Expect the same LR(0) states: %s", slr.StateSummary())
+	}
+
+	conflicts := slr.SLRConflicts()
+
+	if len(conflicts) != 1 || conflictCount(slr) != 1 || conflictCount(lalr) != 0 {
+		t.Fatalf("Expect one SLR(1) conflict, actual %v", conflicts)
+	}
+
+	stp := walk(t, slr, "l")
+	expect := fmt.Sprintf("State %d has a conflict on EQ with SLR(1) only: EQ is in FOLLOW(r), but not in the LALR(1) lookaheads of `r ::= l *`", stp.Index())
+
+	if actual := conflicts[0].String(); actual != expect {
+		t.Errorf("Expect %q, actual %q", expect, actual)
+	}
+
+	// The reduce lookaheads are the FOLLOW sets.
+	checkLookaheads(t, slr, walk(t, slr, "STAR", "l"), "r", "", []string{"$", "EQ"})
+
+	// The expression grammar is SLR(1).
+	slr = buildMode(t, "expr.y", exprGrammar, SLR)
+
+	if conflictCount(slr) != 0 || len(slr.SLRConflicts()) != 0 {
+		t.Errorf("Expect no SLR(1) conflict")
+	}
+}
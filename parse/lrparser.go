@@ -0,0 +1,388 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Write an LR parser driven by the packed action table of lemon: the
+// actions of each state on the terminals and the gotos on the
+// non-terminals are found at the offsets of the state, or are its default
+// action. The code of a rule runs when it is reduced.
+func (lemon *Lemon) writeLR(buf *bytes.Buffer) {
+	prefix := lemon.prefix()
+	lemon.writeLexerTypes(buf)
+	fmt.Fprintf(buf, lrParser, prefix, lemon.symTable.TerminalCount(), lemon.nstate, lemon.errorAction(), lemon.errorAction()+1, noOffset)
+	codes := lemon.writeTokenTables(buf)
+
+	actions := make([]int, len(lemon.actionTable))
+	lookaheads := make([]int, len(lemon.actionTable))
+
+	for i, entry := range lemon.actionTable {
+		actions[i], lookaheads[i] = entry.action, entry.lookahead
+
+		// An empty entry is never taken.
+		if entry.lookahead < 0 {
+			actions[i] = lemon.errorAction()
+		}
+	}
+
+	shiftOffsets := make([]int, len(lemon.sortedState))
+	reduceOffsets := make([]int, len(lemon.sortedState))
+	defaults := make([]int, len(lemon.sortedState))
+
+	for i, stp := range lemon.sortedState {
+		shiftOffsets[i], reduceOffsets[i], defaults[i] = stp.iTknOffset, stp.iNtOfst, stp.iDefAction
+	}
+
+	writeInts(buf, "The packed action table: the actions, and the lookaheads they are taken\non. The lookahead of a non-terminal is after the token codes.", prefix+"Actions", actions)
+	writeInts(buf, "", prefix+"Lookaheads", lookaheads)
+	writeInts(buf, "The offsets of the actions of each state on the terminals, if it has some.", prefix+"ShiftOffsets", shiftOffsets)
+	writeInts(buf, "The offsets of the gotos of each state on the non-terminals, if it has some.", prefix+"ReduceOffsets", reduceOffsets)
+	writeInts(buf, "The action of each state on the lookaheads not in the table.", prefix+"Defaults", defaults)
+
+	lemon.writeDecisions(buf, codes)
+	fmt.Fprintf(buf, "\n// The rules, by index.\nvar %sRules = []%sRule{\n", prefix, prefix)
+
+	for rp := lemon.firstRule; rp != nil; rp = rp.next {
+		fmt.Fprintf(buf, "\t%d: {%d, %d}, // %s ::= %s\n", rp.index, lemon.lookaheads[rp.lhs.index], rp.nrhs, rp.lhs.name, rp.rhsString())
+	}
+
+	buf.WriteString("}\n")
+	lemon.writeRuleActions(buf)
+
+	if extra := strings.TrimSpace(lemon.extraCode); extra != "" {
+		buf.WriteString("\n" + extra + "\n")
+	}
+}
+
+// Write the conflicts decided by more tokens of lookahead, by state and
+// token code: the strings of token codes which choose each action. They
+// replace the action of the packed table.
+func (lemon *Lemon) writeDecisions(buf *bytes.Buffer, codes map[*Symbol]int) {
+	prefix := lemon.prefix()
+	fmt.Fprintf(buf, "\n// The conflicts decided by more tokens of lookahead, by state and token\n"+
+		"// code: the strings of token codes which choose each action.\nvar %sDecisions = map[int]map[int][]%sDecision{\n", prefix, prefix)
+
+	for _, stp := range lemon.sortedState {
+		if len(stp.decisions) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(buf, "\t%d: {\n", stp.index)
+
+		for _, decision := range stp.decisions {
+			fmt.Fprintf(buf, "\t\t%d: { // %s\n", codes[decision.Lookahead], decision.Lookahead.name)
+
+			for i, ap := range decision.Actions {
+				for _, str := range decision.Strings[i] {
+					tokens := make([]string, len(str))
+
+					for j, symbol := range str {
+						tokens[j] = strconv.Itoa(codes[symbol])
+					}
+
+					fmt.Fprintf(buf, "\t\t\t{[]int{%s}, %d}, // %s: %s\n", strings.Join(tokens, ", "), lemon.decisionCode(ap),
+						lookString(str).key(), actionLabel(ap))
+				}
+			}
+
+			buf.WriteString("\t\t},\n")
+		}
+
+		buf.WriteString("\t},\n")
+	}
+
+	buf.WriteString("}\n")
+}
+
+// Get the code of an action of a conflict decided by more tokens of
+// lookahead. The reduces it drops and the default one are not in the
+// packed table.
+func (lemon *Lemon) decisionCode(ap *Action) int {
+	switch ap.actionType {
+	case LookaheadDecided, NotUsed:
+		return lemon.nstate + ap.rp.index
+	}
+
+	return lemon.actionCode(ap)
+}
+
+// Write a table of ints, 16 by line.
+func writeInts(buf *bytes.Buffer, doc string, name string, values []int) {
+	buf.WriteString("\n")
+	writeDoc(buf, doc, "")
+	fmt.Fprintf(buf, "var %s = []int{", name)
+
+	for i, value := range values {
+		if i%16 == 0 {
+			buf.WriteString("\n\t")
+		} else {
+			buf.WriteString(" ")
+		}
+
+		fmt.Fprintf(buf, "%d,", value)
+	}
+
+	buf.WriteString("\n}\n")
+}
+
+// The parser, apart from the tables and the code of the rules.
+const lrParser = `
+// The codes of the actions: a shift or a goto to a state, the number of
+// states plus a rule to reduce, then the error and the accept.
+const (
+	%[1]sNState       = %[3]d
+	%[1]sErrorAction  = %[4]d
+	%[1]sAcceptAction = %[5]d
+	%[1]sNoOffset     = %[6]d
+)
+
+// A rule: the lookahead of its left hand side, and its length.
+type %[1]sRule struct {
+	lhs int
+	n   int
+}
+
+// A string of token codes, and the action it chooses.
+type %[1]sDecision struct {
+	tokens []int
+	action int
+}
+
+// A state of the stack, and the value of the symbol which leads to it.
+type %[1]sStackEntry struct {
+	state int
+	value %[1]sSymType
+}
+
+// An LR parser: the stack, and the lookaheads read.
+type %[1]sLR struct {
+	lex    %[1]sLexer
+	stack  []%[1]sStackEntry
+	tokens []int        // The token codes of the lookaheads, -1 if unknown
+	lvals  []%[1]sSymType // Their values
+	valid  bool         // True if the lookahead is known to be shifted
+}
+
+// Parse the input of the lexer. Return 0 on success, 1 on a syntax error.
+func %[1]sParse(lex %[1]sLexer) int {
+	p := &%[1]sLR{lex: lex, stack: []%[1]sStackEntry{{state: 0}}}
+
+	for {
+		action := p.action(p.stack[len(p.stack)-1].state)
+
+		switch {
+		case action < %[1]sNState:
+			p.stack = append(p.stack, %[1]sStackEntry{action, p.lvals[0]})
+			p.tokens, p.lvals, p.valid = p.tokens[1:], p.lvals[1:], false
+		case action < %[1]sErrorAction:
+			// The code of the rules doesn't run on a lookahead the
+			// default reductions would only find wrong later.
+			if p.valid = p.valid || p.shifts(p.peek(0)); !p.valid {
+				p.fail()
+
+				return 1
+			}
+
+			p.reduce(action - %[1]sNState)
+		case action == %[1]sAcceptAction:
+			return 0
+		default:
+			p.fail()
+
+			return 1
+		}
+	}
+}
+
+// Get the token code of a lookahead, reading the tokens up to it. The
+// lexer returns 0 at the end of the input, and codes beyond the token
+// codes are characters. Nothing is read after the end of the input.
+func (p *%[1]sLR) peek(i int) int {
+	for len(p.tokens) <= i {
+		if n := len(p.tokens); n > 0 && p.tokens[n-1] == %[1]sEOF {
+			return %[1]sEOF
+		}
+
+		var lval %[1]sSymType
+		token := p.lex.Lex(&lval)
+
+		switch {
+		case token == 0:
+			token = %[1]sEOF
+		case token >= %[2]d || token < 0:
+			if code, ok := %[1]sLiterals[token]; ok {
+				token = code
+			} else {
+				token = -1
+			}
+		}
+
+		p.tokens, p.lvals = append(p.tokens, token), append(p.lvals, lval)
+	}
+
+	return p.tokens[i]
+}
+
+// Get the action of the state on the lookahead. If the state decides by
+// more tokens, it is the one of the string they start with, or the error.
+func (p *%[1]sLR) action(state int) int {
+	decisions, ok := %[1]sDecisions[state][p.peek(0)]
+
+	if !ok {
+		return %[1]sFind(%[1]sShiftOffsets, state, p.peek(0))
+	}
+
+	for _, decision := range decisions {
+		if p.matched(decision.tokens) == len(decision.tokens) {
+			return decision.action
+		}
+	}
+
+	return %[1]sErrorAction
+}
+
+// Get the number of the tokens of a string the lookaheads start with.
+func (p *%[1]sLR) matched(tokens []int) int {
+	for i, token := range tokens {
+		if p.peek(i) != token {
+			return i
+		}
+	}
+
+	return len(tokens)
+}
+
+// Find the action of the state on a lookahead in the packed table, at the
+// offset of the state for the terminals or the non-terminals, or its
+// default action.
+func %[1]sFind(offsets []int, state int, lookahead int) int {
+	if offset := offsets[state]; offset != %[1]sNoOffset && lookahead >= 0 {
+		if k := offset + lookahead; k >= 0 && k < len(%[1]sLookaheads) && %[1]sLookaheads[k] == lookahead {
+			return %[1]sActions[k]
+		}
+	}
+
+	return %[1]sDefaults[state]
+}
+
+// Reduce by the rule: run its code on the values of its symbols, and
+// replace them by the value of its left hand side. The value of the rule
+// is the one of its first symbol unless the code sets it.
+func (p *%[1]sLR) reduce(rule int) {
+	n := %[1]sRules[rule].n
+	top := len(p.stack) - n
+	yyS := make([]%[1]sSymType, n+1)
+
+	for i, entry := range p.stack[top:] {
+		yyS[i+1] = entry.value
+	}
+
+	var yyVAL %[1]sSymType
+
+	if n > 0 {
+		yyVAL = yyS[1]
+	}
+
+	%[1]sAction(rule, yyS, &yyVAL)
+	state := %[1]sFind(%[1]sReduceOffsets, p.stack[top-1].state, %[1]sRules[rule].lhs)
+	p.stack = append(p.stack[:top], %[1]sStackEntry{state, yyVAL})
+}
+
+// Report a syntax error. If the state decides by more tokens, the tokens
+// expected are the next ones of the strings which match the most, else
+// the ones the stack shifts.
+func (p *%[1]sLR) fail() {
+	if decisions, ok := %[1]sDecisions[p.stack[len(p.stack)-1].state][p.peek(0)]; ok {
+		p.failDecision(decisions)
+
+		return
+	}
+
+	var names []string
+
+	for token, name := range %[1]sTerminalNames {
+		if p.shifts(token) {
+			names = append(names, name)
+		}
+	}
+
+	p.lex.Error(%[1]sExpected(names...))
+}
+
+// Report a syntax error in the tokens which decide a conflict.
+func (p *%[1]sLR) failDecision(decisions []%[1]sDecision) {
+	longest := 0
+
+	for _, decision := range decisions {
+		if n := p.matched(decision.tokens); n > longest {
+			longest = n
+		}
+	}
+
+	expected := make([]bool, len(%[1]sTerminalNames))
+	var names []string
+
+	for _, decision := range decisions {
+		if p.matched(decision.tokens) == longest {
+			expected[decision.tokens[longest]] = true
+		}
+	}
+
+	for token, ok := range expected {
+		if ok {
+			names = append(names, %[1]sTerminalNames[token])
+		}
+	}
+
+	p.lex.Error(%[1]sExpected(names...))
+}
+
+// Check the stack shifts or accepts the token after the reductions on
+// it, without doing them: the states they push are kept apart. A token
+// which starts the strings of a conflict is expected.
+func (p *%[1]sLR) shifts(token int) bool {
+	n := len(p.stack) // The entries of the stack left
+	var pushed []int  // The states pushed on them
+
+	for {
+		state := p.stack[n-1].state
+
+		if len(pushed) > 0 {
+			state = pushed[len(pushed)-1]
+		}
+
+		if _, ok := %[1]sDecisions[state][token]; ok {
+			return true
+		}
+
+		action := %[1]sFind(%[1]sShiftOffsets, state, token)
+
+		switch {
+		case action < %[1]sNState || action == %[1]sAcceptAction:
+			return true
+		case action < %[1]sErrorAction:
+			rule := %[1]sRules[action-%[1]sNState]
+
+			if rule.n <= len(pushed) {
+				pushed = pushed[:len(pushed)-rule.n]
+			} else {
+				n, pushed = n-(rule.n-len(pushed)), pushed[:0]
+			}
+
+			state = p.stack[n-1].state
+
+			if len(pushed) > 0 {
+				state = pushed[len(pushed)-1]
+			}
+
+			pushed = append(pushed, %[1]sFind(%[1]sReduceOffsets, state, rule.lhs))
+		default:
+			return false
+		}
+	}
+}
+`
@@ -0,0 +1,258 @@
+package parse
+
+import "testing"
+
+// A calculator: precedence, character and string literals, an empty rule
+// and syntax errors.
+const calcLRGrammar = `%{
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+%}
+%union {
+	n int
+}
+%type <n> e
+%token <n> NUM
+%left '+' '-'
+%left '*' '/'
+%right "**"
+%right NEG
+%%
+lines: | lines e ';' { fmt.Println($2) } ;
+e: e '+' e { $$ = $1 + $3 }
+ | e '-' e { $$ = $1 - $3 }
+ | e '*' e { $$ = $1 * $3 }
+ | e '/' e { $$ = $1 / $3 }
+ | e "**" e { $$ = 1; for i := 0; i < $3; i++ { $$ *= $1 } }
+ | '-' e %prec NEG { $$ = -$2 }
+ | '(' e ')' { $$ = $2 }
+ | NUM ;
+%%
+type lexer struct {
+	tokens []string
+}
+
+func (l *lexer) Lex(lval *yySymType) int {
+	if len(l.tokens) == 0 {
+		return 0
+	}
+
+	token := l.tokens[0]
+	l.tokens = l.tokens[1:]
+
+	if token == "**" {
+		return LIT_2A_2A
+	}
+
+	if n, err := strconv.Atoi(token); err == nil {
+		lval.n = n
+
+		return NUM
+	}
+
+	return int(token[0])
+}
+
+func (l *lexer) Error(s string) {
+	fmt.Println(s)
+}
+
+func main() {
+	for _, input := range []string{
+		"",
+		"1 + 2 * 3 ;",
+		"( 1 + 2 ) * 3 ; 2 ** 3 ** 2 ; - 2 ** 2 ; 7 - 2 - 1 ;",
+		"1 + ;",
+		"1 2 ;",
+		"1 + 2",
+		"1 ? 2 ;",
+	} {
+		fmt.Println(yyParse(&lexer{strings.Fields(input)}))
+	}
+}
+`
+
+// Generate the LR parser of the grammar, with at most k tokens of
+// lookahead, run it and get its output.
+func runLR(t *testing.T, name string, src string, mode LRMode, k int) []string {
+	t.Helper()
+	lemon := NewLemonFromBytes(name, []byte(src), "")
+	lemon.SetLRMode(mode)
+	lemon.SetMaxLookahead(k)
+	lemon.Parse()
+
+	return runParser(t, name, lemon)
+}
+
+func TestLRParser(t *testing.T) {
+	checkStrings(t, "calc.y", []string{
+		"0",
+		"7", "0",
+		"9", "512", "4", "4", "0",
+		"syntax error: expected '(', '-' or NUM", "1",
+		"syntax error: expected \"**\", '*', '+', '-', '/' or ';'", "1",
+		"syntax error: expected \"**\", '*', '+', '-', '/' or ';'", "1",
+		"syntax error: expected \"**\", '*', '+', '-', '/' or ';'", "1",
+	}, runLR(t, "calc.y", calcLRGrammar, LALR, 1))
+}
+
+// The example passes go vet, and reads nothing without an input.
+func TestLRParserExample(t *testing.T) {
+	lemon := NewLemon("../example/expr.y", "")
+	lemon.Parse()
+	checkStrings(t, "expr.y", []string{">"}, runParser(t, "expr.y", lemon))
+}
+
+// LR(2): the reduce of C is decided by the token after X.
+const lr2LRGrammar = `%{
+package main
+
+import "fmt"
+%}
+%%
+s: a X Y { fmt.Println("s ::= a X Y") } | b X Z { fmt.Println("s ::= b X Z") } ;
+a: C { fmt.Println("a ::= C") } ;
+b: C { fmt.Println("b ::= C") } ;
+%%
+type lexer struct {
+	tokens []int
+}
+
+func (l *lexer) Lex(lval *yySymType) int {
+	if len(l.tokens) == 0 {
+		return 0
+	}
+
+	token := l.tokens[0]
+	l.tokens = l.tokens[1:]
+
+	return token
+}
+
+func (l *lexer) Error(s string) {
+	fmt.Println(s)
+}
+
+func main() {
+	fmt.Println(yyParse(&lexer{[]int{C, X, Y}}))
+	fmt.Println(yyParse(&lexer{[]int{C, X, Z}}))
+	fmt.Println(yyParse(&lexer{[]int{C, X, C}}))
+	fmt.Println(yyParse(&lexer{[]int{C, Y}}))
+	fmt.Println(yyParse(&lexer{[]int{C, X}}))
+}
+`
+
+func TestLRParserDecisions(t *testing.T) {
+	checkStrings(t, "lr2.y", []string{
+		"a ::= C", "s ::= a X Y", "0",
+		"b ::= C", "s ::= b X Z", "0",
+		"syntax error: expected Y or Z", "1",
+		"syntax error: expected X", "1",
+		"syntax error: expected Y or Z", "1",
+	}, runLR(t, "lr2.y", lr2LRGrammar, LALR, 2))
+}
+
+// The LALR and canonical parsers of the calculator take the same actions.
+func TestLRParserCanonical(t *testing.T) {
+	lalr := runLR(t, "calc.y", calcLRGrammar, LALR, 1)
+	checkStrings(t, "calc.y canonical", lalr, runLR(t, "calc.y", calcLRGrammar, Canonical, 1))
+}
+
+// LR(1) but not LALR(1): the canonical parser reduces C by the token
+// before it.
+const lr1LRGrammar = `%{
+package main
+
+import "fmt"
+%}
+%%
+s: A a D | B b D | A b E | B a E ;
+a: C { fmt.Println("a ::= C") } ;
+b: C { fmt.Println("b ::= C") } ;
+%%
+type lexer struct {
+	tokens []int
+}
+
+func (l *lexer) Lex(lval *yySymType) int {
+	if len(l.tokens) == 0 {
+		return 0
+	}
+
+	token := l.tokens[0]
+	l.tokens = l.tokens[1:]
+
+	return token
+}
+
+func (l *lexer) Error(s string) {
+	fmt.Println(s)
+}
+
+func main() {
+	fmt.Println(yyParse(&lexer{[]int{A, C, D}}))
+	fmt.Println(yyParse(&lexer{[]int{B, C, D}}))
+	fmt.Println(yyParse(&lexer{[]int{A, C, E}}))
+	fmt.Println(yyParse(&lexer{[]int{B, C, E}}))
+	fmt.Println(yyParse(&lexer{[]int{A, C, C}}))
+}
+`
+
+func TestLRParserCanonicalSplits(t *testing.T) {
+	checkStrings(t, "lr1.y", []string{
+		"a ::= C", "0",
+		"b ::= C", "0",
+		"b ::= C", "0",
+		"a ::= C", "0",
+		"syntax error: expected D or E", "1",
+	}, runLR(t, "lr1.y", lr1LRGrammar, Canonical, 1))
+}
+
+// The trace hook gets the rules reduced, by their names.
+const traceLRGrammar = `%{
+package main
+
+import "fmt"
+%}
+%%
+s: s PLUS t #Add | t ;
+t: NUM #Num ;
+%%
+type lexer struct {
+	tokens []int
+}
+
+func (l *lexer) Lex(lval *yySymType) int {
+	if len(l.tokens) == 0 {
+		return 0
+	}
+
+	token := l.tokens[0]
+	l.tokens = l.tokens[1:]
+
+	return token
+}
+
+func (l *lexer) Error(s string) {
+	fmt.Println(s)
+}
+
+func main() {
+	yyTrace = func(rule RuleID) {
+		fmt.Println(rule, rule == RuleSAdd)
+	}
+
+	fmt.Println(yyParse(&lexer{[]int{NUM, PLUS, NUM}}))
+}
+`
+
+var traceLROutput = []string{"t#Num false", "s ::= t false", "t#Num false", "s#Add true", "0"}
+
+func TestLRParserTrace(t *testing.T) {
+	checkStrings(t, "trace.y", traceLROutput, runLR(t, "trace.y", traceLRGrammar, LALR, 1))
+}
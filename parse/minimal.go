@@ -0,0 +1,104 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golemon/util"
+)
+
+// A minimal LR(1) state which is kept apart from another state with the
+// same basis, the one of a LALR(1) state, since merging them might make
+// a reduce/reduce conflict.
+type StateSplit struct {
+	State      *State     // The state kept apart
+	Other      *State     // The state it is not merged with
+	LALRState  int        // The LALR(1) state both are split from
+	Configs    [2]*Config // Configurations of State which would share lookaheads if merged
+	Lookaheads []*Symbol  // The lookaheads they would share
+}
+
+// Find why the state can't be merged with the basis, according to Pager's
+// weak compatibility: two configurations i and j of the basis would share
+// lookaheads once merged, while they don't share any in either state.
+// Return -1 if they can be merged, otherwise i, j and the lookaheads.
+func incompatibility(stp *State, basis []item) (int, int, util.BitSet) {
+	for i := range basis {
+		for j := i + 1; j < len(basis); j++ {
+			l1i, l1j := stp.bp[i].fws, stp.bp[j].fws
+			l2i, l2j := basis[i].fws, basis[j].fws
+
+			if l1i.HasIntersect(l1j) || l2i.HasIntersect(l2j) {
+				continue
+			}
+
+			shared := l1i.Intersect(l2j)
+			shared.AddSet(l2i.Intersect(l1j))
+
+			if shared.Len() > 0 {
+				return i, j, shared
+			}
+		}
+	}
+
+	return -1, -1, nil
+}
+
+func (builder *stateBuilder) newStateSplit(stp *State, other *State, basis []item) StateSplit {
+	i, j, shared := incompatibility(other, basis)
+	split := StateSplit{State: stp, Other: other, Configs: [2]*Config{stp.bp[i], stp.bp[j]}}
+
+	for _, index := range shared.AsSlice() {
+		split.Lookaheads = append(split.Lookaheads, builder.symbols[index])
+	}
+
+	return split
+}
+
+// Get the basis of the state, without the follow sets.
+func coreOf(stp *State) []item {
+	core := make([]item, len(stp.bp))
+
+	for i, cfp := range stp.bp {
+		core[i] = item{cfp.rp, cfp.dot, nil}
+	}
+
+	return core
+}
+
+// Find the LALR(1) state each split comes from.
+func (lemon *Lemon) findSplitOrigins(lalrStates []*State) {
+	table := make(map[uint64][]*State)
+
+	for _, stp := range lalrStates {
+		hash := hashBasis(coreOf(stp), false)
+		table[hash] = append(table[hash], stp)
+	}
+
+	for i := range lemon.splits {
+		core := coreOf(lemon.splits[i].State)
+
+		for _, stp := range table[hashBasis(core, false)] {
+			if sameCore(stp, core) {
+				lemon.splits[i].LALRState = stp.index
+			}
+		}
+	}
+}
+
+// Get the minimal LR(1) states which are kept apart from another state,
+// and why. They are empty in the other modes.
+func (lemon *Lemon) Splits() []StateSplit {
+	return lemon.splits
+}
+
+func (split StateSplit) String() string {
+	names := make([]string, len(split.Lookaheads))
+
+	for i, symbol := range split.Lookaheads {
+		names[i] = symbol.name
+	}
+
+	return fmt.Sprintf("LALR(1) state %d is split: state %d is kept apart from state %d, as `%v` and `%v` would share the lookaheads %s",
+		split.LALRState, split.State.index, split.Other.index, split.Configs[0], split.Configs[1], strings.Join(names, " "))
+}
@@ -0,0 +1,56 @@
+package parse
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Write the states of the parser, like the `.out` file of lemon: the
+// configurations of each state, with the lookaheads of the completed
+// ones, and its actions. Then the conflicts not resolved by precedence,
+// with their counterexamples if they are searched, the ones more tokens
+// of lookahead don't decide, the states split in minimal LR(1) mode, or
+// the conflicts only SLR(1) has in SLR(1) mode.
+func (lemon *Lemon) WriteOutput(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	symbols := lemon.symTable.SortedSymbols()
+	fmt.Fprintf(out, "// %s\n", lemon.StateSummary())
+	fmt.Fprintf(out, "// %d entries in the action table\n\n", lemon.tableSize)
+
+	for _, stp := range lemon.sortedState {
+		stp.write(out, symbols)
+		fmt.Fprintln(out)
+	}
+
+	if len(lemon.conflicts) > 0 {
+		fmt.Fprintln(out, "Conflicts:")
+		lemon.writeConflicts(out)
+	}
+
+	if undecided := lemon.undecided(); len(undecided) > 0 {
+		fmt.Fprintf(out, "Conflicts not decided by %d tokens of lookahead:\n", lemon.maxLookahead)
+
+		for _, decision := range undecided {
+			fmt.Fprintf(out, "    %v\n", decision)
+		}
+	}
+
+	if len(lemon.splits) > 0 {
+		fmt.Fprintln(out, "Split states:")
+
+		for _, split := range lemon.splits {
+			fmt.Fprintf(out, "    %v\n", split)
+		}
+	}
+
+	if len(lemon.slrConflicts) > 0 {
+		fmt.Fprintln(out, "Conflicts of SLR(1) but not LALR(1):")
+
+		for _, conflict := range lemon.slrConflicts {
+			fmt.Fprintf(out, "    %v\n", conflict)
+		}
+	}
+
+	return out.Flush()
+}
@@ -1,14 +1,13 @@
 package parse
 
 import (
-	"fmt"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/golemon/util"
 )
 
-// TODO: right hand side non-terminal must occur in rule left hand side once or defined as %type.
-
 // https://www.ibm.com/docs/en/aix/7.2?topic=information-yacc-grammar-file-declarations
 // Terminal (or token) names can be declared using the %token declaration
 // Nonterminal names can be declared using the %type declaration.
@@ -55,7 +54,8 @@ const (
 	WaitRuleRhsSymbol
 	WaitPrecedence
 	WaitPrecedenceTerm
-	WaitSymbolAfterPrec
+	WaitRuleEnd
+	WaitRuleLabel
 
 	WaitSubRoutine1
 	WaitSubRoutine2
@@ -73,6 +73,10 @@ const (
 	KwStart
 	KwPrec
 	KwUnion
+	KwName
+	KwExpect
+	KwExpectRR
+	KwGLR
 )
 
 // TODO: case sensitivity
@@ -85,19 +89,24 @@ var ReservedKeywords = map[Keyword]string{
 	KwStart:    "START",
 	KwPrec:     "PREC",
 	KwUnion:    "UNION",
+	KwName:     "NAME",
+	KwExpect:   "EXPECT",
+	KwExpectRR: "EXPECT-RR",
+	KwGLR:      "GLR",
 }
 
 // The state of the parser.
 type ParserState struct {
 	gp              *Lemon   // The owner of this parser state
 	errorCnt        int      // Number of errors so far
-	currToken       *Token   // Text of current token
 	curState        FsmState // Current state of the parser
-	importCode      []rune   // Import code inside %{ %}
+	importCode      string   // Import code inside %{ %}
 	unionCode       string   // Union type definition
 	unionCodeLineno int      // Union code line number
 	datatype        string   // %type definition
-	startTokLineno  int      // Start token line number
+	startTokPos     Position // Start token position
+	doc             string   // Doc comment of the current token
+	declDoc         string   // Doc comment of the current declaration
 	prevKeyword     Keyword  // Previous keyword
 	prevTag         string
 	// lhs            *Symbol     // Left-hand side of current rule
@@ -139,8 +148,10 @@ func stateToString(state FsmState) string {
 		return "Wait `prec`"
 	case WaitPrecedenceTerm:
 		return "Wait precedence terminal"
-	case WaitSymbolAfterPrec:
-		return "Wait symbol after precedence"
+	case WaitRuleEnd:
+		return "Wait end of rule"
+	case WaitRuleLabel:
+		return "Wait rule label"
 	case WaitSubRoutine1:
 		return "Wait subroutine1"
 	case WaitSubRoutine2:
@@ -152,11 +163,14 @@ func stateToString(state FsmState) string {
 }
 
 func NewParserState(gp *Lemon) *ParserState {
+	symTable := NewSymbolTable()
+	symTable.SetClassifier(gp.classifier)
+
 	return &ParserState{
 		gp:          gp,
 		subroutine:  &strings.Builder{},
 		prevKeyword: KwUnknown,
-		symTable:    NewSymbolTable(),
+		symTable:    symTable,
 	}
 }
 
@@ -169,31 +183,25 @@ func (ps *ParserState) appendRule(rule *Rule) {
 
 	ps.lastRule = rule
 	ps.prevRule = rule
+	rule.index = ps.gp.nrule
 	ps.gp.nrule++
 }
 
-func (ps *ParserState) parseOneToken(token *Token) {
-	runeCount := token.RuneCount()
-	tokenStr := token.String()
+func (ps *ParserState) parseOneToken(token Span, tokenStr string) {
 	upperStr := strings.ToUpper(tokenStr)
-	fstRune := token.FirstRune()
-	lstRune := token.LastRune()
-	startLineno := ps.startTokLineno
+	fstRune, _ := utf8.DecodeRuneInString(tokenStr)
+	lstRune, _ := utf8.DecodeLastRuneInString(tokenStr)
+	startPos := ps.startTokPos
 	filename := ps.gp.InputFile()
 	symTable := ps.symTable
 
-	fmt.Println("State=", stateToString(ps.curState), "[Token=", tokenStr, "]")
-
-	if tokenStr == "'('" {
-		fmt.Println()
-	}
-
 	switch ps.curState {
 	case WaitPercentSign:
 		if fstRune != '%' {
 			ps.errorCnt++
-			errorf(filename, startLineno, "Declaration must start with `%{` and end with `%}`. Find: `%s`", tokenStr)
+			errorf(filename, startPos, "Expect `%%{`, `%%keyword` or `%%%%` at the beginning. Find: `%s`", tokenStr)
 		} else {
+			ps.declDoc = ps.doc
 			ps.curState = WaitOpenBrace
 		}
 
@@ -203,21 +211,25 @@ func (ps *ParserState) parseOneToken(token *Token) {
 		// 1. First rune must be `{`.
 		// 2. Last rune must be `}`.
 		// 3. Second last rune must be `%`.
-		if fstRune != '{' || lstRune != '}' || token.NthRune(runeCount-2) != '%' {
+		if token.Kind != TkCode {
+			// The `%{ %}` prologue is optional, like in yacc.
+			ps.curState = WaitKwDefOrRule2
+			ps.parseOneToken(token, tokenStr)
+		} else if !strings.HasSuffix(tokenStr, "%}") {
 			ps.errorCnt++
-			errorf(filename, startLineno, "Declaration must start with `%{` and end with `%}`. Find: `%s`", tokenStr)
+			errorf(filename, startPos, "Declaration must start with `%%{` and end with `%%}`. Find: `%s`", tokenStr)
 		} else {
-			// Ignore first `{` and last `}`.
-			ps.importCode = make([]rune, runeCount-2)
-			copy(ps.importCode, token.Buffer()[1:runeCount])
+			// Ignore first `{` and last `%}`.
+			ps.importCode = tokenStr[1 : len(tokenStr)-2]
 			ps.curState = WaitKwDefOrRule1
 		}
 
 	case WaitKwDefOrRule1:
 		if fstRune != '%' {
 			ps.errorCnt++
-			errorf(filename, startLineno, "Expect `%keyword` to declare keyword or `%%` to start rule definition. Find: `%s`", tokenStr)
+			errorf(filename, startPos, "Expect `%%keyword` to declare keyword or `%%%%` to start rule definition. Find: `%s`", tokenStr)
 		} else {
+			ps.declDoc = ps.doc
 			ps.curState = WaitKwDefOrRule2
 		}
 
@@ -225,6 +237,8 @@ func (ps *ParserState) parseOneToken(token *Token) {
 		if fstRune == '%' {
 			ps.curState = WaitRuleLhsSymbol
 		} else {
+			ps.prevKeyword = KwUnknown
+
 			for k, v := range ReservedKeywords {
 				if v == upperStr {
 					ps.prevKeyword = k
@@ -234,8 +248,19 @@ func (ps *ParserState) parseOneToken(token *Token) {
 
 			if ps.prevKeyword == KwUnknown {
 				ps.errorCnt++
-				errorf(filename, startLineno, "Expect `%keyword` to declare keyword or `%%` to start rule definition. Find: `%s`", tokenStr)
+				errorf(filename, startPos, "Expect `%%keyword` to declare keyword or `%%%%` to start rule definition. Find: `%s`", tokenStr)
+			} else if ps.prevKeyword == KwGLR {
+				// `%glr` has no argument.
+				ps.gp.glr = true
+				ps.curState = WaitKwDefOrRule1
 			} else {
+				// All the terminals of a declaration have the same
+				// precedence, higher than the ones declared before.
+				switch ps.prevKeyword {
+				case KwLeft, KwRight, KwNonassoc:
+					ps.precCounter++
+				}
+
 				ps.curState = WaitOptTagOrOpenBrace
 			}
 		}
@@ -248,27 +273,63 @@ func (ps *ParserState) parseOneToken(token *Token) {
 		// 5. %right [<tag>] terminal
 		// 6. %nonassoc [<tag>] terminal
 		// 7. %start [<tag>] non-terminal
-		if ps.prevKeyword == KwUnion {
+		// 8. %name prefix
+		// 9. %expect number
+		// 10. %expect-rr number
+		if ps.prevKeyword == KwExpect || ps.prevKeyword == KwExpectRR {
+			count, err := strconv.Atoi(tokenStr)
+
+			if token.Kind != TkIdent || err != nil || count < 0 {
+				ps.errorCnt++
+				errorf(filename, startPos, "Expect the number of conflicts after `%%%s`: `%s`", strings.ToLower(ReservedKeywords[ps.prevKeyword]), tokenStr)
+			} else if ps.prevKeyword == KwExpect {
+				if ps.gp.expectSR >= 0 {
+					ps.errorCnt++
+					errorf(filename, startPos, "The number of shift/reduce conflicts is already declared: `%s`", tokenStr)
+				}
+
+				ps.gp.expectSR = count
+				ps.gp.expectPos = startPos
+			} else {
+				if ps.gp.expectRR >= 0 {
+					ps.errorCnt++
+					errorf(filename, startPos, "The number of reduce/reduce conflicts is already declared: `%s`", tokenStr)
+				}
+
+				ps.gp.expectRR = count
+				ps.gp.expectPos = startPos
+			}
+
+			ps.curState = WaitKwDefOrRule1
+		} else if ps.prevKeyword == KwName {
+			if token.Kind != TkIdent {
+				ps.errorCnt++
+				errorf(filename, startPos, "Expect the name of the parser after `%%name`: `%s`", tokenStr)
+			} else {
+				ps.gp.name = tokenStr
+				ps.curState = WaitKwDefOrRule1
+			}
+		} else if ps.prevKeyword == KwUnion {
 			if fstRune != '{' || lstRune != '}' {
 				ps.errorCnt++
-				errorf(filename, startLineno, "Expect `{}` after `%union`: `%s`", tokenStr)
+				errorf(filename, startPos, "Expect `{}` after `%%union`: `%s`", tokenStr)
 			} else {
 				// TODO: union declared once?
 				if len(ps.unionCode) > 0 {
 					ps.errorCnt++
-					errorf(filename, startLineno, "Multiple `%union` definitions are found. Previous definition is at: %d", ps.unionCodeLineno)
+					errorf(filename, startPos, "Multiple `%%union` definitions are found. Previous definition is at: %d", ps.unionCodeLineno)
 				} else {
 					ps.unionCode = tokenStr
-					ps.unionCodeLineno = startLineno
+					ps.unionCodeLineno = startPos.Line
 					ps.curState = WaitKwDefOrRule1
 				}
 			}
 		} else if fstRune == '<' {
 			if ps.prevKeyword == KwUnion {
 				ps.errorCnt++
-				errorf(filename, startLineno, "Tag specifier `<>` can't follow after `%union`: `%s`", tokenStr)
+				errorf(filename, startPos, "Tag specifier `<>` can't follow after `%%union`: `%s`", tokenStr)
 			} else {
-				ps.prevTag = string(token.Buffer()[1:runeCount])
+				ps.prevTag = tokenStr[1 : len(tokenStr)-1]
 				ps.curState = WaitSymbolAfterKeyword
 			}
 		} else {
@@ -281,6 +342,7 @@ func (ps *ParserState) parseOneToken(token *Token) {
 			// We need to clear previous keyword and tag.
 			ps.prevKeyword = KwUnknown
 			ps.prevTag = ""
+			ps.declDoc = ps.doc
 			ps.curState = WaitKwDefOrRule2
 		} else {
 			ps.defineSymbol(tokenStr)
@@ -292,16 +354,29 @@ func (ps *ParserState) parseOneToken(token *Token) {
 		if fstRune == '%' {
 			if ps.gp.RuleCount() == 0 {
 				ps.errorCnt++
-				errorf(filename, startLineno, "Unexpected `%%`, at least 1 rule must be defined.")
+				errorf(filename, startPos, "Unexpected `%%`, at least 1 rule must be defined.")
 			} else {
 				ps.curState = WaitSubRoutine1
 			}
-		} else if !util.IsLower(tokenStr) {
+		} else if util.IsStringLiteral(tokenStr) {
 			ps.errorCnt++
-			errorf(filename, startLineno, "For rule definition, left hand side symbol must be non-terminal: `%s`.", tokenStr)
+			errorf(filename, startPos, "For rule definition, left hand side symbol must be non-terminal: `%s`.", tokenStr)
 		} else {
 			symbol := symTable.Insert(tokenStr)
-			rule := NewRule(symbol, startLineno)
+
+			// A terminal on the left hand side is reported by verifyAllSymbols.
+			symTable.fixKind(symbol, NonTerminal, startPos)
+
+			if !symbol.lhsPos.IsValid() {
+				symbol.lhsPos = startPos
+			}
+
+			// The comment above the rules documents the non-terminal.
+			if symbol.doc == "" {
+				symbol.doc = ps.doc
+			}
+
+			rule := NewRule(symbol, startPos.Line)
 			ps.appendRule(rule)
 
 			ps.curState = WaitColon
@@ -310,8 +385,9 @@ func (ps *ParserState) parseOneToken(token *Token) {
 	case WaitColon:
 		if fstRune != ':' {
 			ps.errorCnt++
-			errorf(filename, startLineno, "Expect `:` after non-terminal: `%s`", tokenStr)
+			errorf(filename, startPos, "Expect `:` after non-terminal: `%s`", tokenStr)
 		} else {
+			ps.prevRule.doc = ps.doc
 			ps.curState = WaitRuleRhsSymbol
 		}
 
@@ -319,45 +395,59 @@ func (ps *ParserState) parseOneToken(token *Token) {
 		prevRule := ps.prevRule
 		// For grammar `lhs: | expr;`.
 		// There is no symbol on the right hand side for the first rule.
-		// This means `lhs` symbol coule be nullable.
+		// It is an empty rule, so `lhs` is nullable.
 		if fstRune == '|' {
-			count := prevRule.GetRhsSymbolCount()
 			symbol := prevRule.GetLhsSymbol()
 
-			if count == 0 {
-				// TODO: how about `symbol := | | {}`. This is a warnning in bison.
-				if symbol.nullable {
-					ps.errorCnt++
-					errorf(filename, startLineno, "Find multiple empty expression for: `%s`", symbol.Name())
+			if prevRule.GetRhsSymbolCount() == 0 {
+				for other := symbol.rule; other != nil; other = other.nextlhs {
+					if other != prevRule && other.nrhs == 0 {
+						ps.errorCnt++
+						errorf(filename, startPos, "Find multiple empty expression for: `%s`", symbol.Name())
+					}
 				}
-				symbol.nullable = true
-			} else {
-				// TODO: previous rule may needs default action code.
-				rule := NewRule(symbol, startLineno)
-				ps.appendRule(rule)
 			}
+
+			// TODO: previous rule may needs default action code.
+			rule := NewRule(symbol, startPos.Line)
+			rule.doc = ps.doc
+			ps.appendRule(rule)
 		} else if fstRune == '{' {
 			// TODO: check {}{}
 			// Grammar like: `expr: {}` is ok.
-			prevRule.SetCodeAndLine(tokenStr, startLineno)
+			prevRule.SetCodeAndLine(tokenStr, startPos.Line)
+			ps.curState = ps.rhsState()
 		} else if fstRune == '%' {
 			ps.curState = WaitPrecedence
+		} else if fstRune == '#' {
+			ps.curState = WaitRuleLabel
 		} else if fstRune == ';' {
 			// End of this rule.
 			ps.prevRule = nil
 			ps.curState = WaitRuleLhsSymbol
 		} else {
-			// ps.errorCnt++
-			// errorf(filename, startLineno, "Expect right hand symbol but find `%s`", tokenStr)
-			else if symbol, ok := symTable.Get(tokenStr); ok {
-				ps.prevRule.AppendRhsSymbol(symbol)
-			} 
+			// Symbols not seen before are classified, misuses are reported
+			// by verifyAllSymbols once all rules are read.
+			symbol := symTable.Insert(tokenStr)
+
+			if !symbol.usePos.IsValid() {
+				symbol.usePos = startPos
+			}
+
+			// A comment before the first symbol documents the alternative.
+			if prevRule.nrhs == 0 && prevRule.doc == "" {
+				prevRule.doc = ps.doc
+			}
+
+			prevRule.AppendRhsSymbol(symbol)
 		}
 
 	case WaitPrecedence:
-		if upperStr != ReservedKeywords[KwPrec] {
+		if upperStr == ReservedKeywords[KwName] {
+			ps.curState = WaitRuleLabel
+		} else if upperStr != ReservedKeywords[KwPrec] {
 			ps.errorCnt++
-			errorf(filename, startLineno, "Expect `%%prec`. Find: `%s`.", &tokenStr)
+			errorf(filename, startPos, "Expect `%%prec` or `%%name`. Find: `%s`.", tokenStr)
 		} else {
 			ps.curState = WaitPrecedenceTerm
 		}
@@ -365,32 +455,50 @@ func (ps *ParserState) parseOneToken(token *Token) {
 	case WaitPrecedenceTerm:
 		if symbol, ok := symTable.Get(tokenStr); !ok || symbol == nil {
 			ps.errorCnt++
-			errorf(filename, startLineno, "Terminal after `%%prec` must be defined: `%s`.", tokenStr)
+			errorf(filename, startPos, "Terminal after `%%prec` must be defined: `%s`.", tokenStr)
 		} else {
+			if !symbol.usePos.IsValid() {
+				symbol.usePos = startPos
+			}
+
 			ps.prevRule.precSym = symbol
-			ps.curState = WaitSymbolAfterPrec
+			ps.curState = WaitRuleEnd
 		}
 
-	case WaitSymbolAfterPrec:
+	case WaitRuleEnd:
+		// `%prec` ends the right hand side: only the code, the label, `|`
+		// or `;` may follow, and are handled like after any symbol.
 		switch fstRune {
-		case '{':
-			// TODO: include `{}`?
-			ps.prevRule.code = tokenStr
-			fallthrough
-		case '|':
-			// TODO: default action.
-			fallthrough
-		case ';':
-			ps.prevRule = nil
-			ps.curState = WaitRuleLhsSymbol
+		case '|', ';', '{', '%', '#':
+			ps.curState = WaitRuleRhsSymbol
+			ps.parseOneToken(token, tokenStr)
 		default:
-			errorf(filename, startLineno, "Expect `|` or `{` or `;` after `%%prec term`: `%s`", tokenStr)
+			ps.errorCnt++
+			errorf(filename, startPos, "Expect `;`, `|`, code or a label after `%%prec %s`. Find: `%s`", ps.prevRule.precSym.name, tokenStr)
+		}
+
+	case WaitRuleLabel:
+		// `%name Add` or `#Add`.
+		rule := ps.prevRule
+
+		if token.Kind != TkIdent {
+			ps.errorCnt++
+			errorf(filename, startPos, "Expect a label after `%%name` or `#`: `%s`", tokenStr)
+		} else if rule.label != "" {
+			ps.errorCnt++
+			errorf(filename, startPos, "Rule `%v` already has the label `%s`.", rule, rule.label)
+		} else if other := rule.lhs.ruleByLabel(tokenStr); other != nil {
+			ps.errorCnt++
+			errorf(filename, startPos, "Label `%s` is already used by another rule of `%s` at %s:%d.", tokenStr, rule.lhs.name, filename, other.ruleLineno)
+		} else {
+			rule.label = tokenStr
+			ps.curState = ps.rhsState()
 		}
 
 	case WaitSubRoutine1:
 		if fstRune != '%' {
 			ps.errorCnt++
-			errorf(filename, startLineno, "Expect `%%` after `%%` before subroutine: `%s`", tokenStr)
+			errorf(filename, startPos, "Expect `%%` after `%%` before subroutine: `%s`", tokenStr)
 		} else {
 			ps.curState = WaitSubRoutine2
 		}
@@ -400,13 +508,37 @@ func (ps *ParserState) parseOneToken(token *Token) {
 	}
 }
 
+// Get the state after the code or the label of the rule: no symbol may
+// follow them once `%prec` is given.
+func (ps *ParserState) rhsState() FsmState {
+	if ps.prevRule.precSym != nil {
+		return WaitRuleEnd
+	}
+
+	return WaitRuleRhsSymbol
+}
+
 // Define a symbol based on previous keyword.
 func (ps *ParserState) defineSymbol(symName string) *Symbol {
 	kw := ps.prevKeyword
-	startLineno := ps.startTokLineno
+	startPos := ps.startTokPos
 	filename := ps.gp.InputFile()
 	symTable := ps.symTable
 
+	// The start symbol is defined by the rules, it is checked once they
+	// are read.
+	if kw == KwStart {
+		if ps.gp.start != "" {
+			ps.errorCnt++
+			errorf(filename, startPos, "The start symbol is already `%s`: `%s`", ps.gp.start, symName)
+		}
+
+		ps.gp.start = symName
+		ps.gp.startPos = startPos
+
+		return nil
+	}
+
 	// TODO: is it ok insert before `errorf`
 	symbol := symTable.Insert(symName)
 
@@ -414,28 +546,40 @@ func (ps *ParserState) defineSymbol(symName string) *Symbol {
 		symbol.datatype = ps.prevTag
 	}
 
+	// A comment right before the symbol wins over the one before `%keyword`.
+	if doc := ps.doc; symbol.doc == "" {
+		if doc == "" {
+			doc = ps.declDoc
+		}
+
+		symbol.doc = doc
+	}
+
 	switch kw {
 	case KwType:
-		if !util.IsLower(symName) {
-			errorf(filename, startLineno, "Non-terminal must be lower case: `%s`", symName)
-		} else {
-			symbol.symType = NonTerminal
+		// `%type` only gives the data type. Whether the symbol is a
+		// non-terminal is decided by the rules.
+		if !symbol.typePos.IsValid() {
+			symbol.typePos = startPos
 		}
 
 	case KwToken:
+		if !symbol.tokenPos.IsValid() {
+			symbol.tokenPos = startPos
+		}
+
 		// '+' or NUMBER.
-		if !util.IsUpper(symName) && !util.IsStringLiteral(symName) {
-			errorf(filename, startLineno, "Terminal must be upper case or string literal: `%s`", symName)
-		} else {
-			symbol.symType = Terminal
+		if pos, ok := symTable.fixKind(symbol, Terminal, startPos); !ok {
+			ps.errorCnt++
+			errorf(filename, startPos, "Terminal `%s` is defined as non-terminal at %s:%v", symName, filename, pos)
 		}
 
 	case KwLeft, KwRight, KwNonassoc:
 		// Must be terminal.
-		if !util.IsUpper(symName) {
-			errorf(filename, startLineno, "%s must followed by terminal: `%s`", ReservedKeywords[kw], symName)
+		if pos, ok := symTable.fixKind(symbol, Terminal, startPos); !ok {
+			ps.errorCnt++
+			errorf(filename, startPos, "%s must followed by terminal: `%s` is defined as non-terminal at %s:%v", ReservedKeywords[kw], symName, filename, pos)
 		} else {
-			symbol.symType = Terminal
 			switch kw {
 			case KwLeft:
 				symbol.assoc = Left
@@ -445,103 +589,13 @@ func (ps *ParserState) defineSymbol(symName string) *Symbol {
 				symbol.assoc = None
 			}
 			symbol.precedence = ps.precCounter
-			ps.precCounter++
+			symbol.precPos = startPos
 		}
 
-	case KwStart, KwPrec:
-		fmt.Println("Not implement KwStart")
+	case KwPrec:
+		ps.errorCnt++
+		errorf(filename, startPos, "`%%prec` can only be used in a rule: `%s`", symName)
 	}
 
 	return symbol
 }
-
-// Duplicate the input file without comments and without actions on rules.
-func (ps *ParserState) Reprint() {
-	maxLen := 10
-	sortedSymbols := ps.symTable.SortedSymbols()
-
-	fmt.Printf("// Reprint of input file \"%s\".\n// Symbols:\n", ps.gp.InputFile())
-
-	for _, sym := range sortedSymbols {
-		nameLen := len(sym.Name())
-
-		if nameLen > maxLen {
-			maxLen = nameLen
-		}
-	}
-
-	ncolumns := 76 / (maxLen + 5)
-
-	if ncolumns < 1 {
-		ncolumns = 1
-	}
-
-	skip := (len(sortedSymbols) + ncolumns - 1) / ncolumns
-	for i := 0; i < skip; i++ {
-		fmt.Printf("//")
-		for j := i; j < len(sortedSymbols); j += skip {
-			fmt.Printf(" %3d %-*.*s", j, maxLen, maxLen, sortedSymbols[j].Name())
-		}
-		fmt.Println()
-	}
-
-	for rule := ps.firstRule; rule != nil; rule = rule.next {
-		fmt.Println(rule.String())
-	}
-}
-
-// Those rules which have a precedence symbol coded in the input
-// grammar using the "[symbol]" construct will already have the
-// rp->precsym field filled. Other rules take as their precedence
-// symbol the first RHS symbol with a defined precedence. If there
-// are not RHS symbols with a defined precedence, the precedece
-// symbol field is left blank.
-func (ps *ParserState) updateRulePrecedences() {
-	for rp := ps.firstRule; rp != nil; rp = rp.next {
-		if rp.precSym == nil {
-			continue
-		}
-
-		rp.updatePrecedence()
-	}
-}
-
-// For each terminal t
-//   Nullable(t) = false
-// For each non-terminal N
-//   Nullable(N) = is there a production N ::= ε(epsilon)
-// Repeat
-//   For each production N ::= x1x2x3...xn
-//     If Nullable(xi) for all of xi then set Nullable(N) to true
-// Util nothing new becomes Nullable
-func (ps *ParserState) computeNullableSets() {
-	changed := true
-
-	for changed {
-		changed = false
-
-		for rp := ps.firstRule; rp != nil; rp = rp.next {
-			symbol := rp.GetLhsSymbol()
-
-			if symbol.IsNullable() {
-				continue
-			}
-
-			changed = changed || rp.computeNullable()
-		}
-	}
-}
-
-func (ps *ParserState) computeFirstSets() {
-	ps.computeNullableSets()
-
-	for rule := ps.firstRule; rule != nil; rule = rule.next {
-		rule.computeFirstSet()
-	}
-}
-
-// Print the first sets.
-func (ps *ParserState) PrintFirstSets() {
-	ps.computeFirstSets()
-	ps.symTable.PrintFirstSets()
-}
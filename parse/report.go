@@ -0,0 +1,176 @@
+package parse
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+type ReportFormat int
+
+const (
+	ReportMarkdown ReportFormat = iota
+	ReportHTML
+)
+
+// Report formats selectable by name, e.g. from the command line.
+var ReportFormats = map[string]ReportFormat{
+	"md":   ReportMarkdown,
+	"html": ReportHTML,
+}
+
+// Get the file extension of the report format.
+func (format ReportFormat) Extension() string {
+	if format == ReportHTML {
+		return ".html"
+	}
+
+	return ".md"
+}
+
+// Get the right hand side of the rule as text. An empty one is `ε`.
+func (rule *Rule) rhsString() string {
+	if rule.nrhs == 0 {
+		return "ε"
+	}
+
+	names := make([]string, rule.nrhs)
+
+	for i, symbol := range rule.rhs[:rule.nrhs] {
+		names[i] = symbol.name
+	}
+
+	return strings.Join(names, " ")
+}
+
+// Get the rules of a non-terminal in the order of the grammar file.
+func (lemon *Lemon) rulesOf(symbol *Symbol) []*Rule {
+	var rules []*Rule
+
+	for rule := lemon.firstRule; rule != nil; rule = rule.next {
+		if rule.lhs == symbol {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
+
+// Write a report documenting the symbols and rules of the grammar with
+// their doc comments.
+func (lemon *Lemon) WriteReport(w io.Writer, format ReportFormat) error {
+	out := bufio.NewWriter(w)
+
+	if format == ReportHTML {
+		lemon.writeHTMLReport(out)
+	} else {
+		lemon.writeMarkdownReport(out)
+	}
+
+	return out.Flush()
+}
+
+// Escape text inside a Markdown table cell.
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(text, "|", `\|`)
+
+	return strings.ReplaceAll(text, "\n", "<br>")
+}
+
+func (lemon *Lemon) writeMarkdownReport(out *bufio.Writer) {
+	fmt.Fprintf(out, "# Grammar `%s`\n\n## Terminals\n\n", filepath.Base(lemon.infile))
+	out.WriteString("| Code | Symbol | Type | Description |\n| ---: | --- | --- | --- |\n")
+
+	for i, symbol := range lemon.symTable.terminals() {
+		fmt.Fprintf(out, "| %d | `%s` | %s | %s |\n", i, markdownCell(symbol.name),
+			markdownCell(symbol.datatype), markdownCell(symbol.doc))
+	}
+
+	out.WriteString("\n## Non-terminals\n")
+
+	for _, symbol := range lemon.symTable.SortedSymbols() {
+		if symbol.IsTerminal() {
+			continue
+		}
+
+		fmt.Fprintf(out, "\n### `%s`\n\n", symbol.name)
+
+		if symbol.doc != "" {
+			out.WriteString(symbol.doc + "\n\n")
+		}
+
+		for _, rule := range lemon.rulesOf(symbol) {
+			fmt.Fprintf(out, "- `%s ::= %s`", symbol.name, rule.rhsString())
+
+			if rule.label != "" {
+				fmt.Fprintf(out, " **#%s**", rule.label)
+			}
+
+			if rule.doc != "" {
+				out.WriteString(" — " + strings.ReplaceAll(rule.doc, "\n", " "))
+			}
+
+			out.WriteString("\n")
+		}
+	}
+}
+
+// Write a doc comment as HTML paragraphs.
+func writeHTMLDoc(out *bufio.Writer, doc string) {
+	for _, paragraph := range strings.Split(doc, "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			fmt.Fprintf(out, "<p>%s</p>\n", html.EscapeString(paragraph))
+		}
+	}
+}
+
+func (lemon *Lemon) writeHTMLReport(out *bufio.Writer) {
+	name := html.EscapeString(filepath.Base(lemon.infile))
+	fmt.Fprintf(out, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Grammar %s</title>\n</head>\n<body>\n", name)
+	fmt.Fprintf(out, "<h1>Grammar <code>%s</code></h1>\n<h2>Terminals</h2>\n<table>\n", name)
+	out.WriteString("<tr><th>Code</th><th>Symbol</th><th>Type</th><th>Description</th></tr>\n")
+
+	for i, symbol := range lemon.symTable.terminals() {
+		fmt.Fprintf(out, "<tr><td>%d</td><td><code>%s</code></td><td>%s</td><td>%s</td></tr>\n", i,
+			html.EscapeString(symbol.name), html.EscapeString(symbol.datatype), html.EscapeString(symbol.doc))
+	}
+
+	out.WriteString("</table>\n<h2>Non-terminals</h2>\n")
+
+	for _, symbol := range lemon.symTable.SortedSymbols() {
+		if symbol.IsTerminal() {
+			continue
+		}
+
+		fmt.Fprintf(out, "<h3 id=\"%[1]s\"><code>%[1]s</code></h3>\n", html.EscapeString(symbol.name))
+		writeHTMLDoc(out, symbol.doc)
+		out.WriteString("<ul>\n")
+
+		for _, rule := range lemon.rulesOf(symbol) {
+			if rule.label != "" {
+				fmt.Fprintf(out, "<li id=\"%s-%s\">", html.EscapeString(symbol.name), rule.label)
+			} else {
+				out.WriteString("<li>")
+			}
+
+			fmt.Fprintf(out, "<code>%s ::= %s</code>", html.EscapeString(symbol.name), html.EscapeString(rule.rhsString()))
+
+			if rule.label != "" {
+				fmt.Fprintf(out, " <strong>#%s</strong>", rule.label)
+			}
+
+			if rule.doc != "" {
+				out.WriteString(" — " + html.EscapeString(strings.ReplaceAll(rule.doc, "\n", " ")))
+			}
+
+			out.WriteString("</li>\n")
+		}
+
+		out.WriteString("</ul>\n")
+	}
+
+	out.WriteString("</body>\n</html>\n")
+}
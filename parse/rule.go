@@ -17,6 +17,8 @@ type Rule struct {
 	canReduce  bool      // True if this rule is ever reduced
 	nextlhs    *Rule     // Next rule with the same LHS
 	next       *Rule     // Next rule in the global list
+	doc        string    // Doc comment of this alternative
+	label      string    // Name given by `%name Add` or `#Add`, unique among the rules of lhs
 }
 
 func NewRule(symbol *Symbol, ruleLineno int) *Rule {
@@ -47,14 +49,46 @@ func (rule *Rule) GetRhsSymbolCount() int {
 	return rule.nrhs
 }
 
+// Get the symbols on the right hand side of the rule.
+func (rule *Rule) Rhs() []*Symbol {
+	return rule.rhs[:rule.nrhs]
+}
+
+// Get the code executed when the rule is reduced, with its braces.
+func (rule *Rule) Code() string {
+	return rule.code
+}
+
+// Get the terminal which gives its precedence to the rule: the one of
+// `%prec`, or the last terminal with a precedence. It may be nil.
+func (rule *Rule) PrecSymbol() *Symbol {
+	return rule.precSym
+}
+
+// Get the label of the rule, or an empty string.
+func (rule *Rule) Label() string {
+	return rule.label
+}
+
+// Get the index of the rule in the order of the grammar file.
+func (rule *Rule) Index() int {
+	return rule.index
+}
+
+// Get the doc comment of the rule, without comment markers.
+func (rule *Rule) Doc() string {
+	return rule.doc
+}
+
 func (rule *Rule) SetCodeAndLine(code string, line int) {
 	rule.code = code
 	rule.line = line
 }
 
 // Get a string representation of this rule.
-// This is `LHS: RHS.`. The righ hand side may be empty if
-// the symbol on left hand side could be nullable.
+// This is `LHS: RHS.`, followed by `#Label` if the rule has one.
+// The righ hand side may be empty if the symbol on left hand side
+// could be nullable.
 func (rule *Rule) String() string {
 	var buf bytes.Buffer
 	buf.WriteString(rule.lhs.Name())
@@ -76,60 +110,18 @@ func (rule *Rule) String() string {
 		buf.WriteRune(']')
 	}
 
+	if rule.label != "" {
+		buf.WriteString(" #" + rule.label)
+	}
+
 	return buf.String()
 }
 
 func (rule *Rule) updatePrecedence() {
-	for i := 0; i < rule.nrhs; i++ {
+	for i := rule.nrhs - 1; i >= 0; i-- {
 		if rule.rhs[i].precedence >= 0 {
 			rule.precSym = rule.rhs[i]
 			break
 		}
 	}
 }
-
-func (rule *Rule) computeNullable() bool {
-	for i := 0; i < rule.nrhs; i++ {
-		if !rule.rhs[i].nullable {
-			return false
-		}
-	}
-
-	rule.lhs.nullable = true
-
-	return true
-}
-
-func (rule *Rule) computeFirstSet() bool {
-	changed := false
-	lhsSymbol := rule.lhs
-
-	for i := 0; i < rule.nrhs; i++ {
-		rhsSymbol := rule.rhs[i]
-		index := rhsSymbol.index
-
-		if rhsSymbol.IsTerminal() {
-			if !lhsSymbol.firstset.Includes(index) {
-				changed = true
-				lhsSymbol.firstset.Add(index)
-			}
-		} else if lhsSymbol == rhsSymbol {
-			if !lhsSymbol.IsNullable() {
-				break
-			}
-		} else {
-			oldLen := lhsSymbol.firstset.Len()
-			lhsSymbol.firstset.AddSet(rhsSymbol.firstset)
-
-			if lhsSymbol.firstset.Len() != oldLen {
-				changed = true
-			}
-
-			if !rhsSymbol.IsNullable() {
-				break
-			}
-		}
-	}
-
-	return changed
-}
@@ -0,0 +1,109 @@
+package parse
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const labelGrammar = `%{
+package calc
+%}
+
+%token MINUS NUM
+%left PLUS
+%right UMINUS
+
+%%
+
+expr:
+	expr PLUS expr %name Add
+|	// Negation.
+	MINUS expr %prec UMINUS #Neg { $$ = -$2 }
+|	term
+;
+
+term: NUM #Add ;
+
+%%
+`
+
+func TestRuleLabels(t *testing.T) {
+	lemon := NewLemonFromBytes("calc.y", []byte(labelGrammar), "calc.go")
+	lemon.Parse()
+
+	cases := []struct {
+		lhs, label, expect string
+		index              int
+	}{
+		{"expr", "Add", "expr:expr PLUS expr.[PLUS] #Add", 0},
+		{"expr", "Neg", "expr:MINUS expr.[UMINUS] #Neg", 1},
+		{"term", "Add", "term:NUM. #Add", 3},
+	}
+
+	for _, c := range cases {
+		rule, ok := lemon.FindRule(c.lhs, c.label)
+
+		if !ok {
+			t.Errorf("Rule %s#%s not found", c.lhs, c.label)
+			continue
+		}
+
+		if rule.String() != c.expect || rule.Index() != c.index || rule.Label() != c.label {
+			t.Errorf("Expect %s (rule %d), actual %s (rule %d)", c.expect, c.index, rule, rule.Index())
+		}
+	}
+
+	if neg, _ := lemon.FindRule("expr", "Neg"); neg.code != "{ $$ = -$2 }" {
+		t.Errorf("Unexpected code after the label: %q", neg.code)
+	}
+
+	if _, ok := lemon.FindRule("expr", "Mul"); ok {
+		t.Errorf("Expect no rule labelled Mul")
+	}
+
+	var buf bytes.Buffer
+	lemon.WriteGo(&buf)
+
+	for _, expect := range []string{
+		"RuleExprAdd RuleID = 0 // expr ::= expr PLUS expr",
+		"\t// Negation.\n\tRuleExprNeg RuleID = 1",
+		"RuleTermAdd RuleID = 3",
+		`1: "expr#Neg",`,
+		`2: "expr ::= term",`,
+	} {
+		if !strings.Contains(buf.String(), expect) {
+			t.Errorf("Expect %q in generated code:\n%s", expect, buf.String())
+		}
+	}
+}
+
+// `%prec` ends the right hand side: a symbol after it is an error, which
+// exits, so the grammar is read by the test binary run again.
+func TestRuleAfterPrecedence(t *testing.T) {
+	if src := os.Getenv("GOLEMON_TEST_GRAMMAR"); src != "" {
+		NewLemonFromBytes("prec.y", []byte(src), "").ReadGrammar()
+
+		return
+	}
+
+	for _, c := range []struct {
+		src, expect string
+	}{
+		{"%left P\n%%\na: B %prec P C ;\n%%\n", "Find: `C`: prec.y:3:14"},
+		{"%left P\n%%\na: B %prec P { } #L C ;\n%%\n", "Find: `C`: prec.y:3:21"},
+		{"%left P\n%%\na: B %prec P #L { } | C %prec P ;\n%%\n", ""},
+	} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestRuleAfterPrecedence$")
+		cmd.Env = append(os.Environ(), "GOLEMON_TEST_GRAMMAR="+c.src)
+		out, err := cmd.CombinedOutput()
+
+		if c.expect == "" && err != nil {
+			t.Errorf("%q: unexpected error: %v\n%s", c.src, err, out)
+		} else if c.expect != "" && (err == nil || !strings.Contains(string(out), c.expect)) {
+			t.Errorf("%q: expect %q, actual %v\n%s", c.src, c.expect, err, out)
+		}
+	}
+}
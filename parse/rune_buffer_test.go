@@ -1,15 +1,214 @@
 package parse
 
 import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
+// The reader of the grammar files before Scanner, kept to compare with it.
+
+const EOF = -1
+
+// RuneBuffer reads runes and keeps track of their positions.
+// A leading byte order mark is skipped and `\r\n` is returned as a single `\n`.
+type RuneBuffer struct {
+	reader   *bufio.Reader // A pointer to buffer reader
+	peekRune rune          // Peek rune
+	hasPeek  bool          // True if peekRune is pending
+	peekPos  Position      // Position of peekRune
+	pos      Position      // Position of the rune last returned
+	prevPos  Position      // Position of the rune returned before that
+	next     Position      // Position of the next rune to read
+	onError  func(pos Position, msg string)
+}
+
+func NewRuneBuffer(rd io.Reader) *RuneBuffer {
+	start := Position{Line: 1, Column: 1}
+
+	return &RuneBuffer{
+		reader: bufio.NewReader(rd),
+		pos:    start,
+		next:   start,
+	}
+}
+
+// Set the function called on malformed input such as invalid UTF-8.
+// Without a handler such input is silently replaced by `utf8.RuneError`.
+func (runeBuf *RuneBuffer) SetErrorHandler(onError func(pos Position, msg string)) {
+	runeBuf.onError = onError
+}
+
+// Get the position of the rune last returned by GetRune.
+// After EOF is returned, this is the position just past the end of the input.
+func (runeBuf *RuneBuffer) Position() Position {
+	return runeBuf.pos
+}
+
+func (runeBuf *RuneBuffer) GetRune() rune {
+	runeBuf.prevPos = runeBuf.pos
+
+	if runeBuf.hasPeek {
+		runeBuf.hasPeek = false
+		runeBuf.pos = runeBuf.peekPos
+
+		return runeBuf.peekRune
+	}
+
+	runeBuf.pos = runeBuf.next
+	r, n, err := runeBuf.reader.ReadRune()
+
+	if n == 0 {
+		if err != nil && err != io.EOF {
+			runeBuf.errorf("Read error: %s", err.Error())
+		}
+
+		return EOF
+	}
+
+	if r == BOM && runeBuf.next.Offset == 0 {
+		runeBuf.next.Offset += n
+
+		return runeBuf.GetRune()
+	}
+
+	if r == utf8.RuneError && n == 1 {
+		runeBuf.errorf("Invalid UTF-8 encoding.")
+	}
+
+	if r == '\r' {
+		if b, err := runeBuf.reader.Peek(1); err == nil && b[0] == NewLine {
+			runeBuf.reader.ReadByte()
+			r = NewLine
+			n++
+		}
+	}
+
+	runeBuf.next.Offset += n
+
+	if r == NewLine {
+		runeBuf.next.Line++
+		runeBuf.next.Column = 1
+	} else {
+		runeBuf.next.Column++
+	}
+
+	return r
+}
+
+// Push back the rune last returned by GetRune. Only one rune can be pushed back.
+func (runeBuf *RuneBuffer) UngetRune(c rune) {
+	if runeBuf.hasPeek {
+		panic("UngetRune - 2nd unget")
+	}
+
+	runeBuf.peekRune = c
+	runeBuf.peekPos = runeBuf.pos
+	runeBuf.hasPeek = true
+	runeBuf.pos = runeBuf.prevPos
+}
+
+func (runeBuf *RuneBuffer) errorf(format string, args ...interface{}) {
+	if runeBuf.onError != nil {
+		runeBuf.onError(runeBuf.pos, fmt.Sprintf(format, args...))
+	}
+}
+
 type Expect struct {
-	data []rune
-	n    int
-	err  error
+	r   rune
+	pos Position
+}
+
+func readAll(runeBuf *RuneBuffer) []Expect {
+	var result []Expect
+
+	for r := runeBuf.GetRune(); r != EOF; r = runeBuf.GetRune() {
+		result = append(result, Expect{r, runeBuf.Position()})
+	}
+
+	return result
+}
+
+func checkRunes(t *testing.T, input string, expects []Expect) {
+	actual := readAll(NewRuneBuffer(strings.NewReader(input)))
+
+	if len(actual) != len(expects) {
+		t.Fatalf("Input %q: expect %d runes, actual %d: %v", input, len(expects), len(actual), actual)
+	}
+
+	for i, e := range expects {
+		if actual[i] != e {
+			t.Errorf("Input %q: rune %d, expect %q at %+v, actual %q at %+v", input, i, e.r, e.pos, actual[i].r, actual[i].pos)
+		}
+	}
 }
 
 func TestReadNRune(t *testing.T) {
+	checkRunes(t, "a\nβc", []Expect{
+		{'a', Position{1, 1, 0}},
+		{'\n', Position{1, 2, 1}},
+		{'β', Position{2, 1, 2}},
+		{'c', Position{2, 2, 4}},
+	})
+}
+
+func TestCRLF(t *testing.T) {
+	checkRunes(t, "a\r\nb\rc", []Expect{
+		{'a', Position{1, 1, 0}},
+		{'\n', Position{1, 2, 1}},
+		{'b', Position{2, 1, 3}},
+		{'\r', Position{2, 2, 4}},
+		{'c', Position{2, 3, 5}},
+	})
+}
+
+func TestBOM(t *testing.T) {
+	checkRunes(t, "\uFEFFa\uFEFF", []Expect{
+		{'a', Position{1, 1, 3}},
+		{BOM, Position{1, 2, 4}},
+	})
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	var errors []Position
+	runeBuf := NewRuneBuffer(strings.NewReader("a\n\xffb"))
+	runeBuf.SetErrorHandler(func(pos Position, msg string) {
+		errors = append(errors, pos)
+	})
+
+	if n := len(readAll(runeBuf)); n != 4 {
+		t.Errorf("Expect 4 runes, actual %d", n)
+	}
+
+	if len(errors) != 1 || errors[0] != (Position{2, 1, 2}) {
+		t.Errorf("Expect one error at 2:1, actual %v", errors)
+	}
+}
+
+func TestUngetRune(t *testing.T) {
+	runeBuf := NewRuneBuffer(strings.NewReader("ab\nc"))
+	runeBuf.GetRune()
+	r := runeBuf.GetRune()
+	runeBuf.UngetRune(r)
+
+	if pos := runeBuf.Position(); pos != (Position{1, 1, 0}) {
+		t.Errorf("Expect position to move back to 1:1, actual %+v", pos)
+	}
+
+	if r = runeBuf.GetRune(); r != 'b' || runeBuf.Position() != (Position{1, 2, 1}) {
+		t.Errorf("Expect `b` at 1:2, actual %q at %+v", r, runeBuf.Position())
+	}
+
+	runeBuf.GetRune()
+
+	if r = runeBuf.GetRune(); r != 'c' || runeBuf.Position() != (Position{2, 1, 3}) {
+		t.Errorf("Expect `c` at 2:1, actual %q at %+v", r, runeBuf.Position())
+	}
 
+	if r = runeBuf.GetRune(); r != EOF || runeBuf.Position() != (Position{2, 2, 4}) {
+		t.Errorf("Expect EOF at 2:2, actual %q at %+v", r, runeBuf.Position())
+	}
 }
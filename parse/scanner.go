@@ -0,0 +1,507 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/golemon/util"
+)
+
+const (
+	NewLine = '\n'
+	BOM     = '\uFEFF'
+)
+
+// Position of a rune inside the input file.
+type Position struct {
+	Line   int // Line number, starting at 1
+	Column int // Column number counted in runes, starting at 1
+	Offset int // Byte offset, starting at 0
+}
+
+// Return `line:column`, or only the line if the column is unknown.
+func (pos Position) String() string {
+	if pos.Column == 0 {
+		return fmt.Sprintf("%d", pos.Line)
+	}
+
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+}
+
+// Check the position points to somewhere in a file.
+func (pos Position) IsValid() bool {
+	return pos.Line > 0
+}
+
+type TokenKind int
+
+const (
+	TkEOF     TokenKind = iota
+	TkIdent             // expr, NUM
+	TkLiteral           // '+' or "+"
+	TkTag               // <num>
+	TkCode              // { ... } including the braces
+	TkPunct             // Any other single rune such as `%`, `:`, `|` or `;`
+	TkSpace             // Spaces and tabs, only if trivia is kept
+	TkNewline           // `\n` or `\r\n`, only if trivia is kept
+	TkComment           // `// ...` or `/* ... */`, only if trivia is kept
+)
+
+func (kind TokenKind) String() string {
+	switch kind {
+	case TkEOF:
+		return "EOF"
+	case TkIdent:
+		return "identifier"
+	case TkLiteral:
+		return "literal"
+	case TkTag:
+		return "tag"
+	case TkCode:
+		return "code"
+	case TkPunct:
+		return "punctuation"
+	case TkSpace:
+		return "space"
+	case TkNewline:
+		return "newline"
+	case TkComment:
+		return "comment"
+	default:
+		return "Not implemented"
+	}
+}
+
+// A token of the grammar file, located by byte offsets into the source.
+// The text of the token is never copied unless asked for.
+type Span struct {
+	Kind  TokenKind
+	Start int // Offset of the first byte
+	End   int // Offset just past the last byte
+}
+
+func (span Span) Len() int {
+	return span.End - span.Start
+}
+
+// Check the token is a space, a newline or a comment.
+func (span Span) IsTrivia() bool {
+	return span.Kind == TkSpace || span.Kind == TkNewline || span.Kind == TkComment
+}
+
+// Scanner splits an in-memory grammar file into tokens. Spaces and comments
+// are skipped unless KeepTrivia is called. Any number of tokens can be looked
+// ahead with Peek.
+//
+// A leading byte order mark is skipped, `\r\n` counts as a single newline
+// and invalid UTF-8 is reported as a positioned error.
+type Scanner struct {
+	src       []byte // The grammar file
+	off       int    // Offset of the next byte to scan
+	ahead     []Span // Tokens scanned but not consumed yet
+	head      int    // Index of the next token inside ahead
+	lastEnd   int    // End of the token last returned by Next
+	lastStart int    // Start of the token last returned by Next
+	gapEnd    int    // End of the token before the one last returned by Next
+	lines     []int  // Offsets of the start of each line
+	checked   bool   // True if the encoding has been verified
+	trivia    bool   // True if spaces and comments are returned as tokens
+	onError   func(pos Position, msg string)
+}
+
+func NewScanner(src []byte) *Scanner {
+	start := 0
+
+	if r, n := utf8.DecodeRune(src); r == BOM {
+		start = n
+	}
+
+	lines := make([]int, 1, bytes.Count(src, []byte{NewLine})+1)
+	lines[0] = start
+
+	for i := start; ; {
+		n := bytes.IndexByte(src[i:], NewLine)
+
+		if n < 0 {
+			break
+		}
+
+		i += n + 1
+		lines = append(lines, i)
+	}
+
+	return &Scanner{
+		src:     src,
+		off:     start,
+		lastEnd: start,
+		lines:   lines,
+	}
+}
+
+// Set the function called on malformed input such as an unterminated
+// string or invalid UTF-8.
+func (sc *Scanner) SetErrorHandler(onError func(pos Position, msg string)) {
+	sc.onError = onError
+}
+
+// Return spaces, newlines and comments as tokens too, so that the tokens
+// cover every byte of the source. A leading byte order mark is returned as
+// a space. Must be called before the first token is scanned.
+func (sc *Scanner) KeepTrivia() {
+	sc.trivia = true
+	sc.off = 0
+	sc.lastEnd = 0
+}
+
+// Consume and return the next token.
+func (sc *Scanner) Next() Span {
+	span := sc.Peek(0)
+
+	if span.Kind != TkEOF {
+		sc.head++
+	}
+
+	if sc.head == len(sc.ahead) {
+		sc.ahead = sc.ahead[:0]
+		sc.head = 0
+	}
+
+	sc.gapEnd = sc.lastEnd
+	sc.lastStart = span.Start
+	sc.lastEnd = span.End
+
+	return span
+}
+
+// Get the doc comment of the token last returned by Next. This is a
+// `/** ... */` comment or a block of `//` comments on the lines right
+// before the token. A comment following `:` or `|` on the same line
+// documents the alternative after it, so it counts too. The comment markers
+// are removed.
+func (sc *Scanner) Doc() string {
+	start := sc.gapEnd
+
+	if start >= sc.lastStart {
+		return ""
+	}
+
+	lineStart := start == sc.lines[0] || sc.src[start-1] == ':' || sc.src[start-1] == '|'
+
+	return docComment(sc.src[start:sc.lastStart], lineStart)
+}
+
+// Return the token `n` positions ahead without consuming anything.
+// Peek(0) is the token the next call to Next returns.
+func (sc *Scanner) Peek(n int) Span {
+	if !sc.checked {
+		sc.checkEncoding()
+	}
+
+	for len(sc.ahead)-sc.head <= n {
+		if last := len(sc.ahead) - 1; last >= sc.head && sc.ahead[last].Kind == TkEOF {
+			return sc.ahead[last]
+		}
+
+		sc.ahead = append(sc.ahead, sc.scan())
+	}
+
+	return sc.ahead[sc.head+n]
+}
+
+// Get the text of a token. The result shares memory with the source.
+func (sc *Scanner) Bytes(span Span) []byte {
+	return sc.src[span.Start:span.End]
+}
+
+// Get the text of a token as a string. Unlike Bytes this makes a copy.
+func (sc *Scanner) Text(span Span) string {
+	return string(sc.src[span.Start:span.End])
+}
+
+// Get everything after the token last returned by Next, such as the code
+// following the second `%%`.
+func (sc *Scanner) Rest() []byte {
+	return sc.src[sc.lastEnd:]
+}
+
+// Get the whole source.
+func (sc *Scanner) Source() []byte {
+	return sc.src
+}
+
+// Convert a byte offset into a position with line and column.
+func (sc *Scanner) Position(offset int) Position {
+	line := sort.Search(len(sc.lines), func(i int) bool { return sc.lines[i] > offset }) - 1
+
+	if line < 0 {
+		line = 0
+	}
+
+	start := sc.lines[line]
+	column := 1
+
+	if offset > start {
+		column += utf8.RuneCount(sc.src[start:util.Min(offset, len(sc.src))])
+	}
+
+	return Position{Line: line + 1, Column: column, Offset: offset}
+}
+
+func (sc *Scanner) errorf(offset int, msg string) {
+	if sc.onError != nil {
+		sc.onError(sc.Position(offset), msg)
+	}
+}
+
+// Report every invalid UTF-8 sequence once.
+func (sc *Scanner) checkEncoding() {
+	sc.checked = true
+
+	if utf8.Valid(sc.src) {
+		return
+	}
+
+	for i := 0; i < len(sc.src); {
+		r, n := utf8.DecodeRune(sc.src[i:])
+
+		if r == utf8.RuneError && n == 1 {
+			sc.errorf(i, "Invalid UTF-8 encoding.")
+		}
+
+		i += n
+	}
+}
+
+// Scan a single token starting at or after sc.off.
+func (sc *Scanner) scan() Span {
+	if !sc.trivia {
+		sc.skipTrivia()
+	}
+
+	src := sc.src
+	start := sc.off
+
+	if start >= len(src) {
+		return Span{TkEOF, start, start}
+	}
+
+	if sc.trivia {
+		if end, kind := sc.scanTrivia(start); end > start {
+			sc.off = end
+
+			return Span{kind, start, end}
+		}
+	}
+
+	kind := TkPunct
+
+	switch c := src[start]; c {
+	case '\'', '"':
+		kind = TkLiteral
+		sc.off = sc.skipQuoted(start+1, c, true)
+
+		if sc.off > len(src) {
+			sc.off = len(src)
+			sc.errorf(start, "String starting here is not terminated before the end of the file.")
+		}
+
+	case '<':
+		kind = TkTag
+		n := bytes.IndexByte(src[start:], '>')
+
+		if n < 0 {
+			sc.off = len(src)
+			sc.errorf(start, "Type specifier `<type>` starting here is not terminated before the end of the file.")
+		} else {
+			sc.off = start + n + 1
+		}
+
+	case '{':
+		kind = TkCode
+		sc.off = sc.skipCode(start)
+
+	default:
+		r, n := utf8.DecodeRune(src[start:])
+		sc.off = start + n
+
+		if util.IsAlphaNum(r) {
+			kind = TkIdent
+
+			// Keywords such as `%expect-rr` may contain dashes.
+			keyword := start > 0 && src[start-1] == '%'
+
+			for sc.off < len(src) {
+				r, n = utf8.DecodeRune(src[sc.off:])
+
+				if r == '-' && keyword && sc.off+1 < len(src) && util.IsAlphaNum(rune(src[sc.off+1])) {
+					sc.off += n
+					continue
+				}
+
+				if !util.IsAlphaNum(r) && r != '_' {
+					break
+				}
+
+				sc.off += n
+			}
+		}
+	}
+
+	return Span{kind, start, sc.off}
+}
+
+// Skip spaces and comments.
+func (sc *Scanner) skipTrivia() {
+	src := sc.src
+
+	for sc.off < len(src) {
+		c := src[sc.off]
+
+		if util.IsSpace(rune(c)) {
+			sc.off++
+		} else if c == '/' {
+			end, ok := sc.skipComment(sc.off)
+
+			if !ok {
+				return
+			}
+
+			sc.off = end
+		} else {
+			return
+		}
+	}
+}
+
+// Find the end of the space, newline or comment at `start`.
+// If there is none, `start` is returned.
+func (sc *Scanner) scanTrivia(start int) (int, TokenKind) {
+	src := sc.src
+
+	if start == 0 && sc.lines[0] > 0 {
+		return sc.lines[0], TkSpace
+	}
+
+	switch c := src[start]; {
+	case c == NewLine:
+		return start + 1, TkNewline
+
+	case c == '\r' && start+1 < len(src) && src[start+1] == NewLine:
+		return start + 2, TkNewline
+
+	case c == '/':
+		end, _ := sc.skipComment(start)
+
+		return end, TkComment
+	}
+
+	end := start
+
+	for end < len(src) && util.IsSpace(rune(src[end])) && src[end] != NewLine {
+		if src[end] == '\r' && end+1 < len(src) && src[end+1] == NewLine {
+			break
+		}
+
+		end++
+	}
+
+	return end, TkSpace
+}
+
+// Skip the comment at `start`. If there is no comment, return false.
+// A line comment ends before the newline.
+// An unterminated comment is reported and skipped up to the end of file.
+func (sc *Scanner) skipComment(start int) (int, bool) {
+	src := sc.src
+
+	if start+1 >= len(src) {
+		return start, false
+	}
+
+	switch src[start+1] {
+	case '/':
+		end := len(src)
+
+		if n := bytes.IndexByte(src[start:], NewLine); n >= 0 {
+			end = start + n
+		}
+
+		if end > start+2 && src[end-1] == '\r' {
+			end--
+		}
+
+		return end, true
+
+	case '*':
+		if n := bytes.Index(src[start+2:], []byte("*/")); n >= 0 {
+			return start + 2 + n + 2, true
+		}
+
+		sc.errorf(start, "EOF inside comment.")
+
+		return len(src), true
+	}
+
+	return start, false
+}
+
+// Skip a quoted string or character whose opening quote is before `i`.
+// Return the offset after the closing quote, or past the end of the
+// source if the string is not terminated.
+func (sc *Scanner) skipQuoted(i int, quote byte, escape bool) int {
+	src := sc.src
+
+	for ; i < len(src); i++ {
+		if src[i] == quote {
+			return i + 1
+		}
+
+		if escape && src[i] == '\\' {
+			i++
+		}
+	}
+
+	return len(src) + 1
+}
+
+// Skip the code block starting with the `{` at `start`. Braces inside
+// strings, characters and comments are not counted.
+func (sc *Scanner) skipCode(start int) int {
+	src := sc.src
+	level := 0
+
+	for i := start; i < len(src); {
+		switch c := src[i]; c {
+		case '{':
+			level++
+			i++
+
+		case '}':
+			level--
+			i++
+
+			if level == 0 {
+				return i
+			}
+
+		case '\'', '"', '`':
+			i = sc.skipQuoted(i+1, c, c != '`')
+
+		case '/':
+			if end, ok := sc.skipComment(i); ok {
+				i = end
+			} else {
+				i++
+			}
+
+		default:
+			i++
+		}
+	}
+
+	sc.errorf(start, "Code starting here is not terminated before the end of the file.")
+
+	return len(src)
+}
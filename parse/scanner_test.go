@@ -0,0 +1,267 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/golemon/util"
+)
+
+func scanAll(sc *Scanner) []Span {
+	var spans []Span
+
+	for span := sc.Next(); span.Kind != TkEOF; span = sc.Next() {
+		spans = append(spans, span)
+	}
+
+	return spans
+}
+
+func TestScanTokens(t *testing.T) {
+	src := []byte(`%token <num> NUM '+'
+%%
+// comment
+expr: expr '+' expr { $$ = "}" + '}' /* } */ } /* c */ | NUM ;
+`)
+	expects := []struct {
+		kind TokenKind
+		text string
+	}{
+		{TkPunct, "%"}, {TkIdent, "token"}, {TkTag, "<num>"}, {TkIdent, "NUM"}, {TkLiteral, "'+'"},
+		{TkPunct, "%"}, {TkPunct, "%"},
+		{TkIdent, "expr"}, {TkPunct, ":"}, {TkIdent, "expr"}, {TkLiteral, "'+'"}, {TkIdent, "expr"},
+		{TkCode, `{ $$ = "}" + '}' /* } */ }`}, {TkPunct, "|"}, {TkIdent, "NUM"}, {TkPunct, ";"},
+	}
+
+	sc := NewScanner(src)
+	spans := scanAll(sc)
+
+	if len(spans) != len(expects) {
+		t.Fatalf("Expect %d tokens, actual %d", len(expects), len(spans))
+	}
+
+	for i, e := range expects {
+		if spans[i].Kind != e.kind || sc.Text(spans[i]) != e.text {
+			t.Errorf("Token %d: expect %v `%s`, actual %v `%s`", i, e.kind, e.text, spans[i].Kind, sc.Text(spans[i]))
+		}
+	}
+}
+
+// Only keywords may contain dashes.
+func TestScanKeywordDash(t *testing.T) {
+	sc := NewScanner([]byte("%expect-rr 2 a-b %name- x"))
+	var texts []string
+
+	for _, span := range scanAll(sc) {
+		texts = append(texts, sc.Text(span))
+	}
+
+	expect := "[% expect-rr 2 a - b % name - x]"
+
+	if actual := fmt.Sprint(texts); actual != expect {
+		t.Errorf("Expect %s, actual %s", expect, actual)
+	}
+}
+
+func TestScannerPeek(t *testing.T) {
+	sc := NewScanner([]byte("a b c"))
+
+	if text := sc.Text(sc.Peek(2)); text != "c" {
+		t.Errorf("Expect `c`, actual `%s`", text)
+	}
+
+	if sc.Peek(5).Kind != TkEOF {
+		t.Errorf("Expect EOF after the last token")
+	}
+
+	for _, expect := range []string{"a", "b", "c", ""} {
+		if text := sc.Text(sc.Next()); text != expect {
+			t.Errorf("Expect `%s`, actual `%s`", expect, text)
+		}
+	}
+
+	if sc.Next().Kind != TkEOF {
+		t.Errorf("Expect EOF to repeat")
+	}
+}
+
+func TestScannerRest(t *testing.T) {
+	sc := NewScanner([]byte("a: b;\n%%\nfunc main() {}\n"))
+
+	for sc.Text(sc.Next()) != "%" || sc.Text(sc.Next()) != "%" {
+	}
+
+	if rest := string(sc.Rest()); rest != "\nfunc main() {}\n" {
+		t.Errorf("Unexpected rest: %q", rest)
+	}
+}
+
+func TestScannerPosition(t *testing.T) {
+	sc := NewScanner([]byte("\uFEFFa\r\n  βb\nc"))
+	expects := []Position{{1, 1, 3}, {2, 3, 8}, {3, 1, 12}}
+
+	for i, span := range scanAll(sc) {
+		if pos := sc.Position(span.Start); pos != expects[i] {
+			t.Errorf("Token %d: expect %+v, actual %+v", i, expects[i], pos)
+		}
+	}
+}
+
+func TestScannerErrors(t *testing.T) {
+	cases := map[string]Position{
+		"a /* b":      {1, 3, 2},
+		"a\n { b":     {2, 2, 3},
+		"a 'b":        {1, 3, 2},
+		"a <b":        {1, 3, 2},
+		"a\n\xff b":   {2, 1, 2},
+		"{ \"}\" ` }": {1, 1, 0},
+	}
+
+	for src, expect := range cases {
+		var errors []Position
+		sc := NewScanner([]byte(src))
+		sc.SetErrorHandler(func(pos Position, msg string) {
+			errors = append(errors, pos)
+		})
+		scanAll(sc)
+
+		if len(errors) != 1 || errors[0] != expect {
+			t.Errorf("Input %q: expect one error at %+v, actual %v", src, expect, errors)
+		}
+	}
+}
+
+// A large grammar made of copies of the rules in expr.y.
+func largeGrammar(copies int) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("%{\npackage main\n%}\n\n%token '+' '-' '*' '/' '(' ')'\n%token <num> NUM\n\n%%\n\n")
+
+	for i := 0; i < copies; i++ {
+		fmt.Fprintf(&buf, `// Copy %[1]d.
+expr%[1]d:
+	expr1_%[1]d
+|	'+' expr%[1]d
+	{
+		$$ = $2
+	}
+|	'-' expr%[1]d
+	{
+		$$ = $2.Neg($2)
+	}
+;
+
+expr1_%[1]d:
+	NUM
+|	expr1_%[1]d '+' NUM
+	{
+		$$ = $1.Add($1, $3)
+	}
+;
+
+`, i)
+	}
+
+	return buf.Bytes()
+}
+
+// Keep the benchmarks from optimizing the token text away.
+var (
+	textSink  string
+	bytesSink []byte
+)
+
+// Split the source into tokens the way Lemon.Parse did before Scanner.
+func tokenizeWithRuneBuffer(src []byte) int {
+	count := 0
+	runeBuf := NewRuneBuffer(bytes.NewReader(src))
+	token := NewToken()
+
+	for r := runeBuf.GetRune(); r != EOF; r = runeBuf.GetRune() {
+		if util.IsSpace(r) {
+			continue
+		}
+
+		if r == '/' {
+			runeBuf.GetRune()
+			for r = runeBuf.GetRune(); r != EOF && r != NewLine; r = runeBuf.GetRune() {
+			}
+			continue
+		}
+
+		token.AppendRune(r)
+
+		if r == '{' {
+			level := 1
+			for r = runeBuf.GetRune(); r != EOF && (level > 1 || r != '}'); r = runeBuf.GetRune() {
+				token.AppendRune(r)
+
+				if r == '}' {
+					level--
+				} else if r == '{' {
+					level++
+				}
+			}
+			token.AppendRune(r)
+		} else if r == '\'' || r == '<' {
+			end := r
+
+			if r == '<' {
+				end = '>'
+			}
+
+			for r = runeBuf.GetRune(); r != EOF && r != end; r = runeBuf.GetRune() {
+				token.AppendRune(r)
+			}
+			token.AppendRune(r)
+		} else if util.IsAlphaNum(r) {
+			for r = runeBuf.GetRune(); r != EOF && (util.IsAlphaNum(r) || r == '_'); r = runeBuf.GetRune() {
+				token.AppendRune(r)
+			}
+
+			if r != EOF {
+				runeBuf.UngetRune(r)
+			}
+		}
+
+		textSink = token.String()
+		count++
+
+		token.Reset()
+	}
+
+	return count
+}
+
+func TestLargeGrammarTokenCount(t *testing.T) {
+	src := largeGrammar(10)
+
+	if expect, actual := tokenizeWithRuneBuffer(src), len(scanAll(NewScanner(src))); expect != actual {
+		t.Errorf("Expect %d tokens, actual %d", expect, actual)
+	}
+}
+
+func BenchmarkScanner(b *testing.B) {
+	src := largeGrammar(1000)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		sc := NewScanner(src)
+
+		for span := sc.Next(); span.Kind != TkEOF; span = sc.Next() {
+			bytesSink = sc.Bytes(span)
+		}
+	}
+}
+
+func BenchmarkRuneBufferToken(b *testing.B) {
+	src := largeGrammar(1000)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		tokenizeWithRuneBuffer(src)
+	}
+}
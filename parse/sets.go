@@ -0,0 +1,237 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golemon/util"
+)
+
+// For each terminal t
+//
+//	Nullable(t) = false
+//
+// For each non-terminal N
+//
+//	Nullable(N) = is there a production N ::= ε(epsilon)
+//
+// Repeat
+//
+//	For each production N ::= x1x2x3...xn
+//	  If Nullable(xi) for all of xi then set Nullable(N) to true
+//
+// Until nothing new becomes Nullable
+func (lemon *Lemon) computeNullable() {
+	for changed := true; changed; {
+		changed = false
+
+		for rule := lemon.firstRule; rule != nil; rule = rule.next {
+			if !rule.lhs.nullable && allNullable(rule.rhs[:rule.nrhs]) {
+				rule.lhs.nullable = true
+				changed = true
+			}
+		}
+	}
+}
+
+// Add FIRST(symbols) to `set`, return true if it has changed. FIRST(t) of
+// a terminal is {t}, so the sets must be initialized before.
+func addFirst(set util.IntSet, symbols []*Symbol) bool {
+	oldLen := set.Len()
+
+	for _, symbol := range symbols {
+		set.AddSet(symbol.firstset)
+
+		if !symbol.nullable {
+			break
+		}
+	}
+
+	return set.Len() != oldLen
+}
+
+// For each production N ::= x1x2x3...xn, FIRST(N) includes FIRST(xi) for
+// each xi such that x1...x(i-1) are all nullable. Repeat until nothing
+// changes, so the result doesn't depend on the order of the rules, and
+// left recursion, nullable or not, is handled like any other symbol.
+func (lemon *Lemon) computeFirstSets() {
+	for _, symbol := range lemon.symTable.SortedSymbols() {
+		symbol.firstset = make(util.IntSet)
+
+		if symbol.IsTerminal() {
+			symbol.firstset.Add(symbol.index)
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for rule := lemon.firstRule; rule != nil; rule = rule.next {
+			if addFirst(rule.lhs.firstset, rule.rhs[:rule.nrhs]) {
+				changed = true
+			}
+		}
+	}
+}
+
+// FOLLOW(S) of the start symbol includes the end of input. For each
+// production A ::= αBβ, FOLLOW(B) includes FIRST(β), and also FOLLOW(A)
+// if β is nullable. Repeat until nothing changes.
+func (lemon *Lemon) computeFollowSets() {
+	for _, symbol := range lemon.symTable.SortedSymbols() {
+		symbol.followset = make(util.IntSet)
+	}
+
+	if start := lemon.startSymbol(); start != nil && lemon.endSym != nil {
+		start.followset.Add(lemon.endSym.index)
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for rule := lemon.firstRule; rule != nil; rule = rule.next {
+			for i, symbol := range rule.rhs[:rule.nrhs] {
+				if symbol.IsTerminal() {
+					continue
+				}
+
+				rest := rule.rhs[i+1 : rule.nrhs]
+				oldLen := symbol.followset.Len()
+				addFirst(symbol.followset, rest)
+
+				if allNullable(rest) {
+					symbol.followset.AddSet(rule.lhs.followset)
+				}
+
+				if symbol.followset.Len() != oldLen {
+					changed = true
+				}
+			}
+		}
+	}
+}
+
+// Compute the nullable, FIRST and FOLLOW sets, once the grammar is read.
+func (lemon *Lemon) computeSets() {
+	if lemon.setsDone {
+		return
+	}
+
+	// Fix the indexes the sets are made of.
+	lemon.symTable.SortedSymbols()
+
+	lemon.computeNullable()
+	lemon.computeFirstSets()
+	lemon.computeFollowSets()
+	lemon.setsDone = true
+}
+
+// Get the symbols of an index set, in index order.
+func (lemon *Lemon) symbolsOf(set util.IntSet) []*Symbol {
+	sortedSymbols := lemon.symTable.SortedSymbols()
+	symbols := make([]*Symbol, 0, set.Len())
+
+	for _, symbol := range sortedSymbols {
+		if set.Includes(symbol.index) {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	return symbols
+}
+
+// Get a symbol of the grammar by name.
+func (lemon *Lemon) Symbol(name string) (*Symbol, bool) {
+	return lemon.symTable.Get(name)
+}
+
+func allNullable(symbols []*Symbol) bool {
+	for _, symbol := range symbols {
+		if !symbol.nullable {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Find the rule of the shortest derivation of each non-terminal, and the
+// length of the string it derives, the shallowest among the shortest
+// ones. A nullable non-terminal derives the empty string. The length of
+// a terminal is 1.
+func (lemon *Lemon) shortestRules() (map[*Symbol]*Rule, map[*Symbol]int) {
+	rules := make(map[*Symbol]*Rule)
+	lengths := make(map[*Symbol]int)
+	depths := make(map[*Symbol]int)
+
+	for _, symbol := range lemon.symTable.SortedSymbols() {
+		if symbol.IsTerminal() {
+			lengths[symbol] = 1
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for rp := lemon.firstRule; rp != nil; rp = rp.next {
+			length, depth, ok := 0, 1, true
+
+			for _, symbol := range rp.rhs[:rp.nrhs] {
+				n, found := lengths[symbol]
+				length, depth, ok = length+n, util.Max(depth, depths[symbol]+1), ok && found
+			}
+
+			oldLength, found := lengths[rp.lhs]
+
+			if ok && (!found || length < oldLength || length == oldLength && depth < depths[rp.lhs]) {
+				rules[rp.lhs], lengths[rp.lhs], depths[rp.lhs] = rp, length, depth
+				changed = true
+			}
+		}
+	}
+
+	return rules, lengths
+}
+
+// Check the sequence of symbols can derive the empty string. An empty
+// sequence is nullable.
+func (lemon *Lemon) Nullable(symbols ...*Symbol) bool {
+	lemon.computeSets()
+
+	return allNullable(symbols)
+}
+
+// Get the terminals which can start a string derived from the sequence
+// of symbols, in index order. Whether it can be empty is told by Nullable.
+func (lemon *Lemon) First(symbols ...*Symbol) []*Symbol {
+	lemon.computeSets()
+	set := make(util.IntSet)
+	addFirst(set, symbols)
+
+	return lemon.symbolsOf(set)
+}
+
+// Get the terminals which can follow the non-terminal in a sentential
+// form, in index order. The end of input `$` follows the start symbol.
+func (lemon *Lemon) Follow(symbol *Symbol) []*Symbol {
+	lemon.computeSets()
+
+	return lemon.symbolsOf(symbol.followset)
+}
+
+// Print the first sets.
+func (lemon *Lemon) PrintFirstSets() {
+	lemon.computeSets()
+	lemon.symTable.PrintFirstSets()
+}
+
+// Print the follow sets.
+func (lemon *Lemon) PrintFollowSets() {
+	lemon.computeSets()
+
+	for _, symbol := range lemon.symTable.SortedSymbols() {
+		if symbol.IsNonTerminal() {
+			fmt.Printf("%s => { %s }\n", symbol.name, strings.Join(lemon.symTable.names(symbol.followset), ", "))
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package parse
+
+import (
+	"testing"
+)
+
+func symbolNames(symbols []*Symbol) []string {
+	names := make([]string, len(symbols))
+
+	for i, symbol := range symbols {
+		names[i] = symbol.name
+	}
+
+	return names
+}
+
+func readGrammar(t *testing.T, name string, src string) *Lemon {
+	lemon := NewLemonFromBytes(name, []byte(src), "")
+	lemon.read()
+	lemon.removeUselessRules()
+
+	for _, finding := range lemon.Findings() {
+		t.Errorf("%s: unexpected finding: %s", name, finding.Message)
+	}
+
+	return lemon
+}
+
+func mustSymbol(t *testing.T, lemon *Lemon, name string) *Symbol {
+	symbol, ok := lemon.Symbol(name)
+
+	if !ok {
+		t.Fatalf("Symbol %s not found", name)
+	}
+
+	return symbol
+}
+
+type setCase struct {
+	name     string
+	nullable bool
+	first    []string
+	follow   []string
+}
+
+func checkSets(t *testing.T, lemon *Lemon, cases []setCase) {
+	for _, c := range cases {
+		symbol := mustSymbol(t, lemon, c.name)
+
+		if nullable := lemon.Nullable(symbol); nullable != c.nullable {
+			t.Errorf("%s: expect nullable %v, actual %v", c.name, c.nullable, nullable)
+		}
+
+		checkStrings(t, "FIRST("+c.name+")", c.first, symbolNames(lemon.First(symbol)))
+		checkStrings(t, "FOLLOW("+c.name+")", c.follow, symbolNames(lemon.Follow(symbol)))
+	}
+}
+
+func TestSetsExprFirstSet(t *testing.T) {
+	lemon := NewLemon("../example/expr_firstset.y", "")
+	lemon.read()
+	lemon.removeUselessRules()
+
+	digits := []string{"'0'", "'1'", "'2'", "'3'", "'4'", "'5'", "'6'", "'7'", "'8'", "'9'"}
+	all := append([]string{"'('"}, digits...)
+
+	checkSets(t, lemon, []setCase{
+		{"expression", false, all, []string{"$", "')'"}},
+		{"term", false, all, []string{"$", "')'", "'+'"}},
+		{"factor", false, all, []string{"$", "')'", "'*'", "'+'"}},
+		{"digit", false, digits, []string{"$", "')'", "'*'", "'+'"}},
+	})
+
+	term := mustSymbol(t, lemon, "term")
+	plus := mustSymbol(t, lemon, "'+'")
+	checkStrings(t, "FIRST('+' term)", []string{"'+'"}, symbolNames(lemon.First(plus, term)))
+}
+
+func TestSetsLeftRecursion(t *testing.T) {
+	lemon := readGrammar(t, "left.y", `%{
+%}
+%token ID
+%%
+list: list ',' item | item ;
+item: item '.' | prefix ID ;
+prefix: prefix '@' | ;
+%%
+`)
+
+	checkSets(t, lemon, []setCase{
+		{"list", false, []string{"'@'", "ID"}, []string{"$", "','"}},
+		{"item", false, []string{"'@'", "ID"}, []string{"$", "','", "'.'"}},
+		{"prefix", true, []string{"'@'"}, []string{"'@'", "ID"}},
+	})
+
+	prefix := mustSymbol(t, lemon, "prefix")
+
+	if !lemon.Nullable() || !lemon.Nullable(prefix, prefix) {
+		t.Errorf("Expect empty and nullable sequences to be nullable")
+	}
+
+	id := mustSymbol(t, lemon, "ID")
+	checkStrings(t, "FIRST(prefix prefix ID)", []string{"'@'", "ID"}, symbolNames(lemon.First(prefix, prefix, id)))
+}
+
+// FIRST sets must not depend on the order of the rules.
+func TestSetsRuleOrder(t *testing.T) {
+	lemon := readGrammar(t, "order.y", `%{
+%}
+%%
+s: a 'y' ;
+a: b | ;
+b: c ;
+c: 'x' ;
+%%
+`)
+
+	checkSets(t, lemon, []setCase{
+		{"s", false, []string{"'x'", "'y'"}, []string{"$"}},
+		{"a", true, []string{"'x'"}, []string{"'y'"}},
+		{"c", false, []string{"'x'"}, []string{"'y'"}},
+	})
+}
@@ -0,0 +1,101 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golemon/util"
+)
+
+// A conflict of a SLR(1) state which the LALR(1) state doesn't have: the
+// FOLLOW sets used by SLR(1) are too coarse for the lookahead.
+type SLRConflict struct {
+	State     *State    // The SLR(1) state, the LALR(1) state has the same index
+	Lookahead *Symbol   // The lookahead with more than one action
+	Configs   []*Config // The completed configurations reduced on it by SLR(1) only
+}
+
+// Set the follow sets of the completed configurations of the LR(0) states
+// to the FOLLOW set of their left hand side, which makes SLR(1) states.
+// The accept rule is completed on `$` only.
+func (lemon *Lemon) findSLRFollowSets() {
+	for _, stp := range lemon.sortedState {
+		for _, cfp := range stp.cfp {
+			if cfp.dot < cfp.rp.nrhs {
+				continue
+			}
+
+			if cfp.rp == lemon.acceptRule {
+				cfp.fws.Add(lemon.endSym.index)
+			} else {
+				cfp.fws.AddInts(cfp.rp.lhs.followset)
+			}
+		}
+	}
+}
+
+// Get the lookaheads on which the state has more than one action, before
+// any conflict is resolved.
+func conflictingLookaheads(stp *State, nsymbol int) util.BitSet {
+	seen := util.NewBitSet(nsymbol)
+	conflicts := util.NewBitSet(nsymbol)
+
+	for _, ap := range stp.ap {
+		if ap.sp.IsTerminal() {
+			seen.Add(ap.sp.index)
+		}
+	}
+
+	for _, cfp := range stp.cfp {
+		if cfp.dot == cfp.rp.nrhs {
+			conflicts.AddSet(seen.Intersect(cfp.fws))
+			seen.AddSet(cfp.fws)
+		}
+	}
+
+	return conflicts
+}
+
+// Find the conflicts of the SLR(1) states which the LALR(1) states don't
+// have. Both have the same LR(0) states, in the same order.
+func (lemon *Lemon) findSLRConflicts(lalrStates []*State) {
+	lemon.slrConflicts = nil
+	symbols := lemon.symTable.SortedSymbols()
+
+	for i, stp := range lemon.sortedState {
+		lalr := lalrStates[i]
+		lalrConflicts := conflictingLookaheads(lalr, lemon.nsymbol)
+
+		for _, index := range conflictingLookaheads(stp, lemon.nsymbol).AsSlice() {
+			if lalrConflicts.Includes(index) {
+				continue
+			}
+
+			conflict := SLRConflict{State: stp, Lookahead: symbols[index]}
+
+			for j, cfp := range stp.cfp {
+				if cfp.dot == cfp.rp.nrhs && cfp.fws.Includes(index) && !lalr.cfp[j].fws.Includes(index) {
+					conflict.Configs = append(conflict.Configs, cfp)
+				}
+			}
+
+			lemon.slrConflicts = append(lemon.slrConflicts, conflict)
+		}
+	}
+}
+
+// Get the conflicts of the SLR(1) states which the LALR(1) states don't
+// have. They are empty in the other modes.
+func (lemon *Lemon) SLRConflicts() []SLRConflict {
+	return lemon.slrConflicts
+}
+
+func (conflict SLRConflict) String() string {
+	reasons := make([]string, len(conflict.Configs))
+
+	for i, cfp := range conflict.Configs {
+		reasons[i] = fmt.Sprintf("%s is in FOLLOW(%s), but not in the LALR(1) lookaheads of `%v`", conflict.Lookahead.name, cfp.rp.lhs.name, cfp)
+	}
+
+	return fmt.Sprintf("State %d has a conflict on %s with SLR(1) only: %s", conflict.State.index, conflict.Lookahead.name, strings.Join(reasons, "; "))
+}
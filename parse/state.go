@@ -1,15 +1,132 @@
 package parse
 
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
 // Each state of the genrated parser's finite state machine
 // is encoded as an instance of the following structure
 type State struct {
-	bp         *Config  // The basis configuration for this state
-	cfp        *Config  // ALl configurations in this set
-	index      int      // Sequencial number for this satte
-	ap         []Action // Array of actions for this state
-	nTknAct    int      // Number of actions on terminals
-	nNtAct     int      // Number of actions on nonterminals
-	iTknOffset int      // yy_action[] offset for terminals
-	iNtOfst    int      // yy_action[] offset for nonterminals
-	iDefAction int      // Default action
+	bp         []*Config            // The basis configurations for this state, sorted by rule and dot
+	cfp        []*Config            // ALl configurations in this set, sorted by rule and dot
+	index      int                  // Sequencial number for this satte
+	ap         []Action             // Array of actions for this state
+	nTknAct    int                  // Number of actions on terminals
+	nNtAct     int                  // Number of actions on nonterminals
+	iTknOffset int                  // yy_action[] offset for terminals
+	iNtOfst    int                  // yy_action[] offset for nonterminals
+	iDefAction int                  // Default action
+	decisions  []*LookaheadDecision // Conflicts decided by more tokens of lookahead
+}
+
+// Get the sequential number of the state. The initial state is 0.
+func (stp *State) Index() int {
+	return stp.index
+}
+
+// Get the basis configurations, the ones the state is identified by.
+func (stp *State) Basis() []*Config {
+	return stp.bp
+}
+
+// Get all the configurations of the state, the closure of the basis.
+func (stp *State) Configs() []*Config {
+	return stp.cfp
+}
+
+// Get the actions of the state.
+func (stp *State) Actions() []Action {
+	return stp.ap
+}
+
+// Get the state reached by a shift or a goto on the symbol, or nil. This
+// is the transition of the automaton, even if a conflict resolution
+// drops the shift.
+func (stp *State) Goto(symbol *Symbol) *State {
+	for i := range stp.ap {
+		if ap := &stp.ap[i]; ap.sp == symbol && ap.stp != nil {
+			return ap.stp
+		}
+	}
+
+	return nil
+}
+
+// Get a description of the state as in the report of lemon: the
+// configurations, then the actions.
+func (stp *State) String() string {
+	var buf strings.Builder
+	stp.write(&buf, nil)
+
+	return buf.String()
+}
+
+// Write the description of the state. If the symbols are given by index,
+// the lookaheads of the completed configurations are written too.
+func (stp *State) write(w io.Writer, symbols []*Symbol) {
+	fmt.Fprintf(w, "State %d:\n", stp.index)
+
+	for _, cfp := range stp.cfp {
+		if symbols == nil || cfp.dot < cfp.rp.nrhs {
+			fmt.Fprintf(w, "    %s\n", cfp)
+			continue
+		}
+
+		names := make([]string, 0, cfp.fws.Len())
+
+		for _, index := range cfp.fws.AsSlice() {
+			names = append(names, symbols[index].name)
+		}
+
+		fmt.Fprintf(w, "    %s  [%s]\n", cfp, strings.Join(names, " "))
+	}
+
+	fmt.Fprintln(w)
+
+	for _, ap := range stp.ap {
+		switch ap.actionType {
+		case Shift:
+			fmt.Fprintf(w, "%30s shift  %d\n", ap.sp.name, ap.stp.index)
+		case Reduce:
+			fmt.Fprintf(w, "%30s reduce %d\n", ap.sp.name, ap.rp.index)
+		case Conflict:
+			fmt.Fprintf(w, "%30s reduce %-3d ** Parsing conflict **\n", ap.sp.name, ap.rp.index)
+		case ShiftResolved:
+			fmt.Fprintf(w, "%30s shift  %-3d -- dropped by precedence\n", ap.sp.name, ap.stp.index)
+		case ReduceResolved:
+			fmt.Fprintf(w, "%30s reduce %-3d -- dropped by precedence\n", ap.sp.name, ap.rp.index)
+		case LookaheadDecided:
+			fmt.Fprintf(w, "%30s reduce %-3d -- decided by lookahead\n", ap.sp.name, ap.rp.index)
+		case NotUsed:
+		default:
+			fmt.Fprintf(w, "%30s %v\n", ap.sp.name, ap.actionType)
+		}
+	}
+
+	// The reduces replaced by the default action of the compressed table.
+	for _, ap := range stp.ap {
+		if ap.actionType == NotUsed {
+			fmt.Fprintf(w, "%30s reduce %d\n", "{default}", ap.rp.index)
+			break
+		}
+	}
+
+	for _, decision := range stp.decisions {
+		fmt.Fprintf(w, "\n    On %s, with %d tokens of lookahead:\n", decision.Lookahead.name, decision.K)
+
+		for i, ap := range decision.Actions {
+			for _, str := range decision.Strings[i] {
+				switch ap.actionType {
+				case Shift:
+					fmt.Fprintf(w, "%30s shift  %d\n", lookString(str).key(), ap.stp.index)
+				case Accept:
+					fmt.Fprintf(w, "%30s accept\n", lookString(str).key())
+				default:
+					fmt.Fprintf(w, "%30s reduce %d\n", lookString(str).key(), ap.rp.index)
+				}
+			}
+		}
+	}
 }
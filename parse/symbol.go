@@ -2,6 +2,7 @@ package parse
 
 import (
 	"hash/fnv"
+	"unicode"
 
 	"github.com/golemon/util"
 )
@@ -21,35 +22,82 @@ const (
 	Unknown
 )
 
+// A SymbolClassifier decides the type of a symbol which is neither declared
+// by `%token`, `%left`, `%right` or `%nonassoc` nor defined by a rule.
+type SymbolClassifier func(name string) SymbolType
+
+// Classifiers selectable by name, e.g. from the command line.
+var SymbolClassifiers = map[string]SymbolClassifier{
+	"case":  ClassifyByCase,
+	"first": ClassifyByFirstLetter,
+	"usage": ClassifyByUsage,
+}
+
+// Names in upper case such as `NUM` and string literals such as `'+'` are
+// terminals, everything else is a non-terminal. This is the default.
+func ClassifyByCase(name string) SymbolType {
+	if util.IsUpper(name) || util.IsStringLiteral(name) {
+		return Terminal
+	}
+
+	return NonTerminal
+}
+
+// Names starting with an upper case letter and string literals are terminals.
+// This is the convention of the C version of lemon.
+func ClassifyByFirstLetter(name string) SymbolType {
+	for _, r := range name {
+		if unicode.IsUpper(r) {
+			return Terminal
+		}
+
+		break
+	}
+
+	if util.IsStringLiteral(name) {
+		return Terminal
+	}
+
+	return NonTerminal
+}
+
+// Every symbol which is not defined by a rule is a terminal.
+func ClassifyByUsage(name string) SymbolType {
+	return Terminal
+}
+
 // Symbols (terminals and nonterminals) of the grammar are stored in the following.
 // TODO: fix data type
 type Symbol struct {
 	name       string      // Name of the symbol
 	index      int         // Index number for this symbol
 	symType    SymbolType  // Symbols are all either TERMINALS or NTs
+	kindPos    Position    // Where symType was fixed by a declaration or a rule (invalid if guessed)
+	tokenPos   Position    // First `%token` declaration
+	typePos    Position    // First `%type` declaration
+	precPos    Position    // Declaration by `%left`, `%right` or `%nonassoc`
+	lhsPos     Position    // First rule defining this symbol
+	usePos     Position    // First use on a right hand side or after `%prec`
 	rule       *Rule       // Linked list of rules of this (if an NT)
 	precedence int         // Precedence if defined (-1 otherwise)
 	assoc      SymbolAssoc // Associativity if predcence is defined
 	firstset   util.IntSet // First-set for all rules of this symbol
+	followset  util.IntSet // Terminals which can follow this symbol (if an NT)
 	nullable   bool        // True if NT and can generate an empty string
 	datatype   string      // The data type of information held by this object. Only used if type==NONTERMINAL
 	dtnum      int         // The data type number. In the parser, the value stack is a union. The .yy%d element of this union is the correct data type for this object
+	doc        string      // Doc comment of the declaration or the rules of this symbol
 }
 
-func NewSymbol(name string) *Symbol {
-	symbol := &Symbol{
-		name:     name,
-		firstset: make(util.IntSet),
-		nullable: false,
-	}
-
-	if util.IsUpper(name) {
-		symbol.symType = Terminal
-	} else {
-		symbol.symType = NonTerminal
+func NewSymbol(name string, symType SymbolType) *Symbol {
+	return &Symbol{
+		name:       name,
+		symType:    symType,
+		precedence: -1,
+		firstset:   make(util.IntSet),
+		followset:  make(util.IntSet),
+		nullable:   false,
 	}
-
-	return symbol
 }
 
 func (symbol Symbol) Equal(other Symbol) bool {
@@ -67,6 +115,39 @@ func (symbol *Symbol) Name() string {
 	return symbol.name
 }
 
+// Get the doc comment of the symbol, without comment markers.
+func (symbol *Symbol) Doc() string {
+	return symbol.doc
+}
+
+// Find the rule of this non-terminal with the given label.
+func (symbol *Symbol) ruleByLabel(label string) *Rule {
+	for rule := symbol.rule; rule != nil; rule = rule.nextlhs {
+		if rule.label == label {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// Get the type tag of the values of the symbol, from `%type` or `%token`,
+// or "".
+func (symbol *Symbol) DataType() string {
+	return symbol.datatype
+}
+
+// Get the precedence of a terminal, higher for the ones declared later,
+// or -1 if it has none.
+func (symbol *Symbol) Precedence() int {
+	return symbol.precedence
+}
+
+// Get the associativity of a terminal with a precedence.
+func (symbol *Symbol) Assoc() SymbolAssoc {
+	return symbol.assoc
+}
+
 func (symbol *Symbol) IsNullable() bool {
 	return symbol.nullable
 }
@@ -74,3 +155,15 @@ func (symbol *Symbol) IsNullable() bool {
 func (symbol *Symbol) IsTerminal() bool {
 	return symbol.symType == Terminal
 }
+
+func (symbol *Symbol) IsNonTerminal() bool {
+	return symbol.symType == NonTerminal
+}
+
+func (symType SymbolType) String() string {
+	if symType == Terminal {
+		return "terminal"
+	}
+
+	return "non-terminal"
+}
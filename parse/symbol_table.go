@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+
+	"github.com/golemon/util"
 )
 
 const TableSize = 1024
@@ -14,15 +16,22 @@ type SymbolTable struct {
 	numNonTerminal int
 	sortedSymbols  []*Symbol
 	symbols        map[string]*Symbol
+	classify       SymbolClassifier // Guess the type of undeclared symbols
 }
 
 func NewSymbolTable() *SymbolTable {
 	return &SymbolTable{
 		sortedSymbols: make([]*Symbol, 0, TableSize),
 		symbols:       make(map[string]*Symbol, TableSize),
+		classify:      ClassifyByCase,
 	}
 }
 
+// Set the classifier used for symbols inserted from now on.
+func (symTable *SymbolTable) SetClassifier(classify SymbolClassifier) {
+	symTable.classify = classify
+}
+
 // This function inserts the symbol with the given name into the symbol table.
 // If a symbol with the same name already exists, the existing symbol will be returned.
 func (symTable *SymbolTable) Insert(name string) *Symbol {
@@ -30,7 +39,7 @@ func (symTable *SymbolTable) Insert(name string) *Symbol {
 		return newSym
 	}
 
-	newSym := NewSymbol(name)
+	newSym := NewSymbol(name, symTable.classify(name))
 	symTable.symbols[name] = newSym
 	symTable.sortedSymbols = append(symTable.sortedSymbols, newSym)
 	symTable.hasNewInsert = true
@@ -45,6 +54,32 @@ func (symTable *SymbolTable) Insert(name string) *Symbol {
 	return newSym
 }
 
+// Fix the type of a symbol because of a declaration or a rule at `pos`.
+// A declaration always wins over the type guessed by the classifier.
+// If the type was already fixed to the other kind, the position which fixed
+// it is returned together with false.
+func (symTable *SymbolTable) fixKind(symbol *Symbol, symType SymbolType, pos Position) (Position, bool) {
+	if symbol.kindPos.IsValid() {
+		return symbol.kindPos, symbol.symType == symType
+	}
+
+	if symbol.symType != symType {
+		if symType == Terminal {
+			symTable.numNonTerminal--
+			symTable.numTerminal++
+		} else {
+			symTable.numTerminal--
+			symTable.numNonTerminal++
+		}
+
+		symbol.symType = symType
+	}
+
+	symbol.kindPos = pos
+
+	return pos, true
+}
+
 func (symTable *SymbolTable) Get(name string) (*Symbol, bool) {
 	symbol, ok := symTable.symbols[name]
 
@@ -73,8 +108,16 @@ func (symTable *SymbolTable) SortedSymbols() []*Symbol {
 		return symTable.sortedSymbols[:size]
 	}
 
+	// The end of input `$` is first whatever the names, e.g. `"+"` sorts
+	// before it.
 	sort.Slice(symTable.sortedSymbols[:size], func(i, j int) bool {
-		return symTable.sortedSymbols[i].name < symTable.sortedSymbols[j].name
+		x, y := symTable.sortedSymbols[i].name, symTable.sortedSymbols[j].name
+
+		if x == EndSymbolName || y == EndSymbolName {
+			return x == EndSymbolName && y != EndSymbolName
+		}
+
+		return x < y
 	})
 
 	// TODO: this may be not necessary.
@@ -88,31 +131,133 @@ func (symTable *SymbolTable) SortedSymbols() []*Symbol {
 	return symTable.sortedSymbols[:size]
 }
 
-func (symTable *SymbolTable) PrintFirstSets() {
+// The name of the end of input symbol. It can't clash with a grammar
+// symbol. Its index is 0: it is sorted before all of them.
+const EndSymbolName = "$"
+
+// Insert the end of input terminal.
+func (symTable *SymbolTable) insertEnd() *Symbol {
+	symbol := symTable.Insert(EndSymbolName)
+	symTable.fixKind(symbol, Terminal, Position{})
+	symbol.doc = "The end of the input."
+
+	return symbol
+}
+
+// Get the names of the symbols in an index set, in index order.
+func (symTable *SymbolTable) names(set util.IntSet) []string {
 	sortedSymbols := symTable.SortedSymbols()
+	indexes := set.AsSlice()
+	sort.Ints(indexes)
+	names := make([]string, len(indexes))
+
+	for i, index := range indexes {
+		names[i] = sortedSymbols[index].name
+	}
 
-	for _, symbol := range sortedSymbols {
-		fmt.Printf("%s => { ", symbol.Name())
-		symNames := make([]string, 0, symbol.firstset.Len())
+	return names
+}
 
-		for index := range symbol.firstset {
-			symNames = append(symNames, sortedSymbols[index].name)
+func (symTable *SymbolTable) PrintFirstSets() {
+	for _, symbol := range symTable.SortedSymbols() {
+		if symbol.IsTerminal() {
+			continue
 		}
 
+		symNames := symTable.names(symbol.firstset)
+
 		if symbol.IsNullable() {
 			symNames = append(symNames, "ε")
 		}
 
-		fmt.Printf(strings.Join(symNames, ", "))
-		fmt.Println(" }")
+		fmt.Printf("%s => { %s }\n", symbol.Name(), strings.Join(symNames, ", "))
+	}
+}
+
+type Severity int
+
+const (
+	SevWarning Severity = iota
+	SevError
+)
+
+func (sev Severity) String() string {
+	if sev == SevError {
+		return "error"
+	}
+
+	return "warning"
+}
+
+// A problem with a symbol found after reading the grammar.
+type Finding struct {
+	Severity Severity
+	Symbol   *Symbol
+	Message  string
+	Use      Position // Where the symbol is used (invalid if it is not)
+	Decl     Position // Where the symbol is declared or defined (invalid if it is not)
+}
+
+// Get the position the finding is reported at: the use if any,
+// otherwise the declaration.
+func (finding *Finding) Position() Position {
+	if finding.Use.IsValid() {
+		return finding.Use
+	}
+
+	return finding.Decl
+}
+
+// Get the first valid position.
+func firstValid(positions ...Position) Position {
+	for _, pos := range positions {
+		if pos.IsValid() {
+			return pos
+		}
 	}
+
+	return Position{}
 }
 
 // Each symbol in symbol table must either be:
-// 1. Terminal
-// 2. Non-terminal
-//   2.1 Defined with %type
-//   2.2
-func (symTable *SymbolTable) verifyAllSymbols() {
+// 1. Terminal, never on the left hand side of a rule
+// 2. Non-terminal, defined by at least one rule, without precedence
+// Declared symbols should be used, and `%type` should only be given to
+// non-terminals. Findings are sorted by position.
+func (symTable *SymbolTable) verifyAllSymbols() []Finding {
+	var findings []Finding
+
+	report := func(sev Severity, symbol *Symbol, use, decl Position, format string, args ...interface{}) {
+		findings = append(findings, Finding{sev, symbol, fmt.Sprintf(format, args...), use, decl})
+	}
+
+	for _, symbol := range symTable.SortedSymbols() {
+		name := symbol.name
+		hasRule := symbol.lhsPos.IsValid()
+
+		switch {
+		case symbol.IsTerminal() && hasRule && symbol.tokenPos.IsValid():
+			report(SevError, symbol, symbol.lhsPos, symbol.tokenPos, "Terminal `%s` is used as the left hand side of a rule", name)
+		case symbol.IsTerminal() && hasRule && symbol.precPos.IsValid():
+			report(SevError, symbol, symbol.lhsPos, symbol.precPos, "Precedence is declared on non-terminal `%s`", name)
+		case symbol.IsNonTerminal() && !hasRule && symbol.usePos.IsValid():
+			report(SevError, symbol, symbol.usePos, symbol.typePos, "Non-terminal `%s` is never defined by a rule", name)
+		}
+
+		decl := firstValid(symbol.tokenPos, symbol.typePos, symbol.precPos)
+
+		if decl.IsValid() && !hasRule && !symbol.usePos.IsValid() {
+			report(SevWarning, symbol, Position{}, decl, "Symbol `%s` is declared but never used", name)
+		}
+
+		if symbol.typePos.IsValid() && symbol.IsTerminal() && !hasRule {
+			report(SevWarning, symbol, symbol.usePos, symbol.typePos, "`%%type` is given to terminal `%s`", name)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Position().Offset < findings[j].Position().Offset
+	})
 
+	return findings
 }
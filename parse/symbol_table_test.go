@@ -73,3 +73,147 @@ func TestSortedSymbols(t *testing.T) {
 		}
 	}
 }
+
+func TestEndSymbolFirst(t *testing.T) {
+	lemon := readGrammar(t, "plus.y", "%%\ne: e \"+\" e | N ;\n")
+	end := mustSymbol(t, lemon, EndSymbolName)
+
+	if end.index != 0 {
+		t.Errorf("Expect index 0 for `$`, actual %d", end.index)
+	}
+
+	if terminals := lemon.symTable.terminals(); terminals[0] != end {
+		t.Errorf("Expect `$` as the first terminal, actual `%s`", terminals[0].name)
+	}
+}
+
+func TestClassifiers(t *testing.T) {
+	cases := []struct {
+		name    string
+		byCase  SymbolType
+		byFirst SymbolType
+	}{
+		{"NUM", Terminal, Terminal},
+		{"Ident", NonTerminal, Terminal},
+		{"expr2", NonTerminal, NonTerminal},
+		{"'+'", Terminal, Terminal},
+		{"'a'", Terminal, Terminal},
+	}
+
+	for _, c := range cases {
+		if actual := ClassifyByCase(c.name); actual != c.byCase {
+			t.Errorf("ClassifyByCase(%s): expect %v, actual %v", c.name, c.byCase, actual)
+		}
+
+		if actual := ClassifyByFirstLetter(c.name); actual != c.byFirst {
+			t.Errorf("ClassifyByFirstLetter(%s): expect %v, actual %v", c.name, c.byFirst, actual)
+		}
+
+		if actual := ClassifyByUsage(c.name); actual != Terminal {
+			t.Errorf("ClassifyByUsage(%s): expect terminal, actual %v", c.name, actual)
+		}
+	}
+}
+
+func TestFixKind(t *testing.T) {
+	symTable := NewSymbolTable()
+	ident := symTable.Insert("Ident")
+
+	if ident.IsTerminal() || symTable.TerminalCount() != 0 {
+		t.Errorf("Expect `Ident` to be guessed as non-terminal")
+	}
+
+	// `%token Ident` overrides the guess.
+	if pos, ok := symTable.fixKind(ident, Terminal, Position{Line: 3, Column: 8}); !ok || pos.Line != 3 {
+		t.Errorf("Expect no contradiction, actual position: %v", pos)
+	}
+
+	if !ident.IsTerminal() || symTable.TerminalCount() != 1 || symTable.NonTerminalCount() != 0 {
+		t.Errorf("Expect `Ident` to be a terminal")
+	}
+
+	// `%left Ident` agrees.
+	if _, ok := symTable.fixKind(ident, Terminal, Position{Line: 5, Column: 7}); !ok {
+		t.Errorf("Expect no contradiction")
+	}
+
+	// `Ident: ...` contradicts the declaration at line 3.
+	if pos, ok := symTable.fixKind(ident, NonTerminal, Position{Line: 9, Column: 1}); ok || pos.String() != "3:8" {
+		t.Errorf("Expect contradiction with 3:8, actual position: %v", pos)
+	}
+
+	if !ident.IsTerminal() {
+		t.Errorf("Expect the first declaration to win")
+	}
+}
+
+func TestSetClassifier(t *testing.T) {
+	symTable := NewSymbolTable()
+	symTable.SetClassifier(ClassifyByFirstLetter)
+
+	if !symTable.Insert("Ident").IsTerminal() {
+		t.Errorf("Expect `Ident` to be a terminal")
+	}
+
+	if symTable.Insert("expr").IsTerminal() {
+		t.Errorf("Expect `expr` to be a non-terminal")
+	}
+}
+
+func TestVerifyAllSymbols(t *testing.T) {
+	src := `%{
+package main
+%}
+
+%token NUM
+%token OP UNUSED
+%type <num> expr NUM
+%left PREC
+%nonassoc stmt
+
+%%
+
+expr: expr OP term | NUM ;
+OP: NUM ;
+stmt: expr PREC ;
+
+%%
+`
+	lemon := NewLemonFromBytes("verify.y", []byte(src), "verify.go")
+	lemon.read()
+
+	expects := []struct {
+		sev       Severity
+		name      string
+		use, decl string
+	}{
+		{SevWarning, "UNUSED", "", "6:11"},
+		{SevError, "term", "13:15", ""},
+		{SevWarning, "NUM", "13:22", "7:18"},
+		{SevError, "OP", "14:1", "6:8"},
+		{SevError, "stmt", "15:1", "9:11"},
+	}
+	findings := lemon.Findings()
+
+	if len(findings) != len(expects) {
+		t.Fatalf("Expect %d findings, actual %v", len(expects), findings)
+	}
+
+	for i, e := range expects {
+		f := findings[i]
+		use, decl := "", ""
+
+		if f.Use.IsValid() {
+			use = f.Use.String()
+		}
+
+		if f.Decl.IsValid() {
+			decl = f.Decl.String()
+		}
+
+		if f.Severity != e.sev || f.Symbol.Name() != e.name || use != e.use || decl != e.decl {
+			t.Errorf("Finding %d: expect %v %s at %q declared at %q, actual %v %s at %q declared at %q (%s)",
+				i, e.sev, e.name, e.use, e.decl, f.Severity, f.Symbol.Name(), use, decl, f.Message)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package parse
+
+import "fmt"
+
+// Get the start symbol: the one given by `%start`, or else the left hand
+// side of the first rule.
+func (lemon *Lemon) startSymbol() *Symbol {
+	if lemon.start != "" {
+		if symbol, ok := lemon.symTable.Get(lemon.start); ok {
+			return symbol
+		}
+	}
+
+	if lemon.firstRule != nil {
+		return lemon.firstRule.lhs
+	}
+
+	return nil
+}
+
+func rulePosition(rule *Rule) Position {
+	return Position{Line: rule.ruleLineno}
+}
+
+// Find the non-terminals which derive at least one string of terminals.
+// Terminals are always productive.
+func (lemon *Lemon) productiveSymbols() map[*Symbol]bool {
+	productive := map[*Symbol]bool{}
+
+	for changed := true; changed; {
+		changed = false
+
+		for rule := lemon.firstRule; rule != nil; rule = rule.next {
+			if productive[rule.lhs] {
+				continue
+			}
+
+			ok := true
+
+			for _, symbol := range rule.rhs[:rule.nrhs] {
+				if symbol.IsNonTerminal() && !productive[symbol] {
+					ok = false
+					break
+				}
+			}
+
+			if ok {
+				productive[rule.lhs] = true
+				changed = true
+			}
+		}
+	}
+
+	return productive
+}
+
+// Find the symbols reachable from the start symbol through the rules.
+// A terminal given by `%prec` is reached with its rule.
+func (lemon *Lemon) reachableSymbols(start *Symbol) map[*Symbol]bool {
+	reachable := map[*Symbol]bool{start: true}
+	stack := []*Symbol{start}
+
+	for len(stack) > 0 {
+		symbol := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for rule := symbol.rule; rule != nil; rule = rule.nextlhs {
+			for _, rhs := range rule.rhs[:rule.nrhs] {
+				if !reachable[rhs] {
+					reachable[rhs] = true
+					stack = append(stack, rhs)
+				}
+			}
+
+			if rule.precSym != nil {
+				reachable[rule.precSym] = true
+			}
+		}
+	}
+
+	return reachable
+}
+
+// Remove the rules for which `useless` is true and renumber the others.
+func (lemon *Lemon) removeRules(useless func(rule *Rule) bool) {
+	var first, last *Rule
+	lemon.nrule = 0
+
+	for _, symbol := range lemon.symTable.SortedSymbols() {
+		symbol.rule = nil
+	}
+
+	for rule := lemon.firstRule; rule != nil; rule = rule.next {
+		if useless(rule) {
+			continue
+		}
+
+		if first == nil {
+			first = rule
+		} else {
+			last.next = rule
+		}
+
+		last = rule
+		rule.index = lemon.nrule
+		lemon.nrule++
+	}
+
+	if last != nil {
+		last.next = nil
+	}
+
+	lemon.firstRule = first
+
+	// Rebuild the rules of each non-terminal, prepended like NewRule does.
+	for rule := first; rule != nil; rule = rule.next {
+		rule.nextlhs = rule.lhs.rule
+		rule.lhs.rule = rule
+	}
+}
+
+// Remove useless rules before the tables are built, with a warning for
+// each useless symbol and rule:
+//  1. Non-terminals which can never derive a string of terminals are
+//     nonproductive. So are the rules using them.
+//  2. Symbols which can't be reached from the start symbol, through the
+//     remaining rules, are unreachable. So are their rules.
+//
+// A nonproductive start symbol is an error, the grammar has no sentence.
+func (lemon *Lemon) removeUselessRules() {
+	start := lemon.startSymbol()
+
+	if start == nil {
+		return
+	}
+
+	report := func(sev Severity, symbol *Symbol, pos Position, format string, args ...interface{}) {
+		lemon.findings = append(lemon.findings, Finding{sev, symbol, fmt.Sprintf(format, args...), Position{}, pos})
+	}
+
+	if symbol, ok := lemon.symTable.Get(lemon.start); lemon.start != "" && (!ok || symbol.rule == nil) {
+		report(SevError, nil, lemon.startPos, "Unknown start symbol `%s`", lemon.start)
+		return
+	}
+
+	productive := lemon.productiveSymbols()
+
+	if !productive[start] {
+		report(SevError, start, start.lhsPos, "Start symbol `%s` derives no sentence", start.name)
+		return
+	}
+
+	for _, symbol := range lemon.symTable.SortedSymbols() {
+		if symbol.IsNonTerminal() && symbol.rule != nil && !productive[symbol] {
+			report(SevWarning, symbol, symbol.lhsPos, "Non-terminal `%s` is useless: it derives no string of terminals", symbol.name)
+		}
+	}
+
+	lemon.removeRules(func(rule *Rule) bool {
+		if !productive[rule.lhs] {
+			report(SevWarning, rule.lhs, rulePosition(rule), "Rule `%v` is useless: `%s` derives no string of terminals", rule, rule.lhs.name)
+			return true
+		}
+
+		for _, symbol := range rule.rhs[:rule.nrhs] {
+			if !productive[symbol] && symbol.IsNonTerminal() {
+				report(SevWarning, rule.lhs, rulePosition(rule), "Rule `%v` is useless: `%s` derives no string of terminals", rule, symbol.name)
+				return true
+			}
+		}
+
+		return false
+	})
+
+	reachable := lemon.reachableSymbols(start)
+
+	for _, symbol := range lemon.symTable.SortedSymbols() {
+		// Symbols never used at all are already reported by verifyAllSymbols.
+		used := symbol.rule != nil || (symbol.IsTerminal() && symbol.usePos.IsValid())
+
+		if used && !reachable[symbol] {
+			report(SevWarning, symbol, firstValid(symbol.lhsPos, symbol.usePos), "Symbol `%s` is useless: it is unreachable from the start symbol `%s`", symbol.name, start.name)
+		}
+	}
+
+	lemon.removeRules(func(rule *Rule) bool {
+		if !reachable[rule.lhs] {
+			report(SevWarning, rule.lhs, rulePosition(rule), "Rule `%v` is useless: `%s` is unreachable", rule, rule.lhs.name)
+			return true
+		}
+
+		return false
+	})
+}
+
+// Report the rules which are never reduced, because conflicts are always
+// resolved against them. Must be called once the tables are built.
+func (lemon *Lemon) reportUnreducedRules() {
+	for rule := lemon.firstRule; rule != nil; rule = rule.next {
+		if !rule.canReduce {
+			lemon.findings = append(lemon.findings, Finding{SevWarning, rule.lhs,
+				fmt.Sprintf("Rule `%v` can never be reduced", rule), Position{}, rulePosition(rule)})
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package parse
+
+import (
+	"testing"
+)
+
+const uselessGrammar = `%{
+package main
+%}
+
+%token A B C D
+
+%%
+
+s: a | s A ;
+a: A ;
+b: b B ;
+c: b C | C D ;
+
+%%
+`
+
+func ruleStrings(lemon *Lemon) []string {
+	var rules []string
+
+	for rule := lemon.firstRule; rule != nil; rule = rule.next {
+		rules = append(rules, rule.String())
+	}
+
+	return rules
+}
+
+func checkStrings(t *testing.T, what string, expects, actuals []string) {
+	if len(expects) != len(actuals) {
+		t.Errorf("Expect %s %q, actual %q", what, expects, actuals)
+		return
+	}
+
+	for i := range expects {
+		if expects[i] != actuals[i] {
+			t.Errorf("Expect %s %q, actual %q", what, expects, actuals)
+			return
+		}
+	}
+}
+
+func TestRemoveUselessRules(t *testing.T) {
+	lemon := NewLemonFromBytes("useless.y", []byte(uselessGrammar), "useless.go")
+	lemon.read()
+	lemon.removeUselessRules()
+
+	checkStrings(t, "rules", []string{"s:a.", "s:s A.", "a:A."}, ruleStrings(lemon))
+
+	var messages []string
+
+	for _, finding := range lemon.Findings() {
+		if finding.Severity != SevWarning {
+			t.Errorf("Unexpected error: %s", finding.Message)
+		}
+
+		messages = append(messages, finding.Message)
+	}
+
+	checkStrings(t, "warnings", []string{
+		"Non-terminal `b` is useless: it derives no string of terminals",
+		"Rule `b:b B.` is useless: `b` derives no string of terminals",
+		"Rule `c:b C.` is useless: `b` derives no string of terminals",
+		"Symbol `B` is useless: it is unreachable from the start symbol `s`",
+		"Symbol `C` is useless: it is unreachable from the start symbol `s`",
+		"Symbol `D` is useless: it is unreachable from the start symbol `s`",
+		"Symbol `c` is useless: it is unreachable from the start symbol `s`",
+		"Rule `c:C D.` is useless: `c` is unreachable",
+	}, messages)
+
+	for i, rule := 0, lemon.firstRule; rule != nil; i, rule = i+1, rule.next {
+		if rule.index != i {
+			t.Errorf("Rule %v: expect index %d, actual %d", rule, i, rule.index)
+		}
+	}
+
+	if s, _ := lemon.symTable.Get("s"); s.rule == nil || s.rule.nextlhs == nil || s.rule.nextlhs.nextlhs != nil {
+		t.Errorf("Expect 2 rules for `s`")
+	}
+}
+
+func TestStartSymbol(t *testing.T) {
+	src := "%{\n%}\n%token A\n%start a\n%%\ns: a ;\na: A ;\n%%\n"
+	lemon := NewLemonFromBytes("start.y", []byte(src), "start.go")
+	lemon.read()
+	lemon.removeUselessRules()
+
+	checkStrings(t, "rules", []string{"a:A."}, ruleStrings(lemon))
+}
+
+func TestNonproductiveStart(t *testing.T) {
+	src := "%{\n%}\n%token A\n%%\ns: s A ;\n%%\n"
+	lemon := NewLemonFromBytes("start.y", []byte(src), "start.go")
+	lemon.read()
+	lemon.removeUselessRules()
+
+	if findings := lemon.Findings(); len(findings) != 1 || findings[0].Severity != SevError {
+		t.Errorf("Expect an error for the start symbol, actual %v", findings)
+	}
+}
+
+func TestReportUnreducedRules(t *testing.T) {
+	lemon := NewLemonFromBytes("useless.y", []byte(uselessGrammar), "useless.go")
+	lemon.read()
+	lemon.removeUselessRules()
+	lemon.findings = nil
+
+	for rule := lemon.firstRule; rule != nil; rule = rule.next {
+		rule.canReduce = rule.lhs.name != "a"
+	}
+
+	lemon.reportUnreducedRules()
+
+	if findings := lemon.Findings(); len(findings) != 1 || findings[0].Message != "Rule `a:A.` can never be reduced" {
+		t.Errorf("Unexpected findings: %v", findings)
+	}
+}
+
+func TestUnknownStartSymbol(t *testing.T) {
+	src := "%{\n%}\n%token A\n%start nope\n%%\ns: A ;\n%%\n"
+	lemon := NewLemonFromBytes("start.y", []byte(src), "start.go")
+	lemon.read()
+	lemon.removeUselessRules()
+	findings := lemon.Findings()
+
+	if len(findings) != 1 || findings[0].Message != "Unknown start symbol `nope`" || findings[0].Position().Line != 4 {
+		t.Errorf("Expect an unknown start symbol at line 4, actual %v", findings)
+	}
+
+	if _, ok := lemon.Symbol("nope"); ok {
+		t.Errorf("Expect no symbol `nope`")
+	}
+}
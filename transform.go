@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golemon/parse"
+	"github.com/golemon/transform"
+)
+
+func transformUsage(set *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintln(os.Stderr, "usage: lemon transform -t name[,name...] [flags] infile")
+		set.PrintDefaults()
+		os.Exit(2)
+	}
+}
+
+// Run `golemon transform`: apply transforms to the grammar, in order, and
+// print the new grammar.
+func runTransform(args []string) {
+	var names []string
+
+	for name := range transform.Transforms {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	set := flag.NewFlagSet("transform", flag.ExitOnError)
+	transforms := set.String("t", "", "transforms to apply, separated by commas: "+strings.Join(names, ", "))
+	classify := set.String("classify", "case", "type of symbols neither declared nor defined by a rule: case, first or usage")
+	set.Usage = transformUsage(set)
+	set.Parse(args)
+
+	if set.NArg() != 1 || *transforms == "" {
+		set.Usage()
+	}
+
+	classifier, ok := parse.SymbolClassifiers[*classify]
+
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown symbol classifier: %s\n", *classify)
+		set.Usage()
+	}
+
+	var steps []transform.Transform
+
+	for _, name := range strings.Split(*transforms, ",") {
+		step, ok := transform.Transforms[strings.TrimSpace(name)]
+
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown transform: %s\n", name)
+			set.Usage()
+		}
+
+		steps = append(steps, step)
+	}
+
+	lemon := parse.NewLemon(set.Arg(0), "")
+	lemon.SetSymbolClassifier(classifier)
+	lemon.ReadGrammar()
+	g := transform.FromLemon(lemon)
+
+	for _, step := range steps {
+		g, _ = step(g)
+	}
+
+	if _, err := g.WriteTo(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,101 @@
+package transform
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Put the grammar in Chomsky normal form: every rule is `A ::= B C` or
+// `A ::= a`, but the rule `S ::= ε` of a start symbol which is on no right
+// hand side. A new start symbol derives the old one if needed, the
+// terminals of the longer rules become non-terminals, the rules are split
+// into rules of two symbols, then the ε-rules and the unit rules are
+// removed.
+func ChomskyNormalForm(g *Grammar) (*Grammar, Mapping) {
+	b := newBuilder(g)
+	start := g.Start
+
+	if g.onRightHandSide(start) {
+		start = b.newName(g.Start, "")
+		b.add(&Rule{Lhs: start, Rhs: []string{g.Start}})
+	}
+
+	b.separateTerminals()
+	b.binarize()
+	split, mapping := b.result(start)
+	nonEmpty, next := RemoveEpsilonRules(split)
+	mapping = mapping.Then(next)
+	result, next := RemoveUnitRules(nonEmpty)
+
+	return result, mapping.Then(next)
+}
+
+// Replace the terminals of the rules of several symbols by non-terminals
+// which derive them.
+func (b *builder) separateTerminals() {
+	names := make(map[string]string) // The non-terminal of each terminal
+
+	for _, rule := range append([]*Rule(nil), b.rules...) {
+		if len(rule.Rhs) < 2 {
+			continue
+		}
+
+		rhs := concat(rule.Rhs, nil)
+		changed := false
+
+		for i, symbol := range rhs {
+			if !b.old.IsTerminal(symbol) {
+				continue
+			}
+
+			if names[symbol] == "" {
+				names[symbol] = b.newName(terminalBase(symbol), "")
+				b.add(&Rule{Lhs: names[symbol], Rhs: []string{symbol}})
+			}
+
+			rhs[i], changed = names[symbol], true
+		}
+
+		if changed {
+			b.replace(rule, rule.Lhs, rhs)
+		}
+	}
+}
+
+// Get the base of the name of the non-terminal of a terminal: its name in
+// lower case, or `char` for a character literal.
+func terminalBase(name string) string {
+	for _, c := range name {
+		if c != '_' && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return "char"
+		}
+	}
+
+	return strings.ToLower(name)
+}
+
+// Split the rules of more than two symbols: `A ::= X Y Z` becomes
+// `A ::= X A_1` and `A_1 ::= Y Z`.
+func (b *builder) binarize() {
+	for _, rule := range append([]*Rule(nil), b.rules...) {
+		if len(rule.Rhs) <= 2 {
+			continue
+		}
+
+		origins := b.origins[rule]
+		lhs := rule.Lhs
+		i := b.remove(rule)
+
+		for rhs := rule.Rhs; len(rhs) > 0; {
+			if len(rhs) == 2 {
+				b.insert(i, &Rule{Lhs: lhs, Rhs: rhs, Prec: rule.Prec}, origins...)
+
+				break
+			}
+
+			next := b.newName(rule.Lhs, lhs)
+			i = b.insert(i, &Rule{Lhs: lhs, Rhs: []string{rhs[0], next}, Prec: rule.Prec}, origins...)
+			lhs, rhs = next, rhs[1:]
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package transform
+
+// Remove the ε-rules: a rule becomes the rules without each subset of its
+// nullable symbols, but the empty one. If the start symbol is nullable, it
+// keeps an ε-rule, or a new start symbol derives it or ε if it is on a
+// right hand side.
+func RemoveEpsilonRules(g *Grammar) (*Grammar, Mapping) {
+	b := newBuilder(g)
+	nullable := g.nullable()
+	var emptyStart []*Rule // The ε-rules of the start symbol
+
+	for _, rule := range g.Rules {
+		if rule.Lhs == g.Start && len(rule.Rhs) == 0 {
+			emptyStart = append(emptyStart, rule)
+		}
+	}
+
+	for _, rule := range append([]*Rule(nil), b.rules...) {
+		rhss := withoutNullable(rule.Rhs, nullable)
+
+		if len(rhss) != 1 || !sameSymbols(rhss[0], rule.Rhs) {
+			b.replace(rule, rule.Lhs, rhss...)
+		}
+	}
+
+	b.removeUnproductive()
+	start := g.Start
+
+	if nullable[start] {
+		if g.onRightHandSide(start) {
+			start = b.newName(g.Start, "")
+			b.add(&Rule{Lhs: start, Rhs: []string{g.Start}})
+		}
+
+		b.add(&Rule{Lhs: start}, emptyStart...)
+	}
+
+	return b.result(start)
+}
+
+// Get the right hand sides without each subset of the nullable symbols,
+// the longest first, but the empty one.
+func withoutNullable(rhs []string, nullable map[string]bool) [][]string {
+	rhss := [][]string{nil}
+
+	for _, symbol := range rhs {
+		var next [][]string
+
+		for _, prefix := range rhss {
+			next = append(next, concat(prefix, []string{symbol}))
+		}
+
+		if nullable[symbol] {
+			for _, prefix := range rhss {
+				if !containsSymbols(next, prefix) {
+					next = append(next, prefix)
+				}
+			}
+		}
+
+		rhss = next
+	}
+
+	if len(rhss) > 0 && len(rhss[len(rhss)-1]) == 0 {
+		rhss = rhss[:len(rhss)-1]
+	}
+
+	return rhss
+}
+
+func containsSymbols(rhss [][]string, rhs []string) bool {
+	for _, other := range rhss {
+		if sameSymbols(other, rhs) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check if the symbol is on the right hand side of a rule.
+func (g *Grammar) onRightHandSide(symbol string) bool {
+	for _, rule := range g.Rules {
+		for _, other := range rule.Rhs {
+			if other == symbol {
+				return true
+			}
+		}
+	}
+
+	return false
+}
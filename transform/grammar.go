@@ -0,0 +1,597 @@
+// Package transform rewrites grammars into equivalent ones: without left
+// recursion, left factored, without ε-rules or unit rules, or in Chomsky
+// normal form.
+//
+// The transforms work on a Grammar made of the rules of a parse.Lemon, and
+// return a new one with the rules each old rule became. Only the rules
+// left as they are keep their code and label: the others are marked as
+// having dropped it. The declarations and the code around the rules are
+// kept as they are.
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golemon/format"
+	"github.com/golemon/parse"
+)
+
+// A terminal, its precedence and the type of its values.
+type Terminal struct {
+	Name       string
+	Precedence int // -1 if it has none
+	Assoc      parse.SymbolAssoc
+	Type       string // The tag given by `%token`, or ""
+}
+
+// A rule of a Grammar. Symbols are named as in the grammar file.
+type Rule struct {
+	Lhs   string
+	Rhs   []string
+	Label string // The label of the rule, or ""
+	Prec  string // The terminal given by `%prec`, or ""
+	Code  string // The code of the rule with its braces, or ""
+
+	Dropped bool // True if the rules it comes from have code it doesn't keep
+}
+
+func (rule *Rule) String() string {
+	return rule.Lhs + ":" + strings.Join(rule.Rhs, " ") + "."
+}
+
+// Check two rules have the same left and right hand sides.
+func (rule *Rule) sameAs(other *Rule) bool {
+	return rule.Lhs == other.Lhs && sameSymbols(rule.Rhs, other.Rhs)
+}
+
+func sameSymbols(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// A grammar to transform. Every symbol which is not a terminal is a
+// non-terminal.
+type Grammar struct {
+	Start     string
+	Terminals []Terminal // Sorted by name
+	Rules     []*Rule    // In the order of the grammar file
+
+	Name     string            // The name given by `%name`, or ""
+	Prologue string            // The code between `%{` and `%}`, or ""
+	Union    string            // The fields given by `%union` with their braces, or ""
+	Types    map[string]string // The tags given by `%type`, by non-terminal
+	Epilogue string            // The code after the second `%%`, or ""
+}
+
+// The rules of the new grammar each rule of the old one became.
+type Mapping map[*Rule][]*Rule
+
+// A transform of a grammar.
+type Transform func(g *Grammar) (*Grammar, Mapping)
+
+// Transforms selectable by name, e.g. from the command line.
+var Transforms = map[string]Transform{
+	"left-recursion": RemoveLeftRecursion,
+	"left-factor":    LeftFactor,
+	"epsilon":        RemoveEpsilonRules,
+	"unit":           RemoveUnitRules,
+	"cnf":            ChomskyNormalForm,
+}
+
+// Get the grammar of a Lemon which has read its grammar.
+func FromLemon(lemon *parse.Lemon) *Grammar {
+	g := &Grammar{
+		Start:    lemon.StartSymbol().Name(),
+		Name:     lemon.Name(),
+		Prologue: lemon.Include(),
+		Union:    lemon.Union(),
+		Types:    make(map[string]string),
+		Epilogue: lemon.ExtraCode(),
+	}
+
+	for _, symbol := range lemon.Symbols() {
+		if symbol.IsTerminal() && symbol.Name() != parse.EndSymbolName {
+			g.Terminals = append(g.Terminals, Terminal{symbol.Name(), symbol.Precedence(), symbol.Assoc(), symbol.DataType()})
+		} else if !symbol.IsTerminal() && symbol.DataType() != "" {
+			g.Types[symbol.Name()] = symbol.DataType()
+		}
+	}
+
+	for _, rp := range lemon.Rules() {
+		rule := &Rule{Lhs: rp.GetLhsSymbol().Name(), Label: rp.Label(), Code: rp.Code()}
+
+		for _, symbol := range rp.Rhs() {
+			rule.Rhs = append(rule.Rhs, symbol.Name())
+		}
+
+		// `%prec` is only kept if it changes the precedence of the rule.
+		if prec := rp.PrecSymbol(); prec != nil && prec.Name() != g.lastPrecedence(rule.Rhs) {
+			rule.Prec = prec.Name()
+		}
+
+		g.Rules = append(g.Rules, rule)
+	}
+
+	return g
+}
+
+// Get the last terminal with a precedence of the symbols, or "".
+func (g *Grammar) lastPrecedence(symbols []string) string {
+	for i := len(symbols) - 1; i >= 0; i-- {
+		if t := g.terminal(symbols[i]); t != nil && t.Precedence >= 0 {
+			return t.Name
+		}
+	}
+
+	return ""
+}
+
+func (g *Grammar) terminal(name string) *Terminal {
+	i := sort.Search(len(g.Terminals), func(i int) bool { return g.Terminals[i].Name >= name })
+
+	if i < len(g.Terminals) && g.Terminals[i].Name == name {
+		return &g.Terminals[i]
+	}
+
+	return nil
+}
+
+func (g *Grammar) IsTerminal(name string) bool {
+	return g.terminal(name) != nil
+}
+
+// Get the non-terminals, in the order of their first rule.
+func (g *Grammar) NonTerminals() []string {
+	var names []string
+	seen := make(map[string]bool)
+
+	for _, rule := range g.Rules {
+		if !seen[rule.Lhs] {
+			seen[rule.Lhs] = true
+			names = append(names, rule.Lhs)
+		}
+	}
+
+	return names
+}
+
+// Get the rules of the non-terminal, in order.
+func (g *Grammar) RulesOf(lhs string) []*Rule {
+	var rules []*Rule
+
+	for _, rule := range g.Rules {
+		if rule.Lhs == lhs {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
+
+// Find the non-terminals which derive the empty string.
+func (g *Grammar) nullable() map[string]bool {
+	nullable := make(map[string]bool)
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, rule := range g.Rules {
+			if !nullable[rule.Lhs] && allIn(rule.Rhs, nullable) {
+				nullable[rule.Lhs] = true
+				changed = true
+			}
+		}
+	}
+
+	return nullable
+}
+
+func allIn(symbols []string, set map[string]bool) bool {
+	for _, symbol := range symbols {
+		if !set[symbol] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Write the grammar as a grammar file: the code and the declarations, the
+// rules, then the code after them. The rules which dropped the code of the
+// ones they come from are marked with a comment.
+func (g *Grammar) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	var names []string
+	levels := make(map[int][]Terminal)
+	tokens := make(map[string][]string)
+	types := make(map[string][]string)
+
+	if g.Prologue != "" {
+		fmt.Fprintf(&buf, "%%{%s%%}\n", g.Prologue)
+	}
+
+	if g.Name != "" {
+		fmt.Fprintf(&buf, "%%name %s\n", g.Name)
+	}
+
+	if g.Union != "" {
+		fmt.Fprintf(&buf, "%%union %s\n", g.Union)
+	}
+
+	for _, t := range g.Terminals {
+		if t.Precedence < 0 || t.Type != "" {
+			tokens[t.Type] = append(tokens[t.Type], t.Name)
+		}
+
+		if t.Precedence >= 0 {
+			levels[t.Precedence] = append(levels[t.Precedence], t)
+		}
+	}
+
+	for _, tag := range sortedKeys(tokens) {
+		if tag == "" {
+			fmt.Fprintf(&buf, "%%token %s\n", strings.Join(tokens[tag], " "))
+		} else {
+			fmt.Fprintf(&buf, "%%token <%s> %s\n", tag, strings.Join(tokens[tag], " "))
+		}
+	}
+
+	for _, lhs := range g.NonTerminals() {
+		if tag := g.Types[lhs]; tag != "" {
+			types[tag] = append(types[tag], lhs)
+		}
+	}
+
+	for _, tag := range sortedKeys(types) {
+		fmt.Fprintf(&buf, "%%type <%s> %s\n", tag, strings.Join(types[tag], " "))
+	}
+
+	precedences := make([]int, 0, len(levels))
+
+	for precedence := range levels {
+		precedences = append(precedences, precedence)
+	}
+
+	sort.Ints(precedences)
+
+	for _, precedence := range precedences {
+		names = names[:0]
+
+		for _, t := range levels[precedence] {
+			names = append(names, t.Name)
+		}
+
+		fmt.Fprintf(&buf, "%%%s %s\n", assocKeyword(levels[precedence][0].Assoc), strings.Join(names, " "))
+	}
+
+	fmt.Fprintf(&buf, "%%start %s\n\n%%%%\n\n", g.Start)
+
+	for _, lhs := range g.NonTerminals() {
+		for i, rule := range g.RulesOf(lhs) {
+			if i == 0 {
+				buf.WriteString(lhs + ":")
+			} else {
+				buf.WriteString("|")
+			}
+
+			for _, symbol := range rule.Rhs {
+				buf.WriteString(" " + symbol)
+			}
+
+			if rule.Prec != "" {
+				buf.WriteString(" %prec " + rule.Prec)
+			}
+
+			if rule.Label != "" {
+				buf.WriteString(" #" + rule.Label)
+			}
+
+			if rule.Code != "" {
+				buf.WriteString(" " + rule.Code)
+			} else if rule.Dropped {
+				buf.WriteString(" /* action dropped */")
+			}
+
+			buf.WriteString("\n")
+		}
+
+		buf.WriteString(";\n\n")
+	}
+
+	if g.Epilogue != "" {
+		buf.WriteString("%%" + g.Epilogue)
+	} else {
+		buf.WriteString("%%\n")
+	}
+	src := buf.Bytes()
+
+	if formatted, err := format.Source(src); err == nil {
+		src = formatted
+	}
+
+	n, err := w.Write(src)
+
+	return int64(n), err
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func assocKeyword(assoc parse.SymbolAssoc) string {
+	switch assoc {
+	case parse.Left:
+		return "left"
+	case parse.Right:
+		return "right"
+	}
+
+	return "nonassoc"
+}
+
+// A transform in progress: the rules of the new grammar, and the rules of
+// the old one each comes from.
+type builder struct {
+	old      *Grammar
+	rules    []*Rule
+	origins  map[*Rule][]*Rule
+	names    map[string]bool     // The names of the symbols, to make new ones
+	children map[string][]string // The new non-terminals, by the one they are written after
+}
+
+// Start a transform with a copy of the rules of the grammar.
+func newBuilder(g *Grammar) *builder {
+	b := &builder{
+		old:      g,
+		origins:  make(map[*Rule][]*Rule),
+		names:    make(map[string]bool),
+		children: make(map[string][]string),
+	}
+
+	for _, t := range g.Terminals {
+		b.names[t.Name] = true
+	}
+
+	for _, rule := range g.Rules {
+		b.names[rule.Lhs] = true
+		copied := *rule
+		copied.Rhs = append([]string(nil), rule.Rhs...)
+		b.add(&copied, rule)
+	}
+
+	return b
+}
+
+// Add a rule which comes from the old ones. An existing rule with the same
+// sides gets their origins instead.
+func (b *builder) add(rule *Rule, origins ...*Rule) {
+	b.insert(len(b.rules), rule, origins...)
+}
+
+// Insert a rule before the i-th one, return the index after it.
+func (b *builder) insert(i int, rule *Rule, origins ...*Rule) int {
+	for _, other := range b.rules {
+		if other.sameAs(rule) {
+			b.addOrigins(other, origins...)
+
+			return i
+		}
+	}
+
+	b.rules = append(b.rules, nil)
+	copy(b.rules[i+1:], b.rules[i:])
+	b.rules[i] = rule
+	b.addOrigins(rule, origins...)
+
+	return i + 1
+}
+
+func (b *builder) addOrigins(rule *Rule, origins ...*Rule) {
+	for _, origin := range origins {
+		if !containsRule(b.origins[rule], origin) {
+			b.origins[rule] = append(b.origins[rule], origin)
+		}
+	}
+}
+
+func containsRule(rules []*Rule, rule *Rule) bool {
+	for _, other := range rules {
+		if other == rule {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Replace a rule of the new grammar, in place, by rules of the non-terminal
+// made from it. They keep its origins and its `%prec`, but not its code and
+// label.
+func (b *builder) replace(rule *Rule, lhs string, rhss ...[]string) {
+	origins := b.origins[rule]
+	i := b.remove(rule)
+
+	for _, rhs := range rhss {
+		i = b.insert(i, &Rule{Lhs: lhs, Rhs: rhs, Prec: rule.Prec}, origins...)
+	}
+}
+
+// Remove a rule, return its index.
+func (b *builder) remove(rule *Rule) int {
+	for i, other := range b.rules {
+		if other == rule {
+			b.rules = append(b.rules[:i], b.rules[i+1:]...)
+			delete(b.origins, rule)
+
+			return i
+		}
+	}
+
+	return len(b.rules)
+}
+
+// Make a name for a new non-terminal, e.g. expr_1 for expr. Its rules are
+// written after the ones of `after`, or last if it is "".
+func (b *builder) newName(base string, after string) string {
+	for i := 1; ; i++ {
+		if name := base + "_" + strconv.Itoa(i); !b.names[name] {
+			b.names[name] = true
+			b.children[after] = append(b.children[after], name)
+
+			return name
+		}
+	}
+}
+
+// Get the rules of the non-terminal in the new grammar, in order.
+func (b *builder) rulesOf(lhs string) []*Rule {
+	var rules []*Rule
+
+	for _, rule := range b.rules {
+		if rule.Lhs == lhs {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
+
+// Remove the rules which use a non-terminal without rules, until there are
+// none.
+func (b *builder) removeUnproductive() {
+	for changed := true; changed; {
+		changed = false
+		defined := make(map[string]bool)
+
+		for _, rule := range b.rules {
+			defined[rule.Lhs] = true
+		}
+
+		for _, rule := range append([]*Rule(nil), b.rules...) {
+			for _, symbol := range rule.Rhs {
+				if !defined[symbol] && !b.old.IsTerminal(symbol) {
+					b.remove(rule)
+					changed = true
+
+					break
+				}
+			}
+		}
+	}
+}
+
+// Remove the rules of the non-terminals the start symbol doesn't derive.
+func (b *builder) removeUnreachable(start string) {
+	reachable := map[string]bool{start: true}
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, rule := range b.rules {
+			for _, symbol := range rule.Rhs {
+				if reachable[rule.Lhs] && !reachable[symbol] {
+					reachable[symbol] = true
+					changed = true
+				}
+			}
+		}
+	}
+
+	for _, rule := range append([]*Rule(nil), b.rules...) {
+		if !reachable[rule.Lhs] {
+			b.remove(rule)
+		}
+	}
+}
+
+// Finish the transform: the new grammar, with the rules of each
+// non-terminal together, and the new rules of each old one. A new start
+// symbol comes first, the other new non-terminals after the one they come
+// from.
+func (b *builder) result(start string) (*Grammar, Mapping) {
+	g := &Grammar{
+		Start:     start,
+		Terminals: b.old.Terminals,
+		Name:      b.old.Name,
+		Prologue:  b.old.Prologue,
+		Union:     b.old.Union,
+		Types:     b.old.Types,
+		Epilogue:  b.old.Epilogue,
+	}
+	mapping := make(Mapping)
+	order := make(map[string]int)
+	var visit func(lhs string)
+
+	visit = func(lhs string) {
+		order[lhs] = len(order)
+
+		for _, child := range b.children[lhs] {
+			visit(child)
+		}
+	}
+
+	if start != b.old.Start {
+		visit(start)
+	}
+
+	for _, lhs := range b.old.NonTerminals() {
+		visit(lhs)
+	}
+
+	for _, child := range b.children[""] {
+		if _, ok := order[child]; !ok {
+			visit(child)
+		}
+	}
+
+	g.Rules = append(g.Rules, b.rules...)
+	sort.SliceStable(g.Rules, func(i, j int) bool { return order[g.Rules[i].Lhs] < order[g.Rules[j].Lhs] })
+
+	for _, rule := range g.Rules {
+		for _, origin := range b.origins[rule] {
+			mapping[origin] = append(mapping[origin], rule)
+			rule.Dropped = rule.Dropped || rule.Code == "" && (origin.Code != "" || origin.Dropped)
+		}
+	}
+
+	return g, mapping
+}
+
+// Compose the mappings of two transforms applied one after the other.
+func (mapping Mapping) Then(next Mapping) Mapping {
+	composed := make(Mapping)
+
+	for old, rules := range mapping {
+		for _, rule := range rules {
+			for _, newRule := range next[rule] {
+				if !containsRule(composed[old], newRule) {
+					composed[old] = append(composed[old], newRule)
+				}
+			}
+		}
+	}
+
+	return composed
+}
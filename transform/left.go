@@ -0,0 +1,214 @@
+package transform
+
+// Remove the left recursion, direct or through other non-terminals, by the
+// algorithm of Paull. In the order of the grammar, a rule of a left
+// recursive non-terminal which starts with an earlier one of the same
+// cycle gets the rules of that one instead. Then the direct recursion
+// `A ::= A α | β` becomes `A ::= β A_1` and `A_1 ::= α A_1 | ε`. The
+// recursion behind a nullable symbol is kept: remove the ε-rules first.
+func RemoveLeftRecursion(g *Grammar) (*Grammar, Mapping) {
+	b := newBuilder(g)
+	cycles := g.leftCycles()
+	names := g.NonTerminals()
+
+	for i, lhs := range names {
+		if cycles[lhs] == 0 {
+			continue
+		}
+
+		for _, earlier := range names[:i] {
+			if cycles[earlier] != cycles[lhs] {
+				continue
+			}
+
+			for _, rule := range b.rulesOf(lhs) {
+				if len(rule.Rhs) == 0 || rule.Rhs[0] != earlier {
+					continue
+				}
+
+				var rhss [][]string
+
+				for _, other := range b.rulesOf(earlier) {
+					rhss = append(rhss, concat(other.Rhs, rule.Rhs[1:]))
+				}
+
+				b.replace(rule, lhs, rhss...)
+			}
+		}
+
+		b.removeDirectRecursion(lhs)
+	}
+
+	b.removeUnproductive()
+
+	return b.result(g.Start)
+}
+
+// Replace the rules `A ::= A α` of the non-terminal by rules of a new one
+// on the right. `A ::= A` derives nothing and is removed.
+func (b *builder) removeDirectRecursion(lhs string) {
+	var recursive, others []*Rule
+
+	for _, rule := range b.rulesOf(lhs) {
+		if len(rule.Rhs) > 0 && rule.Rhs[0] == lhs {
+			recursive = append(recursive, rule)
+		} else {
+			others = append(others, rule)
+		}
+	}
+
+	if len(recursive) == 0 {
+		return
+	}
+
+	tail := b.newName(lhs, lhs)
+
+	for _, rule := range others {
+		b.replace(rule, lhs, concat(rule.Rhs, []string{tail}))
+	}
+
+	for _, rule := range recursive {
+		if len(rule.Rhs) == 1 {
+			b.remove(rule)
+		} else {
+			b.replace(rule, tail, concat(rule.Rhs[1:], []string{tail}))
+		}
+	}
+
+	b.add(&Rule{Lhs: tail})
+}
+
+// Number the cycles of the non-terminals which derive a sentential form
+// starting with themselves, from 1. The others are not in the map.
+func (g *Grammar) leftCycles() map[string]int {
+	corners := make(map[string]map[string]bool) // The non-terminals each derives first, through others
+
+	for _, lhs := range g.NonTerminals() {
+		corners[lhs] = make(map[string]bool)
+	}
+
+	for _, rule := range g.Rules {
+		if len(rule.Rhs) > 0 && !g.IsTerminal(rule.Rhs[0]) {
+			corners[rule.Lhs][rule.Rhs[0]] = true
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, set := range corners {
+			for corner := range set {
+				for other := range corners[corner] {
+					if !set[other] {
+						set[other] = true
+						changed = true
+					}
+				}
+			}
+		}
+	}
+
+	cycles := make(map[string]int)
+
+	for _, lhs := range g.NonTerminals() {
+		if _, ok := cycles[lhs]; ok || !corners[lhs][lhs] {
+			continue
+		}
+
+		n := len(cycles) + 1
+
+		for other := range corners[lhs] {
+			if corners[other][lhs] {
+				cycles[other] = n
+			}
+		}
+	}
+
+	return cycles
+}
+
+func concat(x, y []string) []string {
+	return append(append([]string(nil), x...), y...)
+}
+
+// Factor the longest prefix of the rules of a non-terminal which start
+// with the same symbol: `A ::= α β | α γ` becomes `A ::= α A_1` and
+// `A_1 ::= β | γ`. The new non-terminals are factored too, until no two
+// rules of a non-terminal start with the same symbol.
+func LeftFactor(g *Grammar) (*Grammar, Mapping) {
+	b := newBuilder(g)
+	queue := g.NonTerminals()
+
+	for len(queue) > 0 {
+		lhs := queue[0]
+		queue = queue[1:]
+
+		for group := commonFirst(b.rulesOf(lhs)); group != nil; group = commonFirst(b.rulesOf(lhs)) {
+			prefix := commonPrefix(group)
+			tail := b.newName(lhs, lhs)
+			origins := make([][]*Rule, len(group))
+			var all []*Rule
+			at := len(b.rules)
+
+			for i, rule := range group {
+				origins[i] = b.origins[rule]
+				all = append(all, origins[i]...)
+
+				if j := b.remove(rule); j < at {
+					at = j
+				}
+			}
+
+			b.insert(at, &Rule{Lhs: lhs, Rhs: concat(prefix, []string{tail})}, all...)
+
+			for i, rule := range group {
+				b.add(&Rule{Lhs: tail, Rhs: concat(rule.Rhs[len(prefix):], nil), Prec: rule.Prec}, origins[i]...)
+			}
+
+			queue = append(queue, tail)
+		}
+	}
+
+	return b.result(g.Start)
+}
+
+// Get the rules which start with the same symbol as the first rule which
+// shares it with another, or nil.
+func commonFirst(rules []*Rule) []*Rule {
+	for i, rule := range rules {
+		if len(rule.Rhs) == 0 {
+			continue
+		}
+
+		group := []*Rule{rule}
+
+		for _, other := range rules[i+1:] {
+			if len(other.Rhs) > 0 && other.Rhs[0] == rule.Rhs[0] {
+				group = append(group, other)
+			}
+		}
+
+		if len(group) > 1 {
+			return group
+		}
+	}
+
+	return nil
+}
+
+// Get the longest prefix of the right hand sides of the rules.
+func commonPrefix(rules []*Rule) []string {
+	prefix := rules[0].Rhs
+
+	for _, rule := range rules[1:] {
+		n := 0
+
+		for n < len(prefix) && n < len(rule.Rhs) && prefix[n] == rule.Rhs[n] {
+			n++
+		}
+
+		prefix = prefix[:n]
+	}
+
+	return concat(prefix, nil)
+}
@@ -0,0 +1,435 @@
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/golemon/parse"
+)
+
+const exprGrammar = `%left PLUS
+%left TIMES
+%%
+e: e PLUS t | t ;
+t: t TIMES f | f ;
+f: LPAREN e RPAREN | ID ;
+%%
+`
+
+// Left recursive through s, with ε-rules and unit rules.
+const indirectGrammar = `%%
+s: a X | Y ;
+a: a Z | s W | b ;
+b: | V b ;
+%%
+`
+
+const ifGrammar = `%%
+s: IF E THEN s | IF E THEN s ELSE s | X ;
+%%
+`
+
+func readGrammar(t *testing.T, name string, src string) *Grammar {
+	lemon := parse.NewLemonFromBytes(name, []byte(src), "")
+	lemon.ReadGrammar()
+
+	for _, finding := range lemon.Findings() {
+		t.Errorf("%s: unexpected finding: %s", name, finding.Message)
+	}
+
+	return FromLemon(lemon)
+}
+
+func ruleStrings(rules []*Rule) []string {
+	strs := make([]string, len(rules))
+
+	for i, rule := range rules {
+		strs[i] = rule.String()
+	}
+
+	return strs
+}
+
+func checkStrings(t *testing.T, name string, expected []string, actual []string) {
+	t.Helper()
+
+	if strings.Join(expected, "\n") != strings.Join(actual, "\n") {
+		t.Errorf("%s:\nexpected\n\t%s\nactual\n\t%s", name, strings.Join(expected, "\n\t"), strings.Join(actual, "\n\t"))
+	}
+}
+
+// Get the new rules of the old rule printed as `old`.
+func mapped(g *Grammar, mapping Mapping, old string) []string {
+	for _, rule := range g.Rules {
+		if rule.String() == old {
+			return ruleStrings(mapping[rule])
+		}
+	}
+
+	return nil
+}
+
+// Get the sentences of at most maxLength terminals the grammar derives,
+// sorted.
+func sentences(g *Grammar, maxLength int) []string {
+	sets := make(map[string]map[string]bool)
+
+	for _, t := range g.Terminals {
+		sets[t.Name] = map[string]bool{t.Name: true}
+	}
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, rule := range g.Rules {
+			if sets[rule.Lhs] == nil {
+				sets[rule.Lhs] = make(map[string]bool)
+			}
+
+			for _, sentence := range compose(sets, rule.Rhs, maxLength) {
+				if !sets[rule.Lhs][sentence] {
+					sets[rule.Lhs][sentence] = true
+					changed = true
+				}
+			}
+		}
+	}
+
+	var result []string
+
+	for sentence := range sets[g.Start] {
+		result = append(result, sentence)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// Get the sentences of at most maxLength terminals made of sentences of
+// the symbols, as the names of the terminals separated by spaces.
+func compose(sets map[string]map[string]bool, symbols []string, maxLength int) []string {
+	prefixes := []string{""}
+
+	for _, symbol := range symbols {
+		var next []string
+
+		for _, prefix := range prefixes {
+			for sentence := range sets[symbol] {
+				joined := strings.TrimSpace(prefix + " " + sentence)
+
+				if len(strings.Fields(joined)) <= maxLength {
+					next = append(next, joined)
+				}
+			}
+		}
+
+		prefixes = next
+	}
+
+	return prefixes
+}
+
+// Check the new grammar derives the same sentences, and can be read back.
+func checkTransform(t *testing.T, name string, old *Grammar, g *Grammar) {
+	t.Helper()
+	checkStrings(t, name+" sentences", sentences(old, 5), sentences(g, 5))
+	var buf bytes.Buffer
+
+	if _, err := g.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	read := readGrammar(t, name, buf.String())
+	checkStrings(t, name+" read back", ruleStrings(g.Rules), ruleStrings(read.Rules))
+}
+
+func TestRemoveLeftRecursion(t *testing.T) {
+	old := readGrammar(t, "expr.y", exprGrammar)
+	g, mapping := RemoveLeftRecursion(old)
+	checkStrings(t, "expr.y", []string{
+		"e:t e_1.",
+		"e_1:PLUS t e_1.",
+		"e_1:.",
+		"t:f t_1.",
+		"t_1:TIMES f t_1.",
+		"t_1:.",
+		"f:LPAREN e RPAREN.",
+		"f:ID.",
+	}, ruleStrings(g.Rules))
+	checkStrings(t, "expr.y mapping", []string{"e_1:PLUS t e_1."}, mapped(old, mapping, "e:e PLUS t."))
+	checkStrings(t, "expr.y mapping", []string{"f:ID."}, mapped(old, mapping, "f:ID."))
+	checkTransform(t, "expr.y", old, g)
+
+	old = readGrammar(t, "indirect.y", indirectGrammar)
+	g, mapping = RemoveLeftRecursion(old)
+	checkStrings(t, "indirect.y", []string{
+		"s:a X.",
+		"s:Y.",
+		"a:Y W a_1.",
+		"a:b a_1.",
+		"a_1:Z a_1.",
+		"a_1:X W a_1.",
+		"a_1:.",
+		"b:.",
+		"b:V b.",
+	}, ruleStrings(g.Rules))
+	checkStrings(t, "indirect.y mapping", []string{"a:Y W a_1.", "a_1:X W a_1."}, mapped(old, mapping, "a:s W."))
+	checkTransform(t, "indirect.y", old, g)
+
+	// The recursion through the nullable b is kept.
+	old = readGrammar(t, "nullable.y", `%%
+a: b a X | Y ;
+b: | Z ;
+%%
+`)
+	g, _ = RemoveLeftRecursion(old)
+	checkStrings(t, "nullable.y", ruleStrings(old.Rules), ruleStrings(g.Rules))
+
+	// Left recursion is removed once the ε-rules are.
+	g, _ = RemoveEpsilonRules(old)
+	g, _ = RemoveLeftRecursion(g)
+	var buf bytes.Buffer
+	g.WriteTo(&buf)
+	lemon := parse.NewLemonFromBytes("nullable.y", buf.Bytes(), "")
+	lemon.ReadGrammar()
+
+	for _, conflict := range lemon.LL1Conflicts() {
+		if conflict.Kind == parse.LeftRecursion {
+			t.Errorf("nullable.y: %v", conflict)
+		}
+	}
+}
+
+func TestLeftFactor(t *testing.T) {
+	old := readGrammar(t, "if.y", ifGrammar)
+	g, mapping := LeftFactor(old)
+	checkStrings(t, "if.y", []string{
+		"s:IF E THEN s s_1.",
+		"s:X.",
+		"s_1:.",
+		"s_1:ELSE s.",
+	}, ruleStrings(g.Rules))
+	checkStrings(t, "if.y mapping", []string{"s:IF E THEN s s_1.", "s_1:ELSE s."}, mapped(old, mapping, "s:IF E THEN s ELSE s."))
+	checkTransform(t, "if.y", old, g)
+
+	// The new non-terminals are factored too.
+	old = readGrammar(t, "nested.y", `%%
+s: A B C | A B D | A E ;
+%%
+`)
+	g, _ = LeftFactor(old)
+	checkStrings(t, "nested.y", []string{
+		"s:A s_1.",
+		"s_1:B s_1_1.",
+		"s_1:E.",
+		"s_1_1:C.",
+		"s_1_1:D.",
+	}, ruleStrings(g.Rules))
+	checkTransform(t, "nested.y", old, g)
+}
+
+func TestRemoveEpsilonRules(t *testing.T) {
+	old := readGrammar(t, "indirect.y", indirectGrammar)
+	g, mapping := RemoveEpsilonRules(old)
+	checkStrings(t, "indirect.y", []string{
+		"s:a X.",
+		"s:X.",
+		"s:Y.",
+		"a:a Z.",
+		"a:Z.",
+		"a:s W.",
+		"a:b.",
+		"b:V b.",
+		"b:V.",
+	}, ruleStrings(g.Rules))
+	checkStrings(t, "indirect.y mapping", []string{"s:a X.", "s:X."}, mapped(old, mapping, "s:a X."))
+	checkStrings(t, "indirect.y mapping", nil, mapped(old, mapping, "b:."))
+	checkTransform(t, "indirect.y", old, g)
+
+	// The start symbol is nullable and on a right hand side.
+	old = readGrammar(t, "list.y", `%%
+list: | list ITEM ;
+%%
+`)
+	g, mapping = RemoveEpsilonRules(old)
+	checkStrings(t, "list.y", []string{
+		"list_1:list.",
+		"list_1:.",
+		"list:list ITEM.",
+		"list:ITEM.",
+	}, ruleStrings(g.Rules))
+	checkStrings(t, "list.y mapping", []string{"list_1:."}, mapped(old, mapping, "list:."))
+	checkTransform(t, "list.y", old, g)
+}
+
+func TestRemoveUnitRules(t *testing.T) {
+	old := readGrammar(t, "expr.y", exprGrammar)
+	g, mapping := RemoveUnitRules(old)
+	checkStrings(t, "expr.y", []string{
+		"e:e PLUS t.",
+		"e:t TIMES f.",
+		"e:LPAREN e RPAREN.",
+		"e:ID.",
+		"t:t TIMES f.",
+		"t:LPAREN e RPAREN.",
+		"t:ID.",
+		"f:LPAREN e RPAREN.",
+		"f:ID.",
+	}, ruleStrings(g.Rules))
+	checkStrings(t, "expr.y mapping", []string{
+		"e:LPAREN e RPAREN.",
+		"e:ID.",
+		"t:LPAREN e RPAREN.",
+		"t:ID.",
+	}, mapped(old, mapping, "t:f."))
+	checkStrings(t, "expr.y mapping", []string{"e:ID.", "t:ID.", "f:ID."}, mapped(old, mapping, "f:ID."))
+	checkTransform(t, "expr.y", old, g)
+}
+
+func TestChomskyNormalForm(t *testing.T) {
+	for _, test := range []struct{ name, src string }{
+		{"expr.y", exprGrammar},
+		{"indirect.y", indirectGrammar},
+		{"if.y", ifGrammar},
+	} {
+		old := readGrammar(t, test.name, test.src)
+		g, mapping := ChomskyNormalForm(old)
+
+		for _, rule := range g.Rules {
+			switch {
+			case len(rule.Rhs) == 0 && rule.Lhs == g.Start && !g.onRightHandSide(g.Start):
+			case len(rule.Rhs) == 1 && g.IsTerminal(rule.Rhs[0]):
+			case len(rule.Rhs) == 2 && !g.IsTerminal(rule.Rhs[0]) && !g.IsTerminal(rule.Rhs[1]):
+			default:
+				t.Errorf("%s: %v is not in Chomsky normal form", test.name, rule)
+			}
+		}
+
+		for _, rule := range old.Rules {
+			for _, newRule := range mapping[rule] {
+				if !containsRule(g.Rules, newRule) {
+					t.Errorf("%s: %v is mapped to %v, which is not a rule", test.name, rule, newRule)
+				}
+			}
+		}
+
+		checkTransform(t, test.name, old, g)
+	}
+
+	old := readGrammar(t, "if.y", ifGrammar)
+	g, mapping := ChomskyNormalForm(old)
+	checkStrings(t, "if.y", []string{
+		"s_1:if_1 s_2.",
+		"s_1:if_1 s_4.",
+		"s_1:X.",
+		"s:if_1 s_2.",
+		"s:if_1 s_4.",
+		"s:X.",
+		"s_2:e_1 s_3.",
+		"s_3:then_1 s.",
+		"s_4:e_1 s_5.",
+		"s_5:then_1 s_6.",
+		"s_6:s s_7.",
+		"s_7:else_1 s.",
+		"if_1:IF.",
+		"e_1:E.",
+		"then_1:THEN.",
+		"else_1:ELSE.",
+	}, ruleStrings(g.Rules))
+	checkStrings(t, "if.y mapping", []string{"s_1:X.", "s:X."}, mapped(old, mapping, "s:X."))
+}
+
+func TestWriteTo(t *testing.T) {
+	g := readGrammar(t, "prec.y", `%token NUM
+%left PLUS MINUS
+%right POW
+%nonassoc UMINUS
+%start e
+%%
+e: e PLUS e | e MINUS e | e POW e | MINUS e %prec UMINUS #Neg { $$ = -$2 } | NUM ;
+%%
+`)
+	var buf bytes.Buffer
+
+	if _, err := g.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	checkStrings(t, "prec.y", strings.Split(`%token    NUM
+%left     MINUS PLUS
+%right    POW
+%nonassoc UMINUS
+%start    e
+
+%%
+
+e
+	: e PLUS e
+	| e MINUS e
+	| e POW e
+	| MINUS e %prec UMINUS #Neg { $$ = -$2 }
+	| NUM
+	;
+
+%%
+`, "\n"), strings.Split(buf.String(), "\n"))
+}
+
+// A grammar with code reads back the same once written, and keeps its code
+// and declarations once transformed.
+const calcGrammar = `%{
+package main
+%}
+%name calc
+%union {
+	n int
+}
+%type <n> e
+%token <n> NUM
+%left <n> PLUS
+%%
+e: e PLUS NUM { $$ = $1 + $3 } | NUM ;
+%%
+func main() {}
+`
+
+func writeString(t *testing.T, g *Grammar) string {
+	var buf bytes.Buffer
+
+	if _, err := g.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+func TestWriteToRoundTrip(t *testing.T) {
+	src := writeString(t, readGrammar(t, "calc.y", calcGrammar))
+	checkStrings(t, "calc.y", strings.Split(src, "\n"), strings.Split(writeString(t, readGrammar(t, "calc.y", src)), "\n"))
+
+	g, _ := RemoveLeftRecursion(readGrammar(t, "calc.y", calcGrammar))
+	src = writeString(t, g)
+
+	if !strings.Contains(src, "/* action dropped */") {
+		t.Errorf("calc.y: no dropped action in\n%s", src)
+	}
+
+	read := readGrammar(t, "calc.y transformed", src)
+	checkStrings(t, "calc.y transformed", []string{"calc", "\npackage main\n", "{\n\tn int\n}", "n", "\nfunc main() {}\n"},
+		[]string{read.Name, read.Prologue, read.Union, read.Types["e"], read.Epilogue})
+	checkStrings(t, "calc.y terminals", []string{"NUM <n> -1", "PLUS <n> 1"}, terminalStrings(read))
+}
+
+func terminalStrings(g *Grammar) []string {
+	var strs []string
+
+	for _, t := range g.Terminals {
+		strs = append(strs, fmt.Sprintf("%s <%s> %d", t.Name, t.Type, t.Precedence))
+	}
+
+	return strs
+}
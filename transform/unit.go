@@ -0,0 +1,64 @@
+package transform
+
+// Remove the unit rules `A ::= B`: A gets the other rules of each
+// non-terminal it derives through unit rules, which come from all the
+// rules of the way. The non-terminals the start symbol no longer derives
+// are removed.
+func RemoveUnitRules(g *Grammar) (*Grammar, Mapping) {
+	b := newBuilder(g)
+	isUnit := func(rule *Rule) bool { return len(rule.Rhs) == 1 && !g.IsTerminal(rule.Rhs[0]) }
+	var units []*Rule
+	var added []*Rule
+	addedOrigins := make(map[*Rule][]*Rule)
+
+	for _, rule := range b.rules {
+		if isUnit(rule) {
+			units = append(units, rule)
+		}
+	}
+
+	for _, lhs := range g.NonTerminals() {
+		// The rules which derive each non-terminal from lhs, the first way
+		// found.
+		via := map[string][]*Rule{lhs: nil}
+		queue := []string{lhs}
+
+		for len(queue) > 0 {
+			symbol := queue[0]
+			queue = queue[1:]
+
+			for _, rule := range b.rulesOf(symbol) {
+				if !isUnit(rule) {
+					if symbol != lhs {
+						copied := &Rule{Lhs: lhs, Rhs: concat(rule.Rhs, nil), Prec: rule.Prec}
+						added = append(added, copied)
+						addedOrigins[copied] = append(addedOrigins[copied], b.origins[rule]...)
+
+						for _, unit := range via[symbol] {
+							addedOrigins[copied] = append(addedOrigins[copied], b.origins[unit]...)
+						}
+					}
+
+					continue
+				}
+
+				if _, ok := via[rule.Rhs[0]]; !ok {
+					via[rule.Rhs[0]] = append(append([]*Rule(nil), via[symbol]...), rule)
+					queue = append(queue, rule.Rhs[0])
+				}
+			}
+		}
+	}
+
+	for _, rule := range units {
+		b.remove(rule)
+	}
+
+	for _, rule := range added {
+		b.add(rule, addedOrigins[rule]...)
+	}
+
+	b.removeUnreachable(g.Start)
+
+	return b.result(g.Start)
+}
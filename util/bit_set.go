@@ -0,0 +1,108 @@
+package util
+
+import "math/bits"
+
+// BitSet is a set of small non-negative integers, one bit per possible
+// value. Its capacity is fixed when it is made.
+type BitSet []uint64
+
+// NewBitSet makes an empty set which can hold the integers below n.
+func NewBitSet(n int) BitSet {
+	return make(BitSet, (n+63)/64)
+}
+
+func (set BitSet) Add(v int) {
+	set[v/64] |= 1 << (v % 64)
+}
+
+func (set BitSet) Remove(v int) {
+	set[v/64] &^= 1 << (v % 64)
+}
+
+func (set BitSet) Includes(v int) bool {
+	return v/64 < len(set) && set[v/64]&(1<<(v%64)) != 0
+}
+
+// AddSet adds the elements of src, which must not have a bigger capacity,
+// and reports whether the receiver has changed.
+func (set BitSet) AddSet(src BitSet) bool {
+	changed := false
+
+	for i, word := range src {
+		if set[i]|word != set[i] {
+			set[i] |= word
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// AddInts adds the elements of an IntSet.
+func (set BitSet) AddInts(src IntSet) {
+	for v := range src {
+		set.Add(v)
+	}
+}
+
+func (set BitSet) HasIntersect(other BitSet) bool {
+	for i := 0; i < len(set) && i < len(other); i++ {
+		if set[i]&other[i] != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (set BitSet) Intersect(other BitSet) BitSet {
+	result := make(BitSet, len(set))
+
+	for i := 0; i < len(set) && i < len(other); i++ {
+		result[i] = set[i] & other[i]
+	}
+
+	return result
+}
+
+// Len returns number of elements in the receiver.
+func (set BitSet) Len() int {
+	n := 0
+
+	for _, word := range set {
+		n += bits.OnesCount64(word)
+	}
+
+	return n
+}
+
+func (set BitSet) Equal(other BitSet) bool {
+	if len(set) != len(other) {
+		return false
+	}
+
+	for i := range set {
+		if set[i] != other[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (set BitSet) Clone() BitSet {
+	return append(BitSet(nil), set...)
+}
+
+// AsSlice returns the receiver's elements in increasing order.
+func (set BitSet) AsSlice() []int {
+	result := make([]int, 0, set.Len())
+
+	for i, word := range set {
+		for ; word != 0; word &= word - 1 {
+			result = append(result, i*64+bits.TrailingZeros64(word))
+		}
+	}
+
+	return result
+}